@@ -0,0 +1,376 @@
+package pathfind
+
+import (
+	"errors"
+	"math/rand"
+)
+
+// ALT（A*, Landmarks, Triangle inequality）
+// 预先从几个地标出发求出到所有格子的真实距离，由三角不等式
+//   d(v,t) >= |d(L,t) - d(L,v)|
+// 得到比曼哈顿距离强得多的下界，在迷宫里绕远路的时候可以少扩展很多格子。
+// 小地图可以直接存下所有格子两两之间的距离（DistanceTable），启发函数就是真实距离
+
+// MaxDistanceTableCells DistanceTable允许的最多通路格子数，表的大小是它的平方
+const MaxDistanceTableCells = 2048
+
+var ErrTableTooLarge = errors.New("too many floor cells for a distance table")
+
+// LandmarkStrategy 地标的选取方式
+type LandmarkStrategy string
+
+const (
+	LandmarkFarthest LandmarkStrategy = "farthest" // 每次选离已有地标最远的格子
+	LandmarkRandom   LandmarkStrategy = "random"   // 随机选可通行的格子
+	LandmarkCorners  LandmarkStrategy = "corners"  // 离四个角和四条边中点最近的格子，不够时按farthest补充
+)
+
+// DefaultLandmarkCount 默认的地标数量
+const DefaultLandmarkCount = 4
+
+// LandmarkStrategies 所有选取方式，页面上按这个顺序比较
+var LandmarkStrategies = []LandmarkStrategy{LandmarkFarthest, LandmarkRandom, LandmarkCorners}
+
+// Landmarks 地标和它们到所有格子的距离
+type Landmarks struct {
+	Points [][2]int
+	maze   [][]int
+	dist   [][][]int // dist[i]为从第i个地标出发的积分场，不可达为FlowUnreachable
+}
+
+// SelectLandmarks 按strategy从maze中选出最多n个地标，随机选取时从math/rand的全局随机源取一个种子
+func SelectLandmarks(maze [][]int, n int, strategy LandmarkStrategy) [][2]int {
+	return SelectLandmarksRand(rand.New(rand.NewSource(rand.Int63())), maze, n, strategy)
+}
+
+// SelectLandmarksRand 和SelectLandmarks相同，LandmarkRandom使用rng作为随机源
+func SelectLandmarksRand(rng *rand.Rand, maze [][]int, n int, strategy LandmarkStrategy) [][2]int {
+	floors := make([][2]int, 0)
+	for r, row := range maze {
+		for c, v := range row {
+			if v == 0 {
+				floors = append(floors, [2]int{r, c})
+			}
+		}
+	}
+	n = min(n, len(floors))
+	if n <= 0 {
+		return make([][2]int, 0)
+	}
+
+	points := make([][2]int, 0, n)
+	switch strategy {
+	case LandmarkRandom:
+		for _, i := range rng.Perm(len(floors))[:n] {
+			points = append(points, floors[i])
+		}
+		return points
+	case LandmarkCorners:
+		height, width := len(maze)-1, len(maze[0])-1
+		targets := [][2]int{{0, 0}, {0, width}, {height, width}, {height, 0},
+			{0, width / 2}, {height / 2, width}, {height, width / 2}, {height / 2, 0}}
+		chosen := make(map[[2]int]bool)
+		for _, target := range targets {
+			if len(points) == n {
+				break
+			}
+			best := floors[0]
+			for _, f := range floors {
+				if manhattanDistance(f, target) < manhattanDistance(best, target) {
+					best = f
+				}
+			}
+			if !chosen[best] {
+				chosen[best] = true
+				points = append(points, best)
+			}
+		}
+	}
+	return farthestLandmarks(maze, floors, points, n)
+}
+
+// 在已有地标的基础上，每次加入离所有地标最远的可达格子。
+// 地图有多个连通块时，地标只对所在的连通块有用：还没有地标的最大连通块比当前最远距离还大时，
+// 先在这个连通块里放一个地标（离连通块中任意格子最远的格子）
+func farthestLandmarks(maze [][]int, floors, points [][2]int, n int) [][2]int {
+	comp, sizes := floorComponents(maze, floors)
+	for len(points) < n {
+		best, bestDist := [2]int{}, 0
+		covered := make([]bool, len(sizes))
+		if len(points) > 0 {
+			ff, err := NewFlowField(maze, points)
+			if err != nil {
+				break
+			}
+			for _, f := range floors {
+				if d := ff.Cost[f[0]][f[1]]; d > bestDist {
+					best, bestDist = f, d
+				}
+			}
+			for _, p := range points {
+				covered[comp[p[0]][p[1]]] = true
+			}
+		}
+
+		uncovered := -1
+		for id, size := range sizes {
+			if !covered[id] && size > 1 && (uncovered < 0 || size > sizes[uncovered]) {
+				uncovered = id
+			}
+		}
+		if uncovered >= 0 && sizes[uncovered] > bestDist {
+			var first [2]int
+			for _, f := range floors {
+				if comp[f[0]][f[1]] == uncovered {
+					first = f
+					break
+				}
+			}
+			ff, _ := NewFlowField(maze, [][2]int{first})
+			best, bestDist = first, 1
+			for _, f := range floors {
+				if ff.Cost[f[0]][f[1]] > ff.Cost[best[0]][best[1]] {
+					best = f
+				}
+			}
+		}
+		if bestDist == 0 {
+			break
+		}
+		points = append(points, best)
+	}
+	return points
+}
+
+// 通路的4连通块：每个格子所属连通块的编号（墙为-1）和每个连通块的大小
+func floorComponents(maze [][]int, floors [][2]int) ([][]int, []int) {
+	comp := make([][]int, len(maze))
+	for r := range maze {
+		comp[r] = make([]int, len(maze[r]))
+		for c := range comp[r] {
+			comp[r][c] = -1
+		}
+	}
+	sizes := make([]int, 0)
+	dirs := [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}}
+	for _, f := range floors {
+		if comp[f[0]][f[1]] >= 0 {
+			continue
+		}
+		id := len(sizes)
+		comp[f[0]][f[1]] = id
+		queue := [][2]int{f}
+		for head := 0; head < len(queue); head++ {
+			for _, dir := range dirs {
+				next := [2]int{queue[head][0] + dir[0], queue[head][1] + dir[1]}
+				if isWalkable(maze, next) && comp[next[0]][next[1]] < 0 {
+					comp[next[0]][next[1]] = id
+					queue = append(queue, next)
+				}
+			}
+		}
+		sizes = append(sizes, len(queue))
+	}
+	return comp, sizes
+}
+
+// NewLandmarks 求出每个地标到所有格子的距离
+func NewLandmarks(maze [][]int, points [][2]int) *Landmarks {
+	lm := &Landmarks{maze: maze}
+	for _, p := range points {
+		ff, err := NewFlowField(maze, [][2]int{p})
+		if err != nil {
+			continue
+		}
+		lm.Points = append(lm.Points, p)
+		lm.dist = append(lm.dist, ff.Cost)
+	}
+	return lm
+}
+
+// Heuristic pos到goal距离的下界：各地标三角不等式下界和曼哈顿距离中的最大值
+func (lm *Landmarks) Heuristic(pos, goal [2]int) int {
+	h := manhattanDistance(pos, goal)
+	for _, d := range lm.dist {
+		dp, dg := d[pos[0]][pos[1]], d[goal[0]][goal[1]]
+		// 地标和pos或goal不在同一个连通块时得不到下界，跳过
+		if dp == FlowUnreachable || dg == FlowUnreachable {
+			continue
+		}
+		h = max(h, abs(dg-dp))
+	}
+	return h
+}
+
+// DistanceTable 所有通路格子两两之间的距离
+type DistanceTable struct {
+	maze  [][]int
+	index [][]int // 格子在表中的编号，墙为-1
+	cells int
+	dist  []int32 // dist[i*cells+j]，不可达为FlowUnreachable
+}
+
+// NewDistanceTable 从每个通路格子做一次BFS，通路格子超过MaxDistanceTableCells时返回ErrTableTooLarge
+func NewDistanceTable(maze [][]int) (*DistanceTable, error) {
+	t := &DistanceTable{maze: maze, index: make([][]int, len(maze))}
+	floors := make([][2]int, 0)
+	for r, row := range maze {
+		t.index[r] = make([]int, len(row))
+		for c, v := range row {
+			t.index[r][c] = -1
+			if v == 0 {
+				t.index[r][c] = len(floors)
+				floors = append(floors, [2]int{r, c})
+			}
+		}
+	}
+	if len(floors) > MaxDistanceTableCells {
+		return nil, ErrTableTooLarge
+	}
+	t.cells = len(floors)
+	t.dist = make([]int32, t.cells*t.cells)
+	for i := range t.dist {
+		t.dist[i] = FlowUnreachable
+	}
+
+	dirs := [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}}
+	queue := make([][2]int, 0, t.cells)
+	for i, f := range floors {
+		row := t.dist[i*t.cells : (i+1)*t.cells]
+		row[i] = 0
+		queue = append(queue[:0], f)
+		for head := 0; head < len(queue); head++ {
+			cur := queue[head]
+			d := row[t.index[cur[0]][cur[1]]]
+			for _, dir := range dirs {
+				next := [2]int{cur[0] + dir[0], cur[1] + dir[1]}
+				if !isWalkable(maze, next) || row[t.index[next[0]][next[1]]] != FlowUnreachable {
+					continue
+				}
+				row[t.index[next[0]][next[1]]] = d + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+	return t, nil
+}
+
+// Distance a到b的最短距离，不可达（包括墙和越界）时为FlowUnreachable
+func (t *DistanceTable) Distance(a, b [2]int) int {
+	if !isWalkable(t.maze, a) || !isWalkable(t.maze, b) {
+		return FlowUnreachable
+	}
+	return int(t.dist[t.index[a[0]][a[1]]*t.cells+t.index[b[0]][b[1]]])
+}
+
+// FindPath 用真实距离作为启发函数的A*，只扩展最短路径上的格子；终点不可达时不搜索
+func (t *DistanceTable) FindPath(start, end [2]int) PathFindResult {
+	if err := validateEnds(t.maze, start, end); err != nil {
+		return PathFindResult{Path: make([][2]int, 0), Err: err}
+	}
+	if t.Distance(start, end) == FlowUnreachable {
+		return PathFindResult{Path: make([][2]int, 0), StepRecord: MazeStepRecord{Steps: make([]MazeStep, 0)}}
+	}
+	return FindPathAStarHeuristic(t.maze, start, end, func(pos [2]int) int { return t.Distance(pos, end) })
+}
+
+// ALT 使用地标启发函数的A*，实现Preprocessor
+type ALT struct {
+	Count    int              // 地标数量，<=0时为DefaultLandmarkCount
+	Strategy LandmarkStrategy // 选取方式，为空时为LandmarkFarthest
+	Rand     *rand.Rand       // 随机选取地标使用的随机源，nil时在第一次预处理前从math/rand的全局随机源取一个种子
+	lm       *Landmarks
+}
+
+func (a *ALT) Preprocess(maze [][]int) {
+	count, strategy := a.Count, a.Strategy
+	if count <= 0 {
+		count = DefaultLandmarkCount
+	}
+	if strategy == "" {
+		strategy = LandmarkFarthest
+	}
+	if a.Rand == nil {
+		a.Rand = rand.New(rand.NewSource(rand.Int63()))
+	}
+	a.lm = NewLandmarks(maze, SelectLandmarksRand(a.Rand, maze, count, strategy))
+}
+
+// Landmarks 预处理选出的地标，没有预处理时为nil
+func (a *ALT) Landmarks() *Landmarks {
+	return a.lm
+}
+
+func (a *ALT) FindPath(maze [][]int, start, end [2]int) PathFindResult {
+	if a.lm == nil || !sameMaze(a.lm.maze, maze) {
+		a.Preprocess(maze)
+	}
+	return FindPathAStarHeuristic(maze, start, end, func(pos [2]int) int { return a.lm.Heuristic(pos, end) })
+}
+
+// FindPathAStarHeuristic 使用给定启发函数的A*，h必须是一致的（满足三角不等式），每个格子最多扩展一次
+func FindPathAStarHeuristic(maze [][]int, start, end [2]int, h func(pos [2]int) int) PathFindResult {
+	if err := validateEnds(maze, start, end); err != nil {
+		return PathFindResult{Path: make([][2]int, 0), Err: err}
+	}
+
+	var res PathFindResult
+	res.StepRecord.Steps = make([]MazeStep, 0)
+	g := map[[2]int]int{start: 0}
+	closed := make(map[[2]int]bool)
+	// f相同时先扩展g大的节点：地标下界常常是准确的，沿着它一直往前走可以少扩展很多f相同的格子
+	open := NewHeap(func(a, b *Node) bool { return a.f < b.f || a.f == b.f && a.g > b.g })
+	open.Push(&Node{pos: start, h: h(start), f: h(start)})
+	dirs := [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}}
+
+	var found *Node
+	for open.Len() > 0 {
+		cur := open.Pop()
+		if closed[cur.pos] {
+			continue
+		}
+		closed[cur.pos] = true
+		res.StepRecord.Steps = append(res.StepRecord.Steps, MazeStep{Pos: cur.pos, Type: "pop"})
+		if cur.pos == end {
+			found = cur
+			break
+		}
+		for _, dir := range dirs {
+			res.Check++
+			next := [2]int{cur.pos[0] + dir[0], cur.pos[1] + dir[1]}
+			if !isWalkable(maze, next) || closed[next] {
+				continue
+			}
+			ng := cur.g + 1
+			if old, ok := g[next]; ok && old <= ng {
+				continue
+			}
+			g[next] = ng
+			nh := h(next)
+			res.Cost++
+			open.Push(&Node{pos: next, g: ng, h: nh, f: ng + nh, parent: cur})
+			res.StepRecord.Steps = append(res.StepRecord.Steps, MazeStep{Pos: next, Type: "push"})
+		}
+	}
+
+	res.Path = make([][2]int, 0)
+	if found == nil {
+		return res
+	}
+	res.Found = true
+	for n := found; n != nil; n = n.parent {
+		res.Path = append([][2]int{n.pos}, res.Path...)
+	}
+	return res
+}
+
+// Expansions 搜索过程中出堆扩展的格子数
+func (r PathFindResult) Expansions() int {
+	count := 0
+	for _, step := range r.StepRecord.Steps {
+		if step.Type == "pop" || step.Type == StepPopBack {
+			count++
+		}
+	}
+	return count
+}
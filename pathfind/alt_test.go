@@ -0,0 +1,147 @@
+package pathfind
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// 地标启发函数不超过真实距离（可采纳），相邻格子之间相差不超过1（一致）
+func TestLandmarkHeuristicAdmissible(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	dirs := [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}}
+	for i := 0; i < 60; i++ {
+		maze := randomGrid(rng, 5+rng.Intn(25), 5+rng.Intn(25), rng.Float64()*0.45)
+		for _, strategy := range LandmarkStrategies {
+			lm := NewLandmarks(maze, SelectLandmarks(maze, 1+rng.Intn(8), strategy))
+			for k := 0; k < 5; k++ {
+				goal := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+				if !isWalkable(maze, goal) {
+					continue
+				}
+				ff, _ := NewFlowField(maze, [][2]int{goal})
+				for r := range maze {
+					for c := range maze[r] {
+						pos := [2]int{r, c}
+						if d := ff.Cost[r][c]; d == FlowUnreachable {
+							continue
+						} else if h := lm.Heuristic(pos, goal); h > d {
+							t.Fatalf("%s: h(%v,%v)=%d > distance %d, landmarks %v", strategy, pos, goal, h, d, lm.Points)
+						}
+						for _, dir := range dirs {
+							next := [2]int{r + dir[0], c + dir[1]}
+							if isWalkable(maze, next) && abs(lm.Heuristic(pos, goal)-lm.Heuristic(next, goal)) > 1 {
+								t.Fatalf("%s: heuristic not consistent between %v and %v", strategy, pos, next)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestALTStrategiesOptimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(6))
+	for _, strategy := range LandmarkStrategies {
+		alt := &ALT{Count: 6, Strategy: strategy}
+		for i := 0; i < 200; i++ {
+			maze := randomGrid(rng, 2+rng.Intn(25), 2+rng.Intn(25), rng.Float64()*0.45)
+			start := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+			end := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+			maze[start[0]][start[1]], maze[end[0]][end[1]] = 0, 0
+			checkAgainstDijkstra(t, "alt-"+string(strategy), maze, start, end, alt.FindPath(maze, start, end))
+		}
+	}
+}
+
+// 两个互不连通的大区域，最远点选取要在每个区域都放地标
+func TestFarthestLandmarksCoverComponents(t *testing.T) {
+	maze := parseGrid(
+		"..........#..........",
+		"..........#..........",
+		"..........#..........",
+		"..........#..........",
+		".#........#.........#")
+	points := SelectLandmarks(maze, 2, LandmarkFarthest)
+	if len(points) != 2 || (points[0][1] < 10) == (points[1][1] < 10) {
+		t.Fatalf("landmarks %v are in the same component", points)
+	}
+	// 孤立的单个格子没有用，不放地标
+	for _, p := range SelectLandmarks(parseGrid("..#.", "..##"), 3, LandmarkFarthest) {
+		if p == [2]int{0, 3} {
+			t.Fatal("landmark placed on an isolated cell")
+		}
+	}
+}
+
+func TestDistanceTable(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	for i := 0; i < 20; i++ {
+		maze := randomGrid(rng, 2+rng.Intn(20), 2+rng.Intn(20), rng.Float64()*0.45)
+		table, err := NewDistanceTable(maze)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for r := range maze {
+			for c := range maze[r] {
+				if maze[r][c] != 0 {
+					continue
+				}
+				ff, _ := NewFlowField(maze, [][2]int{{r, c}})
+				for r2 := range maze {
+					for c2 := range maze[r2] {
+						if maze[r2][c2] == 0 && table.Distance([2]int{r, c}, [2]int{r2, c2}) != ff.Cost[r2][c2] {
+							t.Fatalf("distance %v->%v = %d, want %d", [2]int{r, c}, [2]int{r2, c2},
+								table.Distance([2]int{r, c}, [2]int{r2, c2}), ff.Cost[r2][c2])
+						}
+					}
+				}
+			}
+		}
+
+		start := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+		end := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+		maze[start[0]][start[1]], maze[end[0]][end[1]] = 0, 0
+		table, _ = NewDistanceTable(maze)
+		res := table.FindPath(start, end)
+		checkAgainstDijkstra(t, "distance table", maze, start, end, res)
+		// 启发函数是真实距离，只扩展路径上的格子
+		if res.Found && res.Expansions() != len(res.Path) {
+			t.Fatalf("expanded %d cells for a path of %d", res.Expansions(), len(res.Path))
+		}
+	}
+
+	if _, err := NewDistanceTable(randomGrid(rng, 50, 50, 0)); !errors.Is(err, ErrTableTooLarge) {
+		t.Fatalf("2500 floor cells: err %v, want ErrTableTooLarge", err)
+	}
+}
+
+// 随机选取的地标是互不相同的通路格子，同一个种子选出的地标相同
+func TestRandomLandmarksSeeded(t *testing.T) {
+	maze := randomGrid(rand.New(rand.NewSource(50)), 30, 30, 0.3)
+	for seed := int64(0); seed < 10; seed++ {
+		points := SelectLandmarksRand(rand.New(rand.NewSource(seed)), maze, 8, LandmarkRandom)
+		again := SelectLandmarksRand(rand.New(rand.NewSource(seed)), maze, 8, LandmarkRandom)
+		if len(points) != 8 || !reflect.DeepEqual(points, again) {
+			t.Fatalf("seed %d: landmarks %v, again %v", seed, points, again)
+		}
+		seen := make(map[[2]int]bool)
+		for _, p := range points {
+			if !isWalkable(maze, p) || seen[p] {
+				t.Fatalf("seed %d: bad landmark %v in %v", seed, p, points)
+			}
+			seen[p] = true
+		}
+
+		// ALT预处理使用Rand，同一个种子的地标相同
+		a := &ALT{Count: 8, Strategy: LandmarkRandom, Rand: rand.New(rand.NewSource(seed))}
+		b := &ALT{Count: 8, Strategy: LandmarkRandom, Rand: rand.New(rand.NewSource(seed))}
+		a.Preprocess(maze)
+		b.Preprocess(maze)
+		if !reflect.DeepEqual(a.Landmarks().Points, b.Landmarks().Points) {
+			t.Fatalf("seed %d: ALT landmarks %v and %v", seed, a.Landmarks().Points, b.Landmarks().Points)
+		}
+	}
+}
@@ -1,18 +1,17 @@
 package pathfind
 
 import (
-	"container/heap"
 	"math"
 )
 
-// Node 表示搜索中的一个节点
+// Node 搜索中的一个节点，A*、Dijkstra、最佳优先搜索和JPS共用
 type Node struct {
-	pos    [2]int // 位置
-	g      int    // 从起点到当前点的实际代价
-	h      int    // 从当前点到终点的估计代价
-	f      int    // f = g + h
-	parent *Node  // 父节点
-	index  int    // 在优先队列中的索引
+	pos     [2]int // 位置
+	g       int    // 从起点到当前点的实际代价
+	h       int    // 从当前点到终点的估计代价
+	f       int    // 出堆的优先级：A*和JPS为g+h，Dijkstra为g，最佳优先搜索为h
+	parent  *Node  // 父节点
+	fromDir [2]int // 从父节点到当前节点的方向，JPS使用
 }
 
 type PathFindResult struct {
@@ -20,38 +19,15 @@ type PathFindResult struct {
 	Cost       int
 	Check      int
 	StepRecord MazeStepRecord
+	Found      bool         // 是否到达终点，没有到达时Path为空（FindPathWithOptions可以改为到最近可达格子的路径）
+	Partial    bool         // Path是到离终点最近的可达格子的路径，只在没有到达终点时出现
+	Err        error        // 起点或终点越界、不可通行，此时不会搜索
+	Waypoints  [][2]float64 // 任意角度路径的拐点，格子(r,c)的中心为(r+0.5,c+0.5)；只有Theta*和平滑后的结果有
 }
 
-// PriorityQueue 实现堆接口
-type PriorityQueue []*Node
-
-func (pq PriorityQueue) Len() int { return len(pq) }
-
-func (pq PriorityQueue) Less(i, j int) bool {
-	return pq[i].f < pq[j].f
-}
-
-func (pq PriorityQueue) Swap(i, j int) {
-	pq[i], pq[j] = pq[j], pq[i]
-	pq[i].index = i
-	pq[j].index = j
-}
-
-func (pq *PriorityQueue) Push(x interface{}) {
-	n := len(*pq)
-	node := x.(*Node)
-	node.index = n
-	*pq = append(*pq, node)
-}
-
-func (pq *PriorityQueue) Pop() interface{} {
-	old := *pq
-	n := len(old)
-	node := old[n-1]
-	old[n-1] = nil  // 避免内存泄漏
-	node.index = -1 // 标记为已移除
-	*pq = old[0 : n-1]
-	return node
+// 按f从小到大出堆的优先队列
+func newNodeQueue() *Heap[*Node] {
+	return NewHeap(func(a, b *Node) bool { return a.f < b.f })
 }
 
 // 计算曼哈顿距离
@@ -61,9 +37,12 @@ func manhattanDistance(a, b [2]int) int {
 
 // FindPathAStar 使用A*算法寻找从start到end的路径
 func FindPathAStar(maze [][]int, start, end [2]int) PathFindResult {
+	if err := validateEnds(maze, start, end); err != nil {
+		return PathFindResult{Path: make([][2]int, 0), Err: err}
+	}
+
 	// 初始化开放列表和关闭列表
-	openList := &PriorityQueue{}
-	heap.Init(openList)
+	openList := newNodeQueue()
 	closedSet := make(map[[2]int]bool)
 
 	// 创建起点节点
@@ -74,7 +53,7 @@ func FindPathAStar(maze [][]int, start, end [2]int) PathFindResult {
 	}
 	startNode.f = startNode.g + startNode.h
 
-	heap.Push(openList, startNode)
+	openList.Push(startNode)
 
 	// 定义方向：上、右、下、左
 	dirs := [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}}
@@ -88,7 +67,7 @@ func FindPathAStar(maze [][]int, start, end [2]int) PathFindResult {
 
 	// 主循环
 	for openList.Len() > 0 {
-		current = heap.Pop(openList).(*Node)
+		current = openList.Pop()
 		// 记录这一步
 		res.StepRecord.Steps = append(res.StepRecord.Steps, MazeStep{
 			Pos:  current.pos,
@@ -135,7 +114,7 @@ func FindPathAStar(maze [][]int, start, end [2]int) PathFindResult {
 			neighbor.f = neighbor.g + neighbor.h
 			res.Cost++
 			// 添加到开放列表
-			heap.Push(openList, neighbor)
+			openList.Push(neighbor)
 			res.StepRecord.Steps = append(res.StepRecord.Steps, MazeStep{
 				Pos:  neighbor.pos,
 				Type: "push", // 标记为已检查
@@ -144,8 +123,12 @@ func FindPathAStar(maze [][]int, start, end [2]int) PathFindResult {
 		}
 	}
 
-	// 重建路径
+	// 重建路径，没有到达终点时路径为空
 	res.Path = make([][2]int, 0)
+	if current == nil || current.pos != end {
+		return res
+	}
+	res.Found = true
 	for node := current; node != nil; node = node.parent {
 		res.Path = append([][2]int{node.pos}, res.Path...)
 	}
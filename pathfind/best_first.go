@@ -1,73 +1,33 @@
 package pathfind
 
-import (
-	"container/heap"
-)
-
-// BNode 表示最佳优先搜索中的一个节点
-type BNode struct {
-	pos    [2]int // 位置
-	h      int    // 启发式值（到目标的估计距离）
-	parent *BNode // 父节点
-	index  int    // 在优先队列中的索引
-}
-
-// BPriorityQueue 实现堆接口
-type BPriorityQueue []*BNode
-
-func (pq BPriorityQueue) Len() int { return len(pq) }
-
-func (pq BPriorityQueue) Less(i, j int) bool {
-	return pq[i].h < pq[j].h
-}
-
-func (pq BPriorityQueue) Swap(i, j int) {
-	pq[i], pq[j] = pq[j], pq[i]
-	pq[i].index = i
-	pq[j].index = j
-}
-
-func (pq *BPriorityQueue) Push(x interface{}) {
-	n := len(*pq)
-	node := x.(*BNode)
-	node.index = n
-	*pq = append(*pq, node)
-}
-
-func (pq *BPriorityQueue) Pop() interface{} {
-	old := *pq
-	n := len(old)
-	node := old[n-1]
-	old[n-1] = nil  // 避免内存泄漏
-	node.index = -1 // 标记为已移除
-	*pq = old[0 : n-1]
-	return node
-}
-
 // FindPathBestFirst 使用最佳优先搜索算法寻找从start到end的路径
 func FindPathBestFirst(maze [][]int, start, end [2]int) PathFindResult {
+	if err := validateEnds(maze, start, end); err != nil {
+		return PathFindResult{Path: make([][2]int, 0), Err: err}
+	}
+
 	// 初始化优先队列和访��集合
-	openList := &BPriorityQueue{}
-	heap.Init(openList)
+	openList := newNodeQueue()
 	visited := make(map[[2]int]bool)
 
 	// 创建起点节点
-	startNode := &BNode{
+	startNode := &Node{
 		pos: start,
 		h:   manhattanDistance(start, end),
 	}
+	startNode.f = startNode.h
 
-	heap.Push(openList, startNode)
+	openList.Push(startNode)
 
 	// 定义方向：上、右、下、左
 	dirs := [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}}
 
-	var current *BNode
+	var current *Node
 	var res PathFindResult
 
 	// 主循环
 	for openList.Len() > 0 {
-		current = heap.Pop(openList).(*BNode)
+		current = openList.Pop()
 		res.StepRecord.Steps = append(res.StepRecord.Steps, MazeStep{
 			Pos:  current.pos,
 			Type: "pop", // 标记为已检查
@@ -101,20 +61,25 @@ func FindPathBestFirst(maze [][]int, start, end [2]int) PathFindResult {
 			}
 
 			// 创建新节点
-			neighbor := &BNode{
+			neighbor := &Node{
 				pos:    nextPos,
 				h:      manhattanDistance(nextPos, end),
 				parent: current,
 			}
+			neighbor.f = neighbor.h
 			res.Cost++
 
 			// 添加到优先队列
-			heap.Push(openList, neighbor)
+			openList.Push(neighbor)
 		}
 	}
 
-	// 重建路径
+	// 重建路径，没有到达终点时路径为空
 	res.Path = make([][2]int, 0)
+	if current == nil || current.pos != end {
+		return res
+	}
+	res.Found = true
 	for node := current; node != nil; node = node.parent {
 		res.Path = append([][2]int{node.pos}, res.Path...)
 	}
@@ -0,0 +1,143 @@
+package pathfind
+
+import (
+	"math"
+)
+
+// 双向搜索记录的步骤类型，正向搜索沿用pop和push
+const (
+	StepPopBack  = "pop-back"  // 反向搜索出堆
+	StepPushBack = "push-back" // 反向搜索入堆
+	StepMeet     = "meet"      // 两个方向相遇的格子
+)
+
+// FindPathBiDijkstra 双向Dijkstra：从起点和终点同时搜索，每次扩展堆顶更小的一侧
+func FindPathBiDijkstra(maze [][]int, start, end [2]int) PathFindResult {
+	return findPathBidirectional(maze, start, end, false)
+}
+
+// FindPathBiAStar 双向A*，使用平均势函数 pF(v) = (h(v,end) - h(start,v)) / 2、pB = -pF，
+// 两个方向的约化边权都非负，终止条件与双向Dijkstra相同，结果是最短路径
+func FindPathBiAStar(maze [][]int, start, end [2]int) PathFindResult {
+	return findPathBidirectional(maze, start, end, true)
+}
+
+// 一个方向的搜索状态
+type biSearch struct {
+	open   *Heap[*Node]
+	g      map[[2]int]int    // 目前找到的最短距离
+	parent map[[2]int][2]int // 该方向上的前驱
+	closed map[[2]int]bool
+	pop    string // 记录步骤用的类型
+	push   string
+}
+
+func newBiSearch(pos [2]int, key int, pop, push string) *biSearch {
+	s := &biSearch{
+		open:   newNodeQueue(),
+		g:      map[[2]int]int{pos: 0},
+		parent: make(map[[2]int][2]int),
+		closed: make(map[[2]int]bool),
+		pop:    pop,
+		push:   push,
+	}
+	s.open.Push(&Node{pos: pos, f: key})
+	return s
+}
+
+// 跳过已经关闭的过期节点后的堆顶，堆为空时返回nil
+func (s *biSearch) top() *Node {
+	for s.open.Len() > 0 {
+		if n := s.open.Peek(); !s.closed[n.pos] {
+			return n
+		}
+		s.open.Pop()
+	}
+	return nil
+}
+
+func findPathBidirectional(maze [][]int, start, end [2]int, useHeuristic bool) PathFindResult {
+	if err := validateEnds(maze, start, end); err != nil {
+		return PathFindResult{Path: make([][2]int, 0), Err: err}
+	}
+
+	// 为了避免出现半整数，键值都乘以2：正向 2g + pot(v)，反向 2g - pot(v)
+	pot := func(pos [2]int) int {
+		if !useHeuristic {
+			return 0
+		}
+		return manhattanDistance(pos, end) - manhattanDistance(start, pos)
+	}
+
+	var res PathFindResult
+	forward := newBiSearch(start, pot(start), "pop", "push")
+	backward := newBiSearch(end, -pot(end), StepPopBack, StepPushBack)
+
+	// 目前找到的最短路径长度和相遇点
+	best, meet := math.MaxInt, [2]int{-1, -1}
+	if start == end {
+		best, meet = 0, start
+	}
+
+	dirs := [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}}
+	for {
+		topF, topB := forward.top(), backward.top()
+		if topF == nil || topB == nil {
+			break
+		}
+		// 两侧堆顶之和不小于已知最短路径时，不可能再找到更短的路径
+		if best != math.MaxInt && topF.f+topB.f >= 2*best {
+			break
+		}
+
+		cur, other, sign := forward, backward, 1
+		if topB.f < topF.f {
+			cur, other, sign = backward, forward, -1
+		}
+		node := cur.open.Pop()
+		cur.closed[node.pos] = true
+		res.StepRecord.Steps = append(res.StepRecord.Steps, MazeStep{Pos: node.pos, Type: cur.pop})
+
+		for _, dir := range dirs {
+			res.Check++
+			next := [2]int{node.pos[0] + dir[0], node.pos[1] + dir[1]}
+			if !isWalkable(maze, next) || cur.closed[next] {
+				continue
+			}
+			g := cur.g[node.pos] + 1
+			if old, ok := cur.g[next]; ok && old <= g {
+				continue
+			}
+			cur.g[next] = g
+			cur.parent[next] = node.pos
+			res.Cost++
+			cur.open.Push(&Node{pos: next, g: g, f: 2*g + sign*pot(next)})
+			res.StepRecord.Steps = append(res.StepRecord.Steps, MazeStep{Pos: next, Type: cur.push})
+
+			// 另一侧也到达过这个格子，得到一条经过它的路径
+			if og, ok := other.g[next]; ok && g+og < best {
+				best, meet = g+og, next
+			}
+		}
+	}
+
+	res.Path = make([][2]int, 0)
+	if best == math.MaxInt {
+		return res
+	}
+	res.Found = true
+	res.StepRecord.Steps = append(res.StepRecord.Steps, MazeStep{Pos: meet, Type: StepMeet})
+
+	// 相遇点向起点回溯，再向终点回溯
+	for pos := meet; ; pos = forward.parent[pos] {
+		res.Path = append([][2]int{pos}, res.Path...)
+		if pos == start {
+			break
+		}
+	}
+	for pos := meet; pos != end; {
+		pos = backward.parent[pos]
+		res.Path = append(res.Path, pos)
+	}
+	return res
+}
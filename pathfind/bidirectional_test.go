@@ -0,0 +1,110 @@
+package pathfind
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// 随机的完美迷宫：偶数坐标为格子，任意两点之间只有一条路径，路径又长又曲折
+func perfectMaze(rng *rand.Rand, rows, cols int) [][]int {
+	maze := make([][]int, 2*rows-1)
+	for r := range maze {
+		maze[r] = make([]int, 2*cols-1)
+		for c := range maze[r] {
+			maze[r][c] = 1
+		}
+	}
+	visited := make([][]bool, rows)
+	for r := range visited {
+		visited[r] = make([]bool, cols)
+	}
+	stack := [][2]int{{0, 0}}
+	visited[0][0] = true
+	maze[0][0] = 0
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		var next [][2]int
+		for _, d := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+			n := [2]int{cur[0] + d[0], cur[1] + d[1]}
+			if n[0] >= 0 && n[0] < rows && n[1] >= 0 && n[1] < cols && !visited[n[0]][n[1]] {
+				next = append(next, n)
+			}
+		}
+		if len(next) == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		n := next[rng.Intn(len(next))]
+		visited[n[0]][n[1]] = true
+		maze[2*n[0]][2*n[1]] = 0
+		maze[cur[0]+n[0]][cur[1]+n[1]] = 0
+		stack = append(stack, n)
+	}
+	return maze
+}
+
+// 双向搜索的终止条件和相遇点：与Dijkstra的最短路径长度相同，找到时恰好记录一个相遇点并且在路径上
+func TestBidirectionalOptimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(43))
+	algos := map[string]PathfinderFunc{"bidijkstra": FindPathBiDijkstra, "biastar": FindPathBiAStar}
+	for i := 0; i < 600; i++ {
+		var maze [][]int
+		switch i % 3 {
+		case 0:
+			maze = randomGrid(rng, 2+rng.Intn(40), 2+rng.Intn(40), rng.Float64()*0.45)
+		case 1:
+			maze = randomGrid(rng, 1+rng.Intn(30), 1+rng.Intn(30), 0) // 开阔地图，相同长度的路径很多
+		default:
+			maze = perfectMaze(rng, 1+rng.Intn(20), 1+rng.Intn(20))
+		}
+		start := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+		end := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+		maze[start[0]][start[1]], maze[end[0]][end[1]] = 0, 0
+
+		for name, find := range algos {
+			res := find(maze, start, end)
+			checkAgainstDijkstra(t, name, maze, start, end, res)
+
+			meets := 0
+			for _, step := range res.StepRecord.Steps {
+				if step.Type == StepMeet {
+					meets++
+					if !containsCell(res.Path, step.Pos) {
+						t.Fatalf("%s %v->%v: meeting point %v not on path %v", name, start, end, step.Pos, res.Path)
+					}
+				}
+			}
+			if res.Found && meets != 1 || !res.Found && meets != 0 {
+				t.Fatalf("%s %v->%v: found=%v with %d meeting points", name, start, end, res.Found, meets)
+			}
+		}
+	}
+}
+
+// 双向A*的势函数使两侧都朝对方收缩，开阔地图上扩展的格子不应该比双向Dijkstra多
+func TestBiAStarExpandsLess(t *testing.T) {
+	maze := randomGrid(rand.New(rand.NewSource(1)), 60, 60, 0)
+	start, end := [2]int{5, 5}, [2]int{50, 55}
+	count := func(res PathFindResult) int {
+		n := 0
+		for _, step := range res.StepRecord.Steps {
+			if step.Type == "pop" || step.Type == StepPopBack {
+				n++
+			}
+		}
+		return n
+	}
+	a, d := count(FindPathBiAStar(maze, start, end)), count(FindPathBiDijkstra(maze, start, end))
+	if a >= d {
+		t.Fatalf("bidirectional A* expanded %d cells, bidirectional Dijkstra %d", a, d)
+	}
+}
+
+func containsCell(path [][2]int, pos [2]int) bool {
+	for _, p := range path {
+		if p == pos {
+			return true
+		}
+	}
+	return false
+}
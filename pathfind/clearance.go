@@ -0,0 +1,72 @@
+package pathfind
+
+// 体型大于1格的智能体寻路
+// 大小为k的智能体占据以所在位置为左上角的k*k个格子，位置的间隙值（clearance）
+// 是以它为左上角、全部可以通行的最大正方形的边长，间隙值不小于k的格子它才能站上去。
+// 把间隙值小于k的格子当成墙得到一张新地图，任何算法在新地图上寻路即可
+
+// ClearanceMap 每个格子的间隙值，墙为0，从右下角往左上角递推：
+// c(r,c) = 1 + min(c(r+1,c), c(r,c+1), c(r+1,c+1))
+func ClearanceMap(maze [][]int) [][]int {
+	height := len(maze)
+	if height == 0 {
+		return make([][]int, 0)
+	}
+	width := len(maze[0])
+	clearance := make([][]int, height)
+	for r := range clearance {
+		clearance[r] = make([]int, width)
+	}
+	at := func(r, c int) int {
+		if r >= height || c >= width {
+			return 0
+		}
+		return clearance[r][c]
+	}
+	for r := height - 1; r >= 0; r-- {
+		for c := width - 1; c >= 0; c-- {
+			if maze[r][c] != 0 {
+				continue
+			}
+			clearance[r][c] = 1 + min(at(r+1, c), at(r, c+1), at(r+1, c+1))
+		}
+	}
+	return clearance
+}
+
+// ClearanceMaze 大小为size的智能体可以站的格子为0，其他为1；size<=1时返回maze本身
+func ClearanceMaze(maze [][]int, size int) [][]int {
+	if size <= 1 {
+		return maze
+	}
+	clearance := ClearanceMap(maze)
+	grid := make([][]int, len(maze))
+	for r := range clearance {
+		grid[r] = make([]int, len(clearance[r]))
+		for c, v := range clearance[r] {
+			if v < size {
+				grid[r][c] = 1
+			}
+		}
+	}
+	return grid
+}
+
+// Footprint 大小为size的智能体沿path走过时占据的所有格子，按第一次经过的顺序
+func Footprint(path [][2]int, size int) [][2]int {
+	size = max(size, 1)
+	seen := make(map[[2]int]bool)
+	cells := make([][2]int, 0, len(path))
+	for _, pos := range path {
+		for dr := 0; dr < size; dr++ {
+			for dc := 0; dc < size; dc++ {
+				cell := [2]int{pos[0] + dr, pos[1] + dc}
+				if !seen[cell] {
+					seen[cell] = true
+					cells = append(cells, cell)
+				}
+			}
+		}
+	}
+	return cells
+}
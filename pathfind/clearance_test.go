@@ -0,0 +1,90 @@
+package pathfind
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// 按定义逐个检查以(r,c)为左上角的正方形
+func bruteClearance(maze [][]int, r, c int) int {
+	k := 0
+	for {
+		for i := 0; i <= k; i++ {
+			for j := 0; j <= k; j++ {
+				if !isWalkable(maze, [2]int{r + i, c + j}) {
+					return k
+				}
+			}
+		}
+		k++
+	}
+}
+
+func TestClearanceMap(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for i := 0; i < 50; i++ {
+		maze := randomGrid(rng, 1+rng.Intn(15), 1+rng.Intn(15), rng.Float64()*0.4)
+		clearance := ClearanceMap(maze)
+		for r := range maze {
+			for c := range maze[r] {
+				if want := bruteClearance(maze, r, c); clearance[r][c] != want {
+					t.Fatalf("clearance(%d,%d) = %d, want %d\n%v", r, c, clearance[r][c], want, maze)
+				}
+			}
+		}
+	}
+}
+
+// 所有注册的算法按体型寻路时，智能体占据的格子都不能是墙，能否到达与在ClearanceMaze上用Dijkstra一致
+func TestAgentSizeFootprint(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	for _, algo := range Algorithms() {
+		for i := 0; i < 100; i++ {
+			maze := randomGrid(rng, 4+rng.Intn(20), 4+rng.Intn(20), rng.Float64()*0.25)
+			size := 2 + rng.Intn(2)
+			grid := ClearanceMaze(maze, size)
+			start := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+			end := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+			res := FindPathWithOptions(algo.New(), maze, start, end, PathOptions{AgentSize: size})
+			if validateEnds(grid, start, end) != nil {
+				if res.Found || res.Err == nil {
+					t.Fatalf("%s size %d: ends %v %v don't fit but found=%v err=%v", algo.Name, size, start, end, res.Found, res.Err)
+				}
+				continue
+			}
+			checkAgainstDijkstra(t, algo.Name, grid, start, end, res)
+			for _, cell := range Footprint(res.Path, size) {
+				if !isWalkable(maze, cell) {
+					t.Fatalf("%s size %d: footprint cell %v is a wall, path %v", algo.Name, size, cell, res.Path)
+				}
+			}
+		}
+	}
+}
+
+// 算法给出穿墙的路径时FindPathWithOptions按没有找到处理
+func TestFindPathWithOptionsRejectsInvalidPath(t *testing.T) {
+	maze := parseGrid(
+		"...",
+		"##.",
+		"...")
+	bad := PathfinderFunc(func(maze [][]int, start, end [2]int) PathFindResult {
+		return PathFindResult{Path: [][2]int{{0, 0}, {1, 0}, {2, 0}}, Found: true}
+	})
+	res := FindPathWithOptions(bad, maze, [2]int{0, 0}, [2]int{2, 0}, PathOptions{})
+	if res.Found || res.Err != ErrInvalidPath || len(res.Path) != 0 {
+		t.Fatalf("wall-crossing path accepted: %+v", res)
+	}
+
+	// 体型为2时(1,1)放不下，同样的路径在原地图上合法也不行
+	open := parseGrid(
+		"...",
+		"...",
+		"..#")
+	step := PathfinderFunc(func(maze [][]int, start, end [2]int) PathFindResult {
+		return PathFindResult{Path: [][2]int{{0, 0}, {0, 1}, {1, 1}}, Found: true}
+	})
+	if res := FindPathWithOptions(step, open, [2]int{0, 0}, [2]int{1, 1}, PathOptions{AgentSize: 2}); res.Found {
+		t.Fatalf("footprint overlapping a wall accepted: %+v", res)
+	}
+}
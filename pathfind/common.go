@@ -1,5 +1,10 @@
 package pathfind
 
+import (
+	"errors"
+	"fmt"
+)
+
 type MazeStep struct {
 	Pos  [2]int // 当前检查的位置
 	Type string // 0:普通 1:墙 2:已检查 3:起点 4:终点 5:当前路径
@@ -9,3 +14,103 @@ type MazeStep struct {
 type MazeStepRecord struct {
 	Steps []MazeStep
 }
+
+var (
+	ErrOutOfBounds = errors.New("position out of bounds")   // 起点或终点在地图外
+	ErrBlocked     = errors.New("position is not walkable") // 起点或终点是墙
+	ErrInvalidPath = errors.New("path is not walkable")     // 算法给出的路径穿墙或者不连续
+)
+
+// 检查起点和终点都在地图内并且可以通行
+func validateEnds(maze [][]int, start, end [2]int) error {
+	if err := validatePos(maze, "start", start); err != nil {
+		return err
+	}
+	return validatePos(maze, "end", end)
+}
+
+func validatePos(maze [][]int, name string, pos [2]int) error {
+	if len(maze) == 0 || pos[0] < 0 || pos[0] >= len(maze) || pos[1] < 0 || pos[1] >= len(maze[0]) {
+		return fmt.Errorf("%s %v: %w", name, pos, ErrOutOfBounds)
+	}
+	if !isWalkable(maze, pos) {
+		return fmt.Errorf("%s %v: %w", name, pos, ErrBlocked)
+	}
+	return nil
+}
+
+// 路径上每个格子都可以通行，相邻两个格子4连通
+func validPath(maze [][]int, path [][2]int) bool {
+	for i, pos := range path {
+		if !isWalkable(maze, pos) {
+			return false
+		}
+		if i > 0 && manhattanDistance(path[i-1], pos) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// PathOptions FindPathWithOptions的选项
+type PathOptions struct {
+	Closest bool // 终点不可达时返回到离终点最近（曼哈顿距离）的可达格子的路径，Partial为true
+	Smooth  bool // 对路径做平滑后处理（SmoothResult），Waypoints为平滑后的拐点
+
+	// 智能体的大小，大于1时在ClearanceMaze(maze, AgentSize)上寻路，路径上的位置是智能体的左上角；
+	// 每次都会生成新地图，需要预处理的算法也会重新预处理
+	AgentSize int
+}
+
+// FindPathWithOptions 用p寻路，并按选项处理终点不可达的情况；返回前检查路径，不合法时Err为ErrInvalidPath
+func FindPathWithOptions(p Pathfinder, maze [][]int, start, end [2]int, opts PathOptions) PathFindResult {
+	maze = ClearanceMaze(maze, opts.AgentSize)
+	res := p.FindPath(maze, start, end)
+	if !res.Found && res.Err == nil && opts.Closest {
+		res.Path = ClosestReachablePath(maze, start, end)
+		res.Partial = true
+	}
+	if opts.Smooth {
+		res = SmoothResult(maze, res)
+	}
+	// 不相信算法的结果：路径必须在（体型对应的）地图上逐步相邻、可以通行，否则按没有找到处理
+	if len(res.Path) > 0 && (!validPath(maze, res.Path) || res.Path[0] != start || res.Found && res.Path[len(res.Path)-1] != end) {
+		res.Path, res.Waypoints = make([][2]int, 0), nil
+		res.Found, res.Partial, res.Err = false, false, ErrInvalidPath
+	}
+	return res
+}
+
+// ClosestReachablePath 从start出发BFS，返回到离end曼哈顿距离最近的可达格子的最短路径，
+// 距离相同时取路径更短的格子；start不可通行时返回空路径
+func ClosestReachablePath(maze [][]int, start, end [2]int) [][2]int {
+	if !isWalkable(maze, start) {
+		return make([][2]int, 0)
+	}
+	parent := map[[2]int][2]int{start: start}
+	queue := [][2]int{start}
+	best := start
+	dirs := [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}}
+	for head := 0; head < len(queue); head++ {
+		cur := queue[head]
+		// BFS按距离顺序出队，只有严格更近时才替换，保证距离相同时路径最短
+		if manhattanDistance(cur, end) < manhattanDistance(best, end) {
+			best = cur
+		}
+		for _, dir := range dirs {
+			next := [2]int{cur[0] + dir[0], cur[1] + dir[1]}
+			if _, ok := parent[next]; ok || !isWalkable(maze, next) {
+				continue
+			}
+			parent[next] = cur
+			queue = append(queue, next)
+		}
+	}
+
+	path := [][2]int{best}
+	for pos := best; pos != start; {
+		pos = parent[pos]
+		path = append([][2]int{pos}, path...)
+	}
+	return path
+}
@@ -1,74 +1,33 @@
 package pathfind
 
-import (
-	"container/heap"
-)
-
-// DNode 表示Dijkstra搜索中的一个节点
-type DNode struct {
-	pos    [2]int // 位置
-	dist   int    // 从起点到当前点的距离
-	parent *DNode // 父节点
-	index  int    // 在优先队列中的索引
-}
-
-// DPriorityQueue 实现堆接口
-type DPriorityQueue []*DNode
-
-func (pq DPriorityQueue) Len() int { return len(pq) }
-
-func (pq DPriorityQueue) Less(i, j int) bool {
-	return pq[i].dist < pq[j].dist
-}
-
-func (pq DPriorityQueue) Swap(i, j int) {
-	pq[i], pq[j] = pq[j], pq[i]
-	pq[i].index = i
-	pq[j].index = j
-}
-
-func (pq *DPriorityQueue) Push(x interface{}) {
-	n := len(*pq)
-	node := x.(*DNode)
-	node.index = n
-	*pq = append(*pq, node)
-}
-
-func (pq *DPriorityQueue) Pop() interface{} {
-	old := *pq
-	n := len(old)
-	node := old[n-1]
-	old[n-1] = nil  // 避免内存泄漏
-	node.index = -1 // 标记为已移除
-	*pq = old[0 : n-1]
-	return node
-}
-
 // FindPathDijkstra 使用Dijkstra算法寻找从start到end的路径
 func FindPathDijkstra(maze [][]int, start, end [2]int) PathFindResult {
+	if err := validateEnds(maze, start, end); err != nil {
+		return PathFindResult{Path: make([][2]int, 0), Err: err}
+	}
+
 	// 初始化优先队列和访问集合
-	openList := &DPriorityQueue{}
-	heap.Init(openList)
+	openList := newNodeQueue()
 	visited := make(map[[2]int]bool)
 
 	// 创建起点节点
-	startNode := &DNode{
-		pos:  start,
-		dist: 0,
+	startNode := &Node{
+		pos: start,
+		g:   0,
 	}
 
-	heap.Push(openList, startNode)
+	openList.Push(startNode)
 
 	// 定义方向：上、右、下、左
 	dirs := [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}}
 
-	var current *DNode
+	var current *Node
 	var res PathFindResult
 
 	// 主循环
 	for openList.Len() > 0 {
 
-		current = heap.Pop(openList).(*DNode)
+		current = openList.Pop()
 		res.StepRecord.Steps = append(res.StepRecord.Steps, MazeStep{
 			Pos:  current.pos,
 			Type: "pop", // 标记为已检查
@@ -102,20 +61,25 @@ func FindPathDijkstra(maze [][]int, start, end [2]int) PathFindResult {
 			}
 
 			// 创建新节点
-			neighbor := &DNode{
+			neighbor := &Node{
 				pos:    nextPos,
-				dist:   current.dist + 1,
+				g:      current.g + 1,
 				parent: current,
 			}
+			neighbor.f = neighbor.g
 			res.Cost++
 
 			// 添加到优先队列
-			heap.Push(openList, neighbor)
+			openList.Push(neighbor)
 		}
 	}
 
-	// 重建路径
+	// 重建路径，没有到达终点时路径为空
 	res.Path = make([][2]int, 0)
+	if current == nil || current.pos != end {
+		return res
+	}
+	res.Found = true
 	for node := current; node != nil; node = node.parent {
 		res.Path = append([][2]int{node.pos}, res.Path...)
 	}
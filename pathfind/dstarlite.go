@@ -0,0 +1,251 @@
+package pathfind
+
+import (
+	"math"
+)
+
+// D* Lite：增量重规划
+// 从终点向起点反向搜索，保存每个格子的g值和rhs值（由邻居算出的一步前瞻值）。
+// 地图变化时只更新受影响格子的rhs，再把不一致（g!=rhs）的格子重新放回堆中修复；
+// 机器人移动后起点改变，用km累加启发值的变化，堆中已有的键不需要重新计算
+
+const dstarInf = math.MaxInt / 2
+
+// 堆中的键，按(k1,k2)字典序比较
+type dstarKey [2]int
+
+func (a dstarKey) less(b dstarKey) bool {
+	return a[0] < b[0] || a[0] == b[0] && a[1] < b[1]
+}
+
+type dstarEntry struct {
+	pos [2]int
+	key dstarKey
+}
+
+// DStarLite 保存搜索状态的增量寻路器，maze是它对地图的认知，通过SetCell修改
+type DStarLite struct {
+	maze        [][]int
+	start, goal [2]int
+	last        [2]int // 上一次计算km时的起点
+	km          int
+	g, rhs      map[[2]int]int
+	open        *Heap[dstarEntry]
+	inOpen      map[[2]int]dstarKey // 堆中每个格子当前有效的键，过期的条目出堆时跳过
+	err         error
+
+	// 上一次Plan之后累计的统计，和PathFindResult中的含义相同
+	cost, check int
+	steps       []MazeStep
+}
+
+// NewDStarLite 在maze上从start到goal规划，maze会被SetCell修改，需要保留原图时传入副本
+func NewDStarLite(maze [][]int, start, goal [2]int) *DStarLite {
+	d := &DStarLite{
+		maze:   maze,
+		start:  start,
+		goal:   goal,
+		last:   start,
+		g:      make(map[[2]int]int),
+		rhs:    make(map[[2]int]int),
+		open:   NewHeap(func(a, b dstarEntry) bool { return a.key.less(b.key) }),
+		inOpen: make(map[[2]int]dstarKey),
+		err:    validateEnds(maze, start, goal),
+	}
+	if d.err == nil {
+		d.rhs[goal] = 0
+		d.push(goal)
+	}
+	return d
+}
+
+func (d *DStarLite) getG(pos [2]int) int {
+	if v, ok := d.g[pos]; ok {
+		return v
+	}
+	return dstarInf
+}
+
+func (d *DStarLite) getRHS(pos [2]int) int {
+	if v, ok := d.rhs[pos]; ok {
+		return v
+	}
+	return dstarInf
+}
+
+func (d *DStarLite) key(pos [2]int) dstarKey {
+	m := min(d.getG(pos), d.getRHS(pos))
+	if m >= dstarInf {
+		return dstarKey{dstarInf, dstarInf}
+	}
+	return dstarKey{m + manhattanDistance(d.start, pos) + d.km, m}
+}
+
+func (d *DStarLite) push(pos [2]int) {
+	k := d.key(pos)
+	d.inOpen[pos] = k
+	d.open.Push(dstarEntry{pos, k})
+	d.cost++
+	d.steps = append(d.steps, MazeStep{Pos: pos, Type: "push"})
+}
+
+// 跳过过期条目后的堆顶
+func (d *DStarLite) top() (dstarEntry, bool) {
+	for d.open.Len() > 0 {
+		e := d.open.Peek()
+		if k, ok := d.inOpen[e.pos]; ok && k == e.key {
+			return e, true
+		}
+		d.open.Pop()
+	}
+	return dstarEntry{}, false
+}
+
+// 走进一个格子的代价，墙为无穷大
+func (d *DStarLite) edgeCost(a, b [2]int) int {
+	if !isWalkable(d.maze, a) || !isWalkable(d.maze, b) {
+		return dstarInf
+	}
+	return 1
+}
+
+func (d *DStarLite) neighbors(pos [2]int) [][2]int {
+	neighbors := make([][2]int, 0, 4)
+	for _, dir := range [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}} {
+		next := [2]int{pos[0] + dir[0], pos[1] + dir[1]}
+		if next[0] >= 0 && next[0] < len(d.maze) && next[1] >= 0 && next[1] < len(d.maze[0]) {
+			neighbors = append(neighbors, next)
+		}
+	}
+	return neighbors
+}
+
+// 重新计算rhs，g和rhs不一致时放回堆中
+func (d *DStarLite) updateVertex(pos [2]int) {
+	if pos != d.goal {
+		best := dstarInf
+		for _, next := range d.neighbors(pos) {
+			d.check++
+			if c, g := d.edgeCost(pos, next), d.getG(next); c < dstarInf && g < dstarInf {
+				best = min(best, c+g)
+			}
+		}
+		d.rhs[pos] = best
+	}
+	delete(d.inOpen, pos)
+	if d.getG(pos) != d.getRHS(pos) {
+		d.push(pos)
+	}
+}
+
+func (d *DStarLite) computeShortestPath() {
+	for {
+		e, ok := d.top()
+		if !ok {
+			return
+		}
+		if !e.key.less(d.key(d.start)) && d.getRHS(d.start) == d.getG(d.start) {
+			return
+		}
+		d.open.Pop()
+		u := e.pos
+		if newKey := d.key(u); e.key.less(newKey) {
+			// km变化后键变大了，按新键重新入堆
+			d.inOpen[u] = newKey
+			d.open.Push(dstarEntry{u, newKey})
+			continue
+		}
+		delete(d.inOpen, u)
+		d.steps = append(d.steps, MazeStep{Pos: u, Type: "pop"})
+		if d.getG(u) > d.getRHS(u) {
+			d.g[u] = d.getRHS(u)
+		} else {
+			d.g[u] = dstarInf
+			d.updateVertex(u)
+		}
+		for _, next := range d.neighbors(u) {
+			d.updateVertex(next)
+		}
+	}
+}
+
+// SetCell 修改一个格子（0可通行，其他为墙），只更新这个格子和它的邻居，下一次Plan时增量修复
+func (d *DStarLite) SetCell(pos [2]int, value int) {
+	if d.err != nil || pos[0] < 0 || pos[0] >= len(d.maze) || pos[1] < 0 || pos[1] >= len(d.maze[0]) {
+		return
+	}
+	if d.maze[pos[0]][pos[1]] == value {
+		return
+	}
+	d.syncStart()
+	d.maze[pos[0]][pos[1]] = value
+	d.updateVertex(pos)
+	for _, next := range d.neighbors(pos) {
+		d.updateVertex(next)
+	}
+}
+
+// 起点移动后累加km，之后入堆的键与堆中已有的键可以直接比较
+func (d *DStarLite) syncStart() {
+	d.km += manhattanDistance(d.last, d.start)
+	d.last = d.start
+}
+
+// Move 机器人移动到pos，之后的路径从pos开始
+func (d *DStarLite) Move(pos [2]int) {
+	d.start = pos
+}
+
+// Start 当前的起点
+func (d *DStarLite) Start() [2]int {
+	return d.start
+}
+
+// Plan 修复搜索状态并返回从当前起点到终点的路径。
+// Cost、Check和StepRecord只统计上一次Plan之后的工作量（包括SetCell），第一次调用相当于一次完整的反向搜索
+func (d *DStarLite) Plan() PathFindResult {
+	// 起点会移动，终点也可能被SetCell改成墙，和重新搜索一样每次都检查
+	err := d.err
+	if err == nil {
+		err = validateEnds(d.maze, d.start, d.goal)
+	}
+	if err != nil {
+		return PathFindResult{Path: make([][2]int, 0), Err: err}
+	}
+	d.syncStart()
+	d.computeShortestPath()
+
+	res := PathFindResult{Cost: d.cost, Check: d.check, Path: make([][2]int, 0)}
+	res.StepRecord.Steps = d.steps
+	d.cost, d.check, d.steps = 0, 0, make([]MazeStep, 0)
+	if d.getG(d.start) >= dstarInf {
+		return res
+	}
+
+	// 沿着c+g最小的邻居走到终点
+	pos := d.start
+	res.Path = append(res.Path, pos)
+	for pos != d.goal && len(res.Path) <= len(d.maze)*len(d.maze[0]) {
+		next, best := pos, dstarInf
+		for _, n := range d.neighbors(pos) {
+			if c := d.edgeCost(pos, n); c < dstarInf && c+d.getG(n) < best {
+				next, best = n, c+d.getG(n)
+			}
+		}
+		if next == pos {
+			return PathFindResult{Cost: res.Cost, Check: res.Check, Path: make([][2]int, 0), StepRecord: res.StepRecord}
+		}
+		pos = next
+		res.Path = append(res.Path, pos)
+	}
+	res.Found = pos == d.goal
+	if !res.Found {
+		res.Path = make([][2]int, 0)
+	}
+	return res
+}
+
+// FindPathDStarLite 一次性的D* Lite寻路，结果和A*一样是最短路径，用于和其他算法比较
+func FindPathDStarLite(maze [][]int, start, end [2]int) PathFindResult {
+	return NewDStarLite(maze, start, end).Plan()
+}
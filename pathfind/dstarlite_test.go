@@ -0,0 +1,81 @@
+package pathfind
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func copyMaze(maze [][]int) [][]int {
+	out := make([][]int, len(maze))
+	for i := range maze {
+		out[i] = append([]int(nil), maze[i]...)
+	}
+	return out
+}
+
+// 随机修改格子、沿路径移动或者跳到别的格子之后重新规划，结果要与在当前地图上从当前起点重新搜索一致
+func TestDStarLiteReplanMatchesFreshSearch(t *testing.T) {
+	rng := rand.New(rand.NewSource(46))
+	for i := 0; i < 200; i++ {
+		maze := randomGrid(rng, 3+rng.Intn(25), 3+rng.Intn(25), rng.Float64()*0.35)
+		start := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+		goal := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+		maze[start[0]][start[1]], maze[goal[0]][goal[1]] = 0, 0
+
+		d := NewDStarLite(copyMaze(maze), start, goal)
+		for step := 0; step < 30; step++ {
+			res := d.Plan()
+			want := FindPathDijkstra(maze, d.Start(), goal)
+			if fmt.Sprint(res.Err) != fmt.Sprint(want.Err) || res.Found != want.Found {
+				t.Fatalf("maze %d step %d %v->%v: found=%v err=%v, fresh found=%v err=%v",
+					i, step, d.Start(), goal, res.Found, res.Err, want.Found, want.Err)
+			}
+			if res.Found && (!validPath(maze, res.Path) || res.Path[0] != d.Start() ||
+				res.Path[len(res.Path)-1] != goal || len(res.Path) != len(want.Path)) {
+				t.Fatalf("maze %d step %d %v->%v: path %v, fresh length %d", i, step, d.Start(), goal, res.Path, len(want.Path))
+			}
+
+			switch op := rng.Intn(4); {
+			case op == 0 && res.Found && len(res.Path) > 1:
+				// 沿规划的路径走几步
+				d.Move(res.Path[min(1+rng.Intn(3), len(res.Path)-1)])
+			case op == 1:
+				pos := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+				if maze[pos[0]][pos[1]] == 0 {
+					d.Move(pos)
+				}
+			default:
+				// 修改几个格子，包括路径上的格子和终点
+				for k := 1 + rng.Intn(4); k > 0; k-- {
+					pos := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+					if k == 1 && res.Found && rng.Intn(2) == 0 {
+						pos = res.Path[rng.Intn(len(res.Path))]
+					}
+					if pos == d.Start() {
+						continue
+					}
+					maze[pos[0]][pos[1]] = 1 - maze[pos[0]][pos[1]]
+					d.SetCell(pos, maze[pos[0]][pos[1]])
+				}
+			}
+		}
+	}
+}
+
+// 重新规划只修复受影响的部分，路径上一个格子变成墙后的工作量应该比重新搜索少
+func TestDStarLiteReplanIsIncremental(t *testing.T) {
+	maze := randomGrid(rand.New(rand.NewSource(2)), 60, 60, 0)
+	start, goal := [2]int{0, 0}, [2]int{59, 59}
+	d := NewDStarLite(copyMaze(maze), start, goal)
+	first := d.Plan()
+
+	d.SetCell(first.Path[len(first.Path)/2], 1)
+	replan := d.Plan()
+	if !replan.Found || len(replan.Path) != len(first.Path) {
+		t.Fatalf("replan found=%v length %d, want %d", replan.Found, len(replan.Path), len(first.Path))
+	}
+	if replan.Cost >= first.Cost {
+		t.Fatalf("replan pushed %d cells, first plan %d", replan.Cost, first.Cost)
+	}
+}
@@ -0,0 +1,145 @@
+package pathfind
+
+import (
+	"errors"
+)
+
+// 流场：大量单位走向同一个（或几个）集结点时，不需要每个单位单独寻路，
+// 从所有目标同时做一次Dijkstra得到积分场（每个格子到最近目标的代价），
+// 再让每个格子指向积分值最小的邻居得到方向场，单位每一步只需要查表
+
+// FlowUnreachable 积分场中不可达格子（包括墙）的值
+const FlowUnreachable = -1
+
+var (
+	ErrNoGoals   = errors.New("flow field needs at least one goal")
+	ErrCostsSize = errors.New("costs size does not match maze")
+)
+
+// FlowField 流场，Cost和Dir都按maze[行][列]索引
+type FlowField struct {
+	Cost [][]int    // 积分场：走到最近目标的代价，不可达为FlowUnreachable
+	Dir  [][][2]int // 方向场：下一步的位移，目标和不可达的格子为{0,0}
+}
+
+// NewFlowField 从goals出发建立流场，每走一步代价为1
+func NewFlowField(maze [][]int, goals [][2]int) (*FlowField, error) {
+	return NewWeightedFlowField(maze, goals, nil)
+}
+
+// NewWeightedFlowField 带权重的流场，走进格子(r,c)的代价为costs[r][c]（小于1时按1计算），costs为nil时都为1
+func NewWeightedFlowField(maze [][]int, goals [][2]int, costs [][]int) (*FlowField, error) {
+	if len(goals) == 0 {
+		return nil, ErrNoGoals
+	}
+	for _, goal := range goals {
+		if err := validatePos(maze, "goal", goal); err != nil {
+			return nil, err
+		}
+	}
+
+	height, width := len(maze), len(maze[0])
+	if costs != nil {
+		if len(costs) != height {
+			return nil, ErrCostsSize
+		}
+		for _, row := range costs {
+			if len(row) != width {
+				return nil, ErrCostsSize
+			}
+		}
+	}
+	ff := &FlowField{Cost: make([][]int, height), Dir: make([][][2]int, height)}
+	for i := range maze {
+		ff.Cost[i] = make([]int, width)
+		for j := range ff.Cost[i] {
+			ff.Cost[i][j] = FlowUnreachable
+		}
+		ff.Dir[i] = make([][2]int, width)
+	}
+
+	stepCost := func(pos [2]int) int {
+		if costs == nil || costs[pos[0]][pos[1]] < 1 {
+			return 1
+		}
+		return costs[pos[0]][pos[1]]
+	}
+
+	// 积分场：从目标反向做多源Dijkstra，单位从next走进cur的代价是cur的代价
+	open := newNodeQueue()
+	for _, goal := range goals {
+		if ff.Cost[goal[0]][goal[1]] != 0 {
+			ff.Cost[goal[0]][goal[1]] = 0
+			open.Push(&Node{pos: goal})
+		}
+	}
+	dirs := [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}}
+	for open.Len() > 0 {
+		cur := open.Pop()
+		if cur.g > ff.Cost[cur.pos[0]][cur.pos[1]] {
+			continue
+		}
+		for _, dir := range dirs {
+			next := [2]int{cur.pos[0] + dir[0], cur.pos[1] + dir[1]}
+			if !isWalkable(maze, next) {
+				continue
+			}
+			g := cur.g + stepCost(cur.pos)
+			if old := ff.Cost[next[0]][next[1]]; old != FlowUnreachable && old <= g {
+				continue
+			}
+			ff.Cost[next[0]][next[1]] = g
+			open.Push(&Node{pos: next, g: g, f: g})
+		}
+	}
+
+	// 方向场：指向走进去的代价加上积分值最小的邻居
+	for r := 0; r < height; r++ {
+		for c := 0; c < width; c++ {
+			if ff.Cost[r][c] <= 0 {
+				continue
+			}
+			best := -1
+			for _, dir := range dirs {
+				next := [2]int{r + dir[0], c + dir[1]}
+				if !isWalkable(maze, next) || ff.Cost[next[0]][next[1]] == FlowUnreachable {
+					continue
+				}
+				if v := ff.Cost[next[0]][next[1]] + stepCost(next); best < 0 || v < best {
+					best, ff.Dir[r][c] = v, dir
+				}
+			}
+		}
+	}
+	return ff, nil
+}
+
+// NextStep 从pos出发的下一步，pos已经是目标、不可达或者越界时返回false
+func (ff *FlowField) NextStep(pos [2]int) ([2]int, bool) {
+	if pos[0] < 0 || pos[0] >= len(ff.Dir) || pos[1] < 0 || pos[1] >= len(ff.Dir[0]) {
+		return pos, false
+	}
+	dir := ff.Dir[pos[0]][pos[1]]
+	if dir == [2]int{0, 0} {
+		return pos, false
+	}
+	return [2]int{pos[0] + dir[0], pos[1] + dir[1]}, true
+}
+
+// Reachable pos是否能走到某个目标
+func (ff *FlowField) Reachable(pos [2]int) bool {
+	return pos[0] >= 0 && pos[0] < len(ff.Cost) && pos[1] >= 0 && pos[1] < len(ff.Cost[0]) &&
+		ff.Cost[pos[0]][pos[1]] != FlowUnreachable
+}
+
+// Path 沿方向场从pos走到最近的目标，不可达时返回空路径
+func (ff *FlowField) Path(pos [2]int) [][2]int {
+	if !ff.Reachable(pos) {
+		return make([][2]int, 0)
+	}
+	path := [][2]int{pos}
+	for next, ok := ff.NextStep(pos); ok; next, ok = ff.NextStep(next) {
+		path = append(path, next)
+	}
+	return path
+}
@@ -0,0 +1,219 @@
+package pathfind
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// 与NewWeightedFlowField独立的参照：对每个目标反复松弛到不再变化（走进格子的代价为costs中的值，小于1按1），
+// 每个格子取所有目标中的最小值
+func bruteForceFlowCost(maze [][]int, goals [][2]int, costs [][]int) [][]int {
+	enter := func(pos [2]int) int {
+		if costs == nil || costs[pos[0]][pos[1]] < 1 {
+			return 1
+		}
+		return costs[pos[0]][pos[1]]
+	}
+	best := make([][]int, len(maze))
+	for r := range best {
+		best[r] = make([]int, len(maze[0]))
+		for c := range best[r] {
+			best[r][c] = FlowUnreachable
+		}
+	}
+	for _, goal := range goals {
+		dist := make([][]int, len(maze))
+		for r := range dist {
+			dist[r] = make([]int, len(maze[0]))
+			for c := range dist[r] {
+				dist[r][c] = FlowUnreachable
+			}
+		}
+		dist[goal[0]][goal[1]] = 0
+		for changed := true; changed; {
+			changed = false
+			for r := range maze {
+				for c := range maze[r] {
+					if maze[r][c] != 0 {
+						continue
+					}
+					for _, dir := range [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}} {
+						next := [2]int{r + dir[0], c + dir[1]}
+						if !isWalkable(maze, next) || dist[next[0]][next[1]] == FlowUnreachable {
+							continue
+						}
+						if d := dist[next[0]][next[1]] + enter(next); dist[r][c] == FlowUnreachable || d < dist[r][c] {
+							dist[r][c], changed = d, true
+						}
+					}
+				}
+			}
+		}
+		for r := range dist {
+			for c, d := range dist[r] {
+				if d != FlowUnreachable && (best[r][c] == FlowUnreachable || d < best[r][c]) {
+					best[r][c] = d
+				}
+			}
+		}
+	}
+	return best
+}
+
+// 积分场与参照相同；可达的格子沿方向场走到一个目标，走过的代价正好等于积分值；不可达的格子没有方向
+func checkFlowField(t *testing.T, maze [][]int, goals [][2]int, costs [][]int, ff *FlowField) {
+	t.Helper()
+	want := bruteForceFlowCost(maze, goals, costs)
+	isGoal := make(map[[2]int]bool)
+	for _, g := range goals {
+		isGoal[g] = true
+	}
+	for r := range maze {
+		for c := range maze[r] {
+			pos := [2]int{r, c}
+			if ff.Cost[r][c] != want[r][c] {
+				t.Fatalf("cost at %v = %d, want %d", pos, ff.Cost[r][c], want[r][c])
+			}
+			if want[r][c] == FlowUnreachable || isGoal[pos] {
+				if _, ok := ff.NextStep(pos); ok || ff.Dir[r][c] != [2]int{0, 0} {
+					t.Fatalf("%v (goal=%v) has direction %v", pos, isGoal[pos], ff.Dir[r][c])
+				}
+				if path := ff.Path(pos); want[r][c] == FlowUnreachable && len(path) != 0 {
+					t.Fatalf("unreachable %v has path %v", pos, path)
+				}
+				continue
+			}
+
+			cost, cur := 0, pos
+			for steps := 0; !isGoal[cur]; steps++ {
+				next, ok := ff.NextStep(cur)
+				if !ok || steps > len(maze)*len(maze[0]) || !validPath(maze, [][2]int{cur, next}) {
+					t.Fatalf("following directions from %v stuck at %v (next %v ok=%v)", pos, cur, next, ok)
+				}
+				cur = next
+				if costs == nil || costs[cur[0]][cur[1]] < 1 {
+					cost++
+				} else {
+					cost += costs[cur[0]][cur[1]]
+				}
+			}
+			if cost != want[r][c] {
+				t.Fatalf("following directions from %v costs %d, want %d", pos, cost, want[r][c])
+			}
+			if path := ff.Path(pos); path[0] != pos || path[len(path)-1] != cur {
+				t.Fatalf("path from %v = %v, want to end at %v", pos, path, cur)
+			}
+		}
+	}
+}
+
+func randomFlowGoals(rng *rand.Rand, maze [][]int) [][2]int {
+	goals := make([][2]int, 1+rng.Intn(4))
+	for i := range goals {
+		goals[i] = [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+		maze[goals[i][0]][goals[i][1]] = 0
+	}
+	return goals
+}
+
+func TestFlowFieldMatchesDijkstra(t *testing.T) {
+	rng := rand.New(rand.NewSource(47))
+	for i := 0; i < 200; i++ {
+		maze := randomGrid(rng, 1+rng.Intn(15), 1+rng.Intn(15), rng.Float64()*0.4)
+		goals := randomFlowGoals(rng, maze)
+		ff, err := NewFlowField(maze, goals)
+		if err != nil {
+			t.Fatalf("maze %d: %v", i, err)
+		}
+		checkFlowField(t, maze, goals, nil, ff)
+
+		// 积分值就是到各个目标的最短路径长度中的最小值
+		for r := range maze {
+			for c := range maze[r] {
+				want := FlowUnreachable
+				for _, goal := range goals {
+					if res := FindPathDijkstra(maze, [2]int{r, c}, goal); res.Found && (want < 0 || len(res.Path)-1 < want) {
+						want = len(res.Path) - 1
+					}
+				}
+				if ff.Cost[r][c] != want {
+					t.Fatalf("maze %d: cost at (%d,%d) = %d, dijkstra %d", i, r, c, ff.Cost[r][c], want)
+				}
+			}
+		}
+	}
+}
+
+func TestWeightedFlowField(t *testing.T) {
+	rng := rand.New(rand.NewSource(470))
+	for i := 0; i < 200; i++ {
+		maze := randomGrid(rng, 1+rng.Intn(12), 1+rng.Intn(12), rng.Float64()*0.3)
+		costs := make([][]int, len(maze))
+		for r := range costs {
+			costs[r] = make([]int, len(maze[0]))
+			for c := range costs[r] {
+				costs[r][c] = rng.Intn(9) - 1 // 包括小于1的值
+			}
+		}
+		goals := randomFlowGoals(rng, maze)
+		ff, err := NewWeightedFlowField(maze, goals, costs)
+		if err != nil {
+			t.Fatalf("maze %d: %v", i, err)
+		}
+		checkFlowField(t, maze, goals, costs, ff)
+	}
+
+	// 直接穿过沼泽代价为5+1，绕路走四格代价为4
+	maze := parseGrid(
+		"...",
+		"...",
+		"...")
+	costs := [][]int{
+		{1, 1, 1},
+		{1, 5, 1},
+		{1, 1, 1},
+	}
+	ff, err := NewWeightedFlowField(maze, [][2]int{{2, 1}}, costs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ff.Cost[0][1] != 4 || len(ff.Path([2]int{0, 1})) != 5 {
+		t.Fatalf("cost %d path %v, want 4 around the swamp", ff.Cost[0][1], ff.Path([2]int{0, 1}))
+	}
+}
+
+func TestFlowFieldInvalidGoals(t *testing.T) {
+	maze := parseGrid(
+		"..#",
+		"...")
+	tests := []struct {
+		name  string
+		goals [][2]int
+		costs [][]int
+		err   error
+	}{
+		{"没有目标", nil, nil, ErrNoGoals},
+		{"目标是墙", [][2]int{{0, 0}, {0, 2}}, nil, ErrBlocked},
+		{"目标越界", [][2]int{{2, 0}}, nil, ErrOutOfBounds},
+		{"负坐标", [][2]int{{0, -1}}, nil, ErrOutOfBounds},
+		{"权重行数不对", [][2]int{{0, 0}}, [][]int{{1, 1, 1}}, ErrCostsSize},
+		{"权重列数不对", [][2]int{{0, 0}}, [][]int{{1, 1, 1}, {1, 1}}, ErrCostsSize},
+	}
+	for _, tt := range tests {
+		if ff, err := NewWeightedFlowField(maze, tt.goals, tt.costs); ff != nil || !errors.Is(err, tt.err) {
+			t.Errorf("%s: flow field %v err %v, want %v", tt.name, ff, err, tt.err)
+		}
+	}
+
+	// 墙和越界的位置既没有方向也没有路径
+	ff, err := NewFlowField(maze, [][2]int{{1, 2}, {1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pos := range [][2]int{{0, 2}, {-1, 0}, {2, 0}, {0, 3}} {
+		if _, ok := ff.NextStep(pos); ok || ff.Reachable(pos) || len(ff.Path(pos)) != 0 {
+			t.Errorf("%v: reachable=%v path %v", pos, ff.Reachable(pos), ff.Path(pos))
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package pathfind
+
+// Heap 通用的二叉最小堆，所有寻路算法共用
+// less(a, b)为true时a先出堆；上浮和下沉的实现与container/heap相同，相同优先级的元素出堆顺序也相同
+type Heap[T any] struct {
+	items []T
+	less  func(a, b T) bool
+}
+
+// NewHeap 创建一个空堆
+func NewHeap[T any](less func(a, b T) bool) *Heap[T] {
+	return &Heap[T]{less: less}
+}
+
+func (h *Heap[T]) Len() int { return len(h.items) }
+
+// Push 加入一个元素
+func (h *Heap[T]) Push(x T) {
+	h.items = append(h.items, x)
+	h.up(len(h.items) - 1)
+}
+
+// Pop 取出优先级最高的元素，堆为空时panic
+func (h *Heap[T]) Pop() T {
+	n := len(h.items) - 1
+	h.items[0], h.items[n] = h.items[n], h.items[0]
+	h.down(0, n)
+	x := h.items[n]
+	var zero T
+	h.items[n] = zero // 避免内存泄漏
+	h.items = h.items[:n]
+	return x
+}
+
+// Peek 返回优先级最高的元素但不取出，堆为空时panic
+func (h *Heap[T]) Peek() T {
+	return h.items[0]
+}
+
+func (h *Heap[T]) up(j int) {
+	for {
+		i := (j - 1) / 2 // 父节点
+		if i == j || !h.less(h.items[j], h.items[i]) {
+			break
+		}
+		h.items[i], h.items[j] = h.items[j], h.items[i]
+		j = i
+	}
+}
+
+func (h *Heap[T]) down(i0, n int) {
+	i := i0
+	for {
+		j1 := 2*i + 1
+		if j1 >= n || j1 < 0 { // j1 < 0 表示int溢出
+			break
+		}
+		j := j1 // 左子节点
+		if j2 := j1 + 1; j2 < n && h.less(h.items[j2], h.items[j1]) {
+			j = j2 // 右子节点
+		}
+		if !h.less(h.items[j], h.items[i]) {
+			break
+		}
+		h.items[i], h.items[j] = h.items[j], h.items[i]
+		i = j
+	}
+}
@@ -0,0 +1,454 @@
+package pathfind
+
+import (
+	"sort"
+)
+
+// HPA*：分层寻路
+// 把地图划分成clusterSize*clusterSize的簇，相邻两个簇的边界上两侧都能走的连续一段作为入口，
+// 入口两侧的格子是抽象图的节点，跨簇的两个节点之间代价为1；同一个簇内的节点之间预先在簇内BFS求出距离。
+// 寻路时把起点和终点临时接到所在簇的节点上，在抽象图上A*，再在簇内把抽象路径细化成格子路径。
+// 结果不保证最短，但搜索的节点数只和簇的数量有关
+
+const (
+	DefaultClusterSize = 10
+	// 边界上连续可通行的一段长度达到该值时，在两端各放一个入口，否则只在中间放一个
+	hpaWideEntrance = 6
+)
+
+// HPA 分层寻路的抽象图，实现Preprocessor；修改地图后用SetCell增量更新
+type HPA struct {
+	ClusterSize int
+
+	maze         [][]int
+	rows, cols   int                       // 簇的行数和列数
+	nodes        map[[2]int]*hpaNode       // 抽象图的节点，以格子位置为键
+	clusterNodes []map[[2]int]bool         // 每个簇内的节点
+	borders      map[hpaBorder][][2][2]int // 每条边界上的入口，每个入口是边界两侧的一对格子
+}
+
+type hpaNode struct {
+	pos   [2]int
+	refs  int            // 引用这个节点的入口数，一个格子可能同时是两条边界上的入口
+	edges map[[2]int]int // 相邻节点和代价
+}
+
+// 簇cluster与它右边（dir=0）或下边（dir=1）的簇之间的边界
+type hpaBorder struct {
+	cluster int
+	dir     int
+}
+
+// HPAEdge 抽象图的一条边，用于可视化
+type HPAEdge struct {
+	A, B  [2]int
+	Cost  int
+	Inter bool // 跨簇的边
+}
+
+// NewHPA 在maze上建立抽象图，clusterSize<=1时使用DefaultClusterSize
+func NewHPA(maze [][]int, clusterSize int) *HPA {
+	h := &HPA{ClusterSize: clusterSize}
+	h.Preprocess(maze)
+	return h
+}
+
+// Preprocess 重新建立整个抽象图
+func (h *HPA) Preprocess(maze [][]int) {
+	if h.ClusterSize <= 1 {
+		h.ClusterSize = DefaultClusterSize
+	}
+	h.maze = maze
+	h.rows = (len(maze) + h.ClusterSize - 1) / h.ClusterSize
+	h.cols = 0
+	if len(maze) > 0 {
+		h.cols = (len(maze[0]) + h.ClusterSize - 1) / h.ClusterSize
+	}
+	h.nodes = make(map[[2]int]*hpaNode)
+	h.clusterNodes = make([]map[[2]int]bool, h.rows*h.cols)
+	for i := range h.clusterNodes {
+		h.clusterNodes[i] = make(map[[2]int]bool)
+	}
+	h.borders = make(map[hpaBorder][][2][2]int)
+
+	for c := range h.clusterNodes {
+		for dir := 0; dir < 2; dir++ {
+			h.buildBorder(hpaBorder{c, dir})
+		}
+	}
+	for c := range h.clusterNodes {
+		h.buildIntraEdges(c)
+	}
+}
+
+// FindPath 实现Pathfinder，没有预处理过或者换了地图时自动预处理
+func (h *HPA) FindPath(maze [][]int, start, end [2]int) PathFindResult {
+	if h.nodes == nil || !sameMaze(h.maze, maze) {
+		h.Preprocess(maze)
+	}
+	return h.findPath(start, end)
+}
+
+// SetCell 修改一个格子并增量更新抽象图：只重建它所在簇的四条边界上的入口，以及受影响的簇内距离
+func (h *HPA) SetCell(pos [2]int, value int) {
+	if pos[0] < 0 || pos[0] >= len(h.maze) || pos[1] < 0 || pos[1] >= len(h.maze[0]) {
+		return
+	}
+	h.maze[pos[0]][pos[1]] = value
+
+	c := h.clusterOf(pos)
+	cr, cc := c/h.cols, c%h.cols
+	affected := map[int]bool{c: true}
+	borders := []hpaBorder{{c, 0}, {c, 1}}
+	if cc > 0 {
+		borders = append(borders, hpaBorder{c - 1, 0})
+		affected[c-1] = true
+	}
+	if cr > 0 {
+		borders = append(borders, hpaBorder{c - h.cols, 1})
+		affected[c-h.cols] = true
+	}
+	if cc+1 < h.cols {
+		affected[c+1] = true
+	}
+	if cr+1 < h.rows {
+		affected[c+h.cols] = true
+	}
+
+	for _, b := range borders {
+		h.removeBorder(b)
+		h.buildBorder(b)
+	}
+	for cluster := range affected {
+		h.buildIntraEdges(cluster)
+	}
+}
+
+// Clusters 簇的行数、列数
+func (h *HPA) Clusters() (rows, cols int) {
+	return h.rows, h.cols
+}
+
+// Nodes 抽象图的所有节点，按位置排序
+func (h *HPA) Nodes() [][2]int {
+	nodes := make([][2]int, 0, len(h.nodes))
+	for pos := range h.nodes {
+		nodes = append(nodes, pos)
+	}
+	sortCells(nodes)
+	return nodes
+}
+
+// 按行、列排序，使遍历map得到的结果是确定的
+func sortCells(cells [][2]int) {
+	sort.Slice(cells, func(i, j int) bool {
+		return cells[i][0] < cells[j][0] || cells[i][0] == cells[j][0] && cells[i][1] < cells[j][1]
+	})
+}
+
+// Edges 抽象图的所有边，每条边只出现一次，按A、B的位置排序
+func (h *HPA) Edges() []HPAEdge {
+	edges := make([]HPAEdge, 0)
+	for _, pos := range h.Nodes() {
+		others := make([][2]int, 0, len(h.nodes[pos].edges))
+		for other := range h.nodes[pos].edges {
+			if other[0] > pos[0] || other[0] == pos[0] && other[1] > pos[1] {
+				others = append(others, other)
+			}
+		}
+		sortCells(others)
+		for _, other := range others {
+			edges = append(edges, HPAEdge{A: pos, B: other, Cost: h.nodes[pos].edges[other], Inter: h.clusterOf(pos) != h.clusterOf(other)})
+		}
+	}
+	return edges
+}
+
+func (h *HPA) clusterOf(pos [2]int) int {
+	return pos[0]/h.ClusterSize*h.cols + pos[1]/h.ClusterSize
+}
+
+// 簇的格子范围[r0,r1)*[c0,c1)
+func (h *HPA) clusterBounds(c int) (r0, c0, r1, c1 int) {
+	r0, c0 = c/h.cols*h.ClusterSize, c%h.cols*h.ClusterSize
+	r1, c1 = min(r0+h.ClusterSize, len(h.maze)), min(c0+h.ClusterSize, len(h.maze[0]))
+	return
+}
+
+func (h *HPA) addNode(pos [2]int) {
+	n := h.nodes[pos]
+	if n == nil {
+		n = &hpaNode{pos: pos, edges: make(map[[2]int]int)}
+		h.nodes[pos] = n
+		h.clusterNodes[h.clusterOf(pos)][pos] = true
+	}
+	n.refs++
+}
+
+func (h *HPA) releaseNode(pos [2]int) {
+	n := h.nodes[pos]
+	if n == nil {
+		return
+	}
+	n.refs--
+	if n.refs > 0 {
+		return
+	}
+	for other := range n.edges {
+		delete(h.nodes[other].edges, pos)
+	}
+	delete(h.nodes, pos)
+	delete(h.clusterNodes[h.clusterOf(pos)], pos)
+}
+
+// 扫描一条边界，找出两侧都可以通行的连续段，在每段上放置入口
+func (h *HPA) buildBorder(b hpaBorder) {
+	r0, c0, r1, c1 := h.clusterBounds(b.cluster)
+	// 边界内侧的格子序列和跨过边界的方向
+	var inside [][2]int
+	var step [2]int
+	if b.dir == 0 {
+		if c1 >= len(h.maze[0]) {
+			return
+		}
+		for r := r0; r < r1; r++ {
+			inside = append(inside, [2]int{r, c1 - 1})
+		}
+		step = [2]int{0, 1}
+	} else {
+		if r1 >= len(h.maze) {
+			return
+		}
+		for c := c0; c < c1; c++ {
+			inside = append(inside, [2]int{r1 - 1, c})
+		}
+		step = [2]int{1, 0}
+	}
+
+	open := func(i int) bool {
+		a := inside[i]
+		return isWalkable(h.maze, a) && isWalkable(h.maze, [2]int{a[0] + step[0], a[1] + step[1]})
+	}
+	entrances := make([][2][2]int, 0)
+	add := func(i int) {
+		a := inside[i]
+		pair := [2][2]int{a, {a[0] + step[0], a[1] + step[1]}}
+		entrances = append(entrances, pair)
+		h.addNode(pair[0])
+		h.addNode(pair[1])
+		h.nodes[pair[0]].edges[pair[1]] = 1
+		h.nodes[pair[1]].edges[pair[0]] = 1
+	}
+	for i := 0; i < len(inside); {
+		if !open(i) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(inside) && open(j) {
+			j++
+		}
+		if j-i >= hpaWideEntrance {
+			add(i)
+			add(j - 1)
+		} else {
+			add((i + j - 1) / 2)
+		}
+		i = j
+	}
+	h.borders[b] = entrances
+}
+
+func (h *HPA) removeBorder(b hpaBorder) {
+	for _, pair := range h.borders[b] {
+		if n := h.nodes[pair[0]]; n != nil {
+			delete(n.edges, pair[1])
+		}
+		if n := h.nodes[pair[1]]; n != nil {
+			delete(n.edges, pair[0])
+		}
+		h.releaseNode(pair[0])
+		h.releaseNode(pair[1])
+	}
+	delete(h.borders, b)
+}
+
+// 重新计算簇内所有节点两两之间的距离（只在簇内走）
+func (h *HPA) buildIntraEdges(c int) {
+	members := h.clusterNodes[c]
+	for pos := range members {
+		for other := range h.nodes[pos].edges {
+			if members[other] {
+				delete(h.nodes[pos].edges, other)
+			}
+		}
+	}
+	for pos := range members {
+		dist, _ := h.clusterBFS(pos, c)
+		for other := range members {
+			if other == pos {
+				continue
+			}
+			if d, ok := dist[other]; ok {
+				h.nodes[pos].edges[other] = d
+			}
+		}
+	}
+}
+
+// 只在簇c内做BFS，返回距离和前驱
+func (h *HPA) clusterBFS(from [2]int, c int) (map[[2]int]int, map[[2]int][2]int) {
+	r0, c0, r1, c1 := h.clusterBounds(c)
+	dist := map[[2]int]int{from: 0}
+	parent := make(map[[2]int][2]int)
+	queue := [][2]int{from}
+	dirs := [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}}
+	for head := 0; head < len(queue); head++ {
+		cur := queue[head]
+		for _, dir := range dirs {
+			next := [2]int{cur[0] + dir[0], cur[1] + dir[1]}
+			if next[0] < r0 || next[0] >= r1 || next[1] < c0 || next[1] >= c1 || !isWalkable(h.maze, next) {
+				continue
+			}
+			if _, ok := dist[next]; ok {
+				continue
+			}
+			dist[next] = dist[cur] + 1
+			parent[next] = cur
+			queue = append(queue, next)
+		}
+	}
+	return dist, parent
+}
+
+// 在抽象图上A*，再把抽象路径细化成格子路径
+func (h *HPA) findPath(start, end [2]int) PathFindResult {
+	res, abstract := h.searchAbstract(start, end)
+	if res.Found {
+		res.Path = h.refine(abstract)
+	}
+	return res
+}
+
+// 在抽象图上A*，返回经过的抽象节点（包括起点和终点）
+func (h *HPA) searchAbstract(start, end [2]int) (PathFindResult, [][2]int) {
+	if err := validateEnds(h.maze, start, end); err != nil {
+		return PathFindResult{Path: make([][2]int, 0), Err: err}, nil
+	}
+	var res PathFindResult
+
+	// 起点和终点临时接到所在簇的节点上，两者在同一个簇时还可以直接在簇内走过去
+	startCluster, endCluster := h.clusterOf(start), h.clusterOf(end)
+	startDist, _ := h.clusterBFS(start, startCluster)
+	endDist, _ := h.clusterBFS(end, endCluster)
+	neighbors := func(pos [2]int) map[[2]int]int {
+		edges := make(map[[2]int]int)
+		if n := h.nodes[pos]; n != nil {
+			for other, cost := range n.edges {
+				edges[other] = cost
+			}
+		}
+		if pos == start {
+			for other := range h.clusterNodes[startCluster] {
+				if d, ok := startDist[other]; ok {
+					edges[other] = d
+				}
+			}
+			if d, ok := startDist[end]; ok {
+				edges[end] = d
+			}
+		}
+		if h.clusterOf(pos) == endCluster && h.nodes[pos] != nil {
+			if d, ok := endDist[pos]; ok {
+				edges[end] = d
+			}
+		}
+		return edges
+	}
+	// 相邻节点按位置排序，搜索结果不受map遍历顺序影响
+	sortedKeys := func(edges map[[2]int]int) [][2]int {
+		keys := make([][2]int, 0, len(edges))
+		for pos := range edges {
+			keys = append(keys, pos)
+		}
+		sortCells(keys)
+		return keys
+	}
+
+	g := map[[2]int]int{start: 0}
+	parent := make(map[[2]int][2]int)
+	closed := make(map[[2]int]bool)
+	open := newNodeQueue()
+	open.Push(&Node{pos: start, f: manhattanDistance(start, end)})
+	found := false
+	for open.Len() > 0 {
+		cur := open.Pop()
+		if closed[cur.pos] {
+			continue
+		}
+		closed[cur.pos] = true
+		res.StepRecord.Steps = append(res.StepRecord.Steps, MazeStep{Pos: cur.pos, Type: "pop"})
+		if cur.pos == end {
+			found = true
+			break
+		}
+		edges := neighbors(cur.pos)
+		for _, next := range sortedKeys(edges) {
+			cost := edges[next]
+			res.Check++
+			if closed[next] {
+				continue
+			}
+			ng := g[cur.pos] + cost
+			if old, ok := g[next]; ok && old <= ng {
+				continue
+			}
+			g[next] = ng
+			parent[next] = cur.pos
+			res.Cost++
+			open.Push(&Node{pos: next, g: ng, f: ng + manhattanDistance(next, end)})
+			res.StepRecord.Steps = append(res.StepRecord.Steps, MazeStep{Pos: next, Type: "push"})
+		}
+	}
+
+	res.Path = make([][2]int, 0)
+	if !found {
+		return res, nil
+	}
+	res.Found = true
+
+	abstract := [][2]int{end}
+	for pos := end; pos != start; {
+		pos = parent[pos]
+		abstract = append([][2]int{pos}, abstract...)
+	}
+	return res, abstract
+}
+
+// 细化：相邻的两个抽象节点之间要么跨过边界（相邻格子），要么在同一个簇内，在簇内BFS得到格子路径
+func (h *HPA) refine(abstract [][2]int) [][2]int {
+	path := [][2]int{abstract[0]}
+	for i := 1; i < len(abstract); i++ {
+		from, to := abstract[i-1], abstract[i]
+		if from == to {
+			continue
+		}
+		if manhattanDistance(from, to) == 1 && h.clusterOf(from) != h.clusterOf(to) {
+			path = append(path, to)
+			continue
+		}
+		_, parent := h.clusterBFS(from, h.clusterOf(from))
+		segment := make([][2]int, 0)
+		for pos := to; pos != from; pos = parent[pos] {
+			segment = append([][2]int{pos}, segment...)
+		}
+		path = append(path, segment...)
+	}
+	return path
+}
+
+// AbstractPath 只在抽象图上搜索，返回经过的抽象节点（包括起点和终点），找不到时返回nil，用于可视化
+func (h *HPA) AbstractPath(start, end [2]int) [][2]int {
+	_, abstract := h.searchAbstract(start, end)
+	return abstract
+}
@@ -0,0 +1,46 @@
+package pathfind
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// 每次SetCell之后，增量更新的抽象图与在当前地图上重新建立的完全相同，寻路结果也与重新建立的一致
+func TestHPASetCellMatchesRebuild(t *testing.T) {
+	rng := rand.New(rand.NewSource(45))
+	for i := 0; i < 25; i++ {
+		maze := randomGrid(rng, 5+rng.Intn(30), 5+rng.Intn(30), rng.Float64()*0.3)
+		clusterSize := 2 + rng.Intn(8) // 地图尺寸不一定是簇大小的整数倍
+		h := NewHPA(copyMaze(maze), clusterSize)
+
+		for step := 0; step < 50; step++ {
+			pos := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+			if rng.Intn(3) == 0 {
+				// 优先修改簇的边界，入口的变化最多
+				pos[0] = min(pos[0]/clusterSize*clusterSize+clusterSize-1, len(maze)-1)
+			}
+			maze[pos[0]][pos[1]] = 1 - maze[pos[0]][pos[1]]
+			h.SetCell(pos, maze[pos[0]][pos[1]])
+
+			fresh := NewHPA(copyMaze(maze), clusterSize)
+			if !reflect.DeepEqual(h.Nodes(), fresh.Nodes()) {
+				t.Fatalf("maze %d step %d: after SetCell(%v) nodes %v, rebuilt %v", i, step, pos, h.Nodes(), fresh.Nodes())
+			}
+			if !reflect.DeepEqual(h.Edges(), fresh.Edges()) {
+				t.Fatalf("maze %d step %d: after SetCell(%v) edges differ from rebuilt graph", i, step, pos)
+			}
+
+			start := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+			end := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+			if !isWalkable(maze, start) || !isWalkable(maze, end) {
+				continue
+			}
+			res := h.FindPath(h.maze, start, end)
+			checkAgainstDijkstra(t, "hpa", maze, start, end, res)
+			if want := fresh.FindPath(fresh.maze, start, end); len(res.Path) != len(want.Path) {
+				t.Fatalf("maze %d step %d %v->%v: path length %d, rebuilt graph %d", i, step, start, end, len(res.Path), len(want.Path))
+			}
+		}
+	}
+}
@@ -1,120 +1,140 @@
 package pathfind
 
-import (
-	"container/heap"
-)
-
-// JNode 表示JPS搜索中的一个节点
-type JNode struct {
-	pos     [2]int // 位置
-	g       int    // 从起点到当前点的实际代价
-	h       int    // 从当前点到终点的估计代价
-	f       int    // f = g + h
-	parent  *JNode // 父节点
-	index   int    // 在优先队列中的索引
-	fromDir [2]int // 从父节点到当前节点的方向
+// 检查位置是否在迷宫范围内且可通行
+func isWalkable(maze [][]int, pos [2]int) bool {
+	return pos[0] >= 0 && pos[0] < len(maze) &&
+		pos[1] >= 0 && pos[1] < len(maze[0]) &&
+		maze[pos[0]][pos[1]] == 0
 }
 
-// JPriorityQueue 实现堆接口
-type JPriorityQueue []*JNode
+// 4方向JPS
+// 规范路径：在所有最短路径中让竖直移动尽量靠前。从c水平走到x后再往竖直方向v走时，
+// 如果c+v可以通行，先竖直再水平的路径一样长，所以水平移动之后只有c+v是墙（强迫邻居）才转向竖直；
+// 竖直移动之后三个方向都可以走。
+// 水平跳跃在终点或有强迫邻居的格子停下；竖直跳跃在终点或者从这里水平跳跃能找到跳点的格子停下。
+// 同一个格子从不同方向到达时后继不同，关闭集合按（位置，到达方向）记录
 
-func (pq JPriorityQueue) Len() int { return len(pq) }
+var (
+	jpsDirs     = [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} // 上 下 左 右，JPS+预处理表也按这个顺序
+	noJumpPoint = [2]int{-1, -1}
+)
 
-func (pq JPriorityQueue) Less(i, j int) bool {
-	return pq[i].f < pq[j].f
+// jpsState 关闭集合的键
+type jpsState struct {
+	pos, dir [2]int
 }
 
-func (pq JPriorityQueue) Swap(i, j int) {
-	pq[i], pq[j] = pq[j], pq[i]
-	pq[i].index = i
-	pq[j].index = j
+// 沿水平方向h走到x时，竖直方向v的邻居是否是强迫邻居
+func forcedVertical(maze [][]int, x, h, v [2]int) bool {
+	return isWalkable(maze, [2]int{x[0] + v[0], x[1]}) && !isWalkable(maze, [2]int{x[0] + v[0], x[1] - h[1]})
 }
 
-func (pq *JPriorityQueue) Push(x interface{}) {
-	n := len(*pq)
-	node := x.(*JNode)
-	node.index = n
-	*pq = append(*pq, node)
+func hasForcedVertical(maze [][]int, x, h [2]int) bool {
+	return forcedVertical(maze, x, h, [2]int{-1, 0}) || forcedVertical(maze, x, h, [2]int{1, 0})
 }
 
-func (pq *JPriorityQueue) Pop() interface{} {
-	old := *pq
-	n := len(old)
-	node := old[n-1]
-	old[n-1] = nil  // 避免内存泄漏
-	node.index = -1 // 标记为已移除
-	*pq = old[0 : n-1]
-	return node
+// 在给定方向上跳跃，返回第一个跳点，碰壁时返回noJumpPoint
+func jump(maze [][]int, current [2]int, dir [2]int, end [2]int, res *PathFindResult) [2]int {
+	for {
+		next := [2]int{current[0] + dir[0], current[1] + dir[1]}
+		if !isWalkable(maze, next) {
+			return noJumpPoint
+		}
+		res.Check++
+		if next == end {
+			return next
+		}
+		if dir[0] == 0 { // 水平移动
+			if hasForcedVertical(maze, next, dir) {
+				return next
+			}
+		} else { // 竖直移动：向左右水平跳跃能找到跳点时停下
+			if jump(maze, next, [2]int{0, -1}, end, res) != noJumpPoint || jump(maze, next, [2]int{0, 1}, end, res) != noJumpPoint {
+				return next
+			}
+		}
+		current = next
+	}
 }
 
-// 检查位置是否在迷宫范围内且可通行
-func isWalkable(maze [][]int, pos [2]int) bool {
-	return pos[0] >= 0 && pos[0] < len(maze) &&
-		pos[1] >= 0 && pos[1] < len(maze[0]) &&
-		maze[pos[0]][pos[1]] == 0
+// 节点的后继方向：起点为四个方向；竖直到达时继续前进和左右；水平到达时继续前进和强迫的竖直方向
+func jpsDirections(maze [][]int, cur *Node) [][2]int {
+	if cur.parent == nil {
+		return jpsDirs
+	}
+	d := cur.fromDir
+	if d[1] == 0 {
+		return [][2]int{d, {0, -1}, {0, 1}}
+	}
+	dirs := [][2]int{d}
+	for _, v := range [][2]int{{-1, 0}, {1, 0}} {
+		if forcedVertical(maze, cur.pos, d, v) {
+			dirs = append(dirs, v)
+		}
+	}
+	return dirs
 }
 
-// 在给定方向上跳跃，直到找到跳点或碰壁
-func jump(maze [][]int, current [2]int, dir [2]int, end [2]int, res *PathFindResult, depth int) [2]int {
-	next := [2]int{current[0] + dir[0], current[1] + dir[1]}
-	// 如果不可通行，返回nil
-	if !isWalkable(maze, next) {
-		//fmt.Println("unwalkable return ", next)
-		return [2]int{-1, -1}
-	}
-	res.Check++
-	//fmt.Println(strings.Repeat(" ", depth), current, getStrFromDir(dir), next)
+// 在跳点之间做A*，jumpFrom返回从cur沿dir的跳点，找到终点时返回终点节点
+func jpsSearch(maze [][]int, start, end [2]int, res *PathFindResult, jumpFrom func(cur *Node, dir [2]int) [2]int) *Node {
+	open := newNodeQueue()
+	open.Push(&Node{pos: start, h: manhattanDistance(start, end), f: manhattanDistance(start, end)})
+	closed := make(map[jpsState]bool)
 
-	// 如果到达终点，返回当前位置
-	if next == end {
-		//fmt.Println(strings.Repeat(" ", depth), "found end")
-		return next
-	}
+	for open.Len() > 0 {
+		current := open.Pop()
+		if closed[jpsState{current.pos, current.fromDir}] {
+			continue
+		}
+		closed[jpsState{current.pos, current.fromDir}] = true
+		res.StepRecord.Steps = append(res.StepRecord.Steps, MazeStep{
+			Pos:  current.pos,
+			Type: "pop", // 标记为已检查
+			Dir:  [2]int{0, 0},
+		})
+		if current.pos == end {
+			return current
+		}
 
-	// 水平或垂直移动
-	if dir[0] == 0 || dir[1] == 0 {
-		// 检查强迫邻居
-		if dir[0] == 0 { // 水平移动
-			// 检查上下是否有强迫邻居
-			if isWalkable(maze, [2]int{next[0] - 1, next[1]}) && !isWalkable(maze, [2]int{next[0] - 1, current[1]}) ||
-				isWalkable(maze, [2]int{next[0] + 1, next[1]}) && !isWalkable(maze, [2]int{next[0] + 1, current[1]}) {
-				//fmt.Println(strings.Repeat(" ", depth), "found -十", current, next)
-				return next
-			}
-			if isWalkable(maze, [2]int{next[0] - 1, next[1]}) && !isWalkable(maze, [2]int{next[0] + dir[0], next[1] + dir[1]}) ||
-				isWalkable(maze, [2]int{next[0] + 1, next[1]}) && !isWalkable(maze, [2]int{next[0] + dir[0], next[1] + dir[1]}) {
-				//fmt.Println(strings.Repeat(" ", depth), "found -H", current, next)
-				return next
-			}
-		} else { // 垂直移动
-			// 检查左右是否有强迫邻居
-			if isWalkable(maze, [2]int{next[0], next[1] - 1}) && !isWalkable(maze, [2]int{current[0], next[1] - 1}) ||
-				isWalkable(maze, [2]int{next[0], next[1] + 1}) && !isWalkable(maze, [2]int{current[0], next[1] + 1}) {
-				//fmt.Println(strings.Repeat(" ", depth), "found |十", current, next)
-				return next
+		for _, dir := range jpsDirections(maze, current) {
+			jumpPoint := jumpFrom(current, dir)
+			if jumpPoint == noJumpPoint || closed[jpsState{jumpPoint, dir}] {
+				continue
 			}
-			if isWalkable(maze, [2]int{next[0], next[1] - 1}) && !isWalkable(maze, [2]int{next[0] + dir[0], next[1] + dir[1]}) ||
-				isWalkable(maze, [2]int{next[0], next[1] + 1}) && !isWalkable(maze, [2]int{next[0] + dir[0], next[1] + dir[1]}) {
-				//fmt.Println(strings.Repeat(" ", depth), "found |工", current, next)
-				return next
+			neighbor := &Node{
+				pos:     jumpPoint,
+				g:       current.g + manhattanDistance(current.pos, jumpPoint),
+				h:       manhattanDistance(jumpPoint, end),
+				parent:  current,
+				fromDir: dir, // 记录来源方向
 			}
+			neighbor.f = neighbor.g + neighbor.h
+			res.Cost++
+			open.Push(neighbor)
+			res.StepRecord.Steps = append(res.StepRecord.Steps, MazeStep{
+				Pos:  neighbor.pos,
+				Type: "push", // 标记为已检查
+				Dir:  [2]int{0, 0},
+			})
 		}
-		//fmt.Println("continue line move", next, dir)
-		return jump(maze, next, dir, end, res, depth+1)
 	}
+	return nil
+}
 
-	// //fmt.Println("continue diagonal line move", next, dir)
-	// // 对角线移动
-	// // 检查水平和垂直方向是否有跳点
-	// if jump(maze, next, [2]int{dir[0], 0}, end, res, depth+1) != [2]int{-1, -1} ||
-	// 	jump(maze, next, [2]int{0, dir[1]}, end, res, depth+1) != [2]int{-1, -1} {
-	// 	fmt.Println(strings.Repeat(" ", depth), "found:\\", current, next)
-	// 	return next
-	// }
-
-	// //fmt.Println("continue diagonal check", next, dir)
-	// // 继续对角线移动
-	return jump(maze, next, dir, end, res, depth+1)
+// 重建跳点之间的路径，逐步检查相邻并且可以通行后才算找到
+func jpsResult(maze [][]int, res PathFindResult, found *Node) PathFindResult {
+	res.Path = make([][2]int, 0)
+	if found == nil {
+		return res
+	}
+	path := rebuildPath(found)
+	if !validPath(maze, path) {
+		res.Err = ErrInvalidPath
+		return res
+	}
+	res.Found = true
+	res.Path = path
+	return res
 }
 
 func getStrFromDir(dir [2]int) string {
@@ -188,15 +208,15 @@ func getPointsBetween(start, end [2]int) [][2]int {
 }
 
 // rebuildPath 从跳点重建完整路径
-func rebuildPath(current *JNode) [][2]int {
+func rebuildPath(current *Node) [][2]int {
 	if current == nil {
 		return make([][2]int, 0)
 	}
 
 	// 收集所有跳点
-	var nodes []*JNode
+	var nodes []*Node
 	for node := current; node != nil; node = node.parent {
-		nodes = append([]*JNode{node}, nodes...)
+		nodes = append([]*Node{node}, nodes...)
 	}
 
 	path := make([][2]int, 0)
@@ -213,86 +233,13 @@ func rebuildPath(current *JNode) [][2]int {
 }
 
 func FindPathJPS(maze [][]int, start, end [2]int) PathFindResult {
-	// 初始化优先队列和访问集合
-	openList := &JPriorityQueue{}
-	heap.Init(openList)
-	visited := make(map[[2]int]bool)
-	//fmt.Println("-----------------------------------------------------")
-	// 创建起点节点
-	startNode := &JNode{
-		pos: start,
-		g:   0,
-		h:   manhattanDistance(start, end),
+	if err := validateEnds(maze, start, end); err != nil {
+		return PathFindResult{Path: make([][2]int, 0), Err: err}
 	}
-	startNode.f = startNode.g + startNode.h
 
-	heap.Push(openList, startNode)
-
-	// 定义所有可能的方向（包括对角线）
-	dirs := [][2]int{
-		{-1, 0}, {1, 0}, {0, -1}, {0, 1}, // 基本方向
-		//{-1, -1}, {-1, 1}, {1, -1}, {1, 1}, // 对角线
-	}
-
-	var current *JNode
 	var res PathFindResult
-
-	// 主循环
-	for openList.Len() > 0 {
-		current = heap.Pop(openList).(*JNode)
-		//fmt.Println("pop from openlist", current.pos)
-		res.StepRecord.Steps = append(res.StepRecord.Steps, MazeStep{
-			Pos:  current.pos,
-			Type: "pop", // 标记为已检查
-			Dir:  [2]int{0, 0},
-		})
-		// 如果到达终点
-		if current.pos == end {
-			break
-		}
-
-		// 标记为已访问
-		visited[current.pos] = true
-
-		// 在每个方向上寻找跳点
-		for _, dir := range dirs {
-			// 跳过与来源方向相反的方向
-			if current.parent != nil {
-				// 如果当前方向与来源方向相反，跳过
-				if dir[0] == -current.fromDir[0] && dir[1] == -current.fromDir[1] {
-					continue
-				}
-			}
-
-			jumpPoint := jump(maze, current.pos, dir, end, &res, 0)
-			if jumpPoint == [2]int{-1, -1} || visited[jumpPoint] {
-				continue
-			}
-
-			distance := manhattanDistance(current.pos, jumpPoint)
-			neighbor := &JNode{
-				pos:     jumpPoint,
-				g:       current.g + distance,
-				h:       manhattanDistance(jumpPoint, end),
-				parent:  current,
-				fromDir: dir, // 记录来源方向
-			}
-			neighbor.f = neighbor.g + neighbor.h
-			res.Cost++
-
-			// 添加到优先队列
-			heap.Push(openList, neighbor)
-			res.StepRecord.Steps = append(res.StepRecord.Steps, MazeStep{
-				Pos:  neighbor.pos,
-				Type: "push", // 标记为已检查
-				Dir:  [2]int{0, 0},
-			})
-			//fmt.Println("push to openlist", neighbor.pos, " parent:", current.pos)
-		}
-	}
-
-	// 使用新函数重建路径
-	res.Path = rebuildPath(current)
-
-	return res
+	found := jpsSearch(maze, start, end, &res, func(cur *Node, dir [2]int) [2]int {
+		return jump(maze, cur.pos, dir, end, &res)
+	})
+	return jpsResult(maze, res, found)
 }
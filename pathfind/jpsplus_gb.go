@@ -1,201 +1,112 @@
 package pathfind
 
-import (
-	"container/heap"
-)
+// jpsPlusEntry 从某个格子沿某个方向跳跃的预处理结果
+type jpsPlusEntry struct {
+	dist int  // 有跳点时为走到第一个跳点的步数，否则为走到墙前最后一个格子的步数
+	jump bool // 这个方向上是否有跳点
+}
 
 // PreprocessedMaze 存储预处理的迷宫信息
 type PreprocessedMaze struct {
-	maze       [][]int       // 原始迷宫
-	primaryJPs [][][4][2]int // 每个方向的主要跳点
+	maze  [][]int             // 原始迷宫
+	jumps [][][4]jpsPlusEntry // 每个格子四个方向（按jpsDirs的顺序）的跳跃结果
 }
 
-// 预处理迷宫，计算跳点和边界
+// 预处理迷宫，跳点的规则与FindPathJPS相同，只是不考虑终点，终点在寻路时检查
 func PreprocessMaze(maze [][]int) *PreprocessedMaze {
 	height, width := len(maze), len(maze[0])
-
-	// 创建一个全0的迷宫用于测试
-	testMaze := make([][]int, height)
-	for i := range testMaze {
-		testMaze[i] = make([]int, width)
-		// 所有元素默认为0，不需要特别设置
-	}
-
 	pm := &PreprocessedMaze{
-		maze:       maze, //testMaze, // 使用测试迷宫替代输入的迷宫
-		primaryJPs: [][][4][2]int{},
+		maze:  maze,
+		jumps: make([][][4]jpsPlusEntry, height),
 	}
-
-	// 初始化方向数组
-	dirs := [][2]int{
-		{-1, 0}, {1, 0}, {0, -1}, {0, 1}, // 基本方向 上 下 左 右
-		//{-1, -1}, {-1, 1}, {1, -1}, {1, 1}, // 对角线
-	}
-
-	// 初始化primaryJPs数组
-	pm.primaryJPs = make([][][4][2]int, height)
 	for y := 0; y < height; y++ {
-		pm.primaryJPs[y] = make([][4][2]int, width)
-		// 不需要再为每个x初始化，因为[4][2]int是固定大小的数组，会自动初始化为零值
+		pm.jumps[y] = make([][4]jpsPlusEntry, width)
 	}
 
-	// 重新排序循环：先y后x最后是方向i
+	// 水平方向：逆着跳跃方向扫描，每个格子由下一个格子的结果递推
 	for y := 0; y < height; y++ {
+		for x := width - 1; x >= 0; x-- {
+			pm.jumps[y][x][3] = pm.scanStep([2]int{y, x}, 3)
+		}
 		for x := 0; x < width; x++ {
-			if maze[y][x] == 0 {
-				for i := range dirs {
-					jp := pm.findJumpPoint([2]int{y, x}, dirs[i])
-					pm.primaryJPs[y][x][i] = jp
-				}
-			}
+			pm.jumps[y][x][2] = pm.scanStep([2]int{y, x}, 2)
+		}
+	}
+	// 竖直方向的跳点取决于水平跳跃的结果，放在后面
+	for x := 0; x < width; x++ {
+		for y := height - 1; y >= 0; y-- {
+			pm.jumps[y][x][1] = pm.scanStep([2]int{y, x}, 1)
+		}
+		for y := 0; y < height; y++ {
+			pm.jumps[y][x][0] = pm.scanStep([2]int{y, x}, 0)
 		}
 	}
-
-	// // 添加调试输出
-	// for y := 0; y < height; y++ {
-	// 	for x := 0; x < width; x++ {
-	// 		if maze[y][x] == 0 {
-	// 			fmt.Printf("\n位置 [%d,%d] 的跳点:\n", y, x)
-
-	// 			// 第一行（上）
-	// 			fmt.Printf("[     ] [%2d,%2d] [     ]\n", pm.primaryJPs[y][x][0][0], pm.primaryJPs[y][x][0][1])
-
-	// 			// 第二行（左、中心点、右）
-	// 			fmt.Printf("[%2d,%2d] [%2d,%2d] [%2d,%2d]\n", pm.primaryJPs[y][x][2][0], pm.primaryJPs[y][x][2][1], y, x, pm.primaryJPs[y][x][3][0], pm.primaryJPs[y][x][3][1])
-
-	// 			// 第三行（下）
-	// 			fmt.Printf("[     ] [%2d,%2d] [     ]\n", pm.primaryJPs[y][x][1][0], pm.primaryJPs[y][x][1][1])
-	// 		}
-	// 	}
-	// }
-
 	return pm
 }
 
-// findJumpPoint 在给定方向上寻找跳点
-func (pm *PreprocessedMaze) findJumpPoint(pos [2]int, dir [2]int) [2]int {
+// 从pos沿第i个方向走一步后的跳跃结果，下一个格子的结果必须已经算好
+func (pm *PreprocessedMaze) scanStep(pos [2]int, i int) jpsPlusEntry {
+	dir := jpsDirs[i]
 	next := [2]int{pos[0] + dir[0], pos[1] + dir[1]}
-
-	// 如果不可通行，返回nil
 	if !isWalkable(pm.maze, next) {
-		return [2]int{-1, -1}
+		return jpsPlusEntry{}
 	}
-	// 检next在dir方向下一个tile有障碍：撞墙了）
-	if !isWalkable(pm.maze, [2]int{next[0] + dir[0], next[1] + dir[1]}) {
-		//fmt.Println(strings.Repeat(" ", depth), "found -H", current, next)
-		return next
+	if dir[0] == 0 && hasForcedVertical(pm.maze, next, dir) ||
+		dir[1] == 0 && (pm.jumps[next[0]][next[1]][2].jump || pm.jumps[next[0]][next[1]][3].jump) {
+		return jpsPlusEntry{dist: 1, jump: true}
 	}
+	e := pm.jumps[next[0]][next[1]][i]
+	return jpsPlusEntry{dist: e.dist + 1, jump: e.jump}
+}
 
-	if dir[0] == 0 { // 水平移动
-		// 检查上下是否有强迫邻居（当前tile上下有障碍，next tile上下没有障碍：有上或下的岔路）
-		if isWalkable(pm.maze, [2]int{next[0] - 1, next[1]}) && !isWalkable(pm.maze, [2]int{next[0] - 1, pos[1]}) ||
-			isWalkable(pm.maze, [2]int{next[0] + 1, next[1]}) && !isWalkable(pm.maze, [2]int{next[0] + 1, pos[1]}) {
-			//fmt.Println(strings.Repeat(" ", depth), "found -十", current, next)
-			return next
+// 从pos沿第i个方向的跳点，扫描经过终点（或竖直扫描经过终点所在的行、从那里水平能走到终点）时提前停下
+func (pm *PreprocessedMaze) jumpPoint(pos [2]int, i int, end [2]int) [2]int {
+	dir := jpsDirs[i]
+	e := pm.jumps[pos[0]][pos[1]][i]
+	stop := [2]int{pos[0] + dir[0]*e.dist, pos[1] + dir[1]*e.dist}
+	if dir[0] == 0 {
+		if end[0] == pos[0] && between(end[1], pos[1], stop[1]) {
+			return end
 		}
-
-	} else { // 垂直移动
-		// 检查左右是否有强迫邻居
-		if isWalkable(pm.maze, [2]int{next[0], next[1] - 1}) && !isWalkable(pm.maze, [2]int{pos[0], next[1] - 1}) ||
-			isWalkable(pm.maze, [2]int{next[0], next[1] + 1}) && !isWalkable(pm.maze, [2]int{pos[0], next[1] + 1}) {
-			//fmt.Println(strings.Repeat(" ", depth), "found |十", current, next)
-			return next
+	} else if between(end[0], pos[0], stop[0]) {
+		x := [2]int{end[0], pos[1]}
+		if x == end {
+			return end
 		}
-
+		h := 3
+		if end[1] < x[1] {
+			h = 2
+		}
+		if between(end[1], x[1], x[1]+jpsDirs[h][1]*pm.jumps[x[0]][x[1]][h].dist) {
+			return x
+		}
+	}
+	if e.jump {
+		return stop
 	}
-	//fmt.Println("continue line move", next, dir)
-	return pm.findJumpPoint(next, dir)
+	return noJumpPoint
+}
+
+// a是否在from（不含）和to（含）之间
+func between(a, from, to int) bool {
+	return from < a && a <= to || to <= a && a < from
 }
 
 // FindPathJPSPlus 使用JPS+算法寻找路径
 func (pm *PreprocessedMaze) FindPathJPSPlus(start, end [2]int) PathFindResult {
-	openList := &JPriorityQueue{}
-	heap.Init(openList)
-	visited := make(map[[2]int]bool)
-
-	startNode := &JNode{
-		pos: start,
-		g:   0,
-		h:   manhattanDistance(start, end),
+	if err := validateEnds(pm.maze, start, end); err != nil {
+		return PathFindResult{Path: make([][2]int, 0), Err: err}
 	}
-	startNode.f = startNode.g + startNode.h
 
-	heap.Push(openList, startNode)
-
-	dirs := [][2]int{
-		{-1, 0}, {1, 0}, {0, -1}, {0, 1},
-		//{-1, -1}, {-1, 1}, {1, -1}, {1, 1},
-	}
-
-	var current *JNode
 	var res PathFindResult
-
-	for openList.Len() > 0 {
-		current = heap.Pop(openList).(*JNode)
-		//fmt.Println("pop", current.pos)
-
-		res.StepRecord.Steps = append(res.StepRecord.Steps, MazeStep{
-			Pos:  current.pos,
-			Type: "pop", // 标记为已检查
-			Dir:  [2]int{0, 0},
-		})
-
-		if current.pos == end {
-			break
-		}
-
-		if visited[current.pos] {
-			continue
-		}
-
-		visited[current.pos] = true
-
-		for i, dir := range dirs {
-
-			if current.parent != nil {
-				// 如果当前方向与来源方向相反，跳过
-				if dir[0] == -current.fromDir[0] && dir[1] == -current.fromDir[1] {
-					continue
-				}
-			}
-
-			// 使用预计算的跳点
-			jp := pm.primaryJPs[current.pos[0]][current.pos[1]][i]
-			if jp == [2]int{-1, -1} || visited[jp] {
-				continue
-			}
-			//fmt.Println("  jp", jp)
-
-			if (dir[0] == 0 && (end[1] > current.pos[1] && end[1] < jp[1] || end[1] < current.pos[1] && end[1] > jp[1])) ||
-				(dir[1] == 0 && (end[0] > current.pos[0] && end[0] < jp[0] || end[0] < current.pos[0] && end[0] > jp[0])) {
-				jp = end
-			}
-
-			res.Check++
-
-			distance := manhattanDistance(current.pos, jp)
-			neighbor := &JNode{
-				pos:     jp,
-				g:       current.g + distance,
-				h:       manhattanDistance(jp, end),
-				parent:  current,
-				fromDir: dir, // 记录来源方向
+	found := jpsSearch(pm.maze, start, end, &res, func(cur *Node, dir [2]int) [2]int {
+		res.Check++
+		for i := range jpsDirs {
+			if jpsDirs[i] == dir {
+				return pm.jumpPoint(cur.pos, i, end)
 			}
-			neighbor.f = neighbor.g + neighbor.h
-			res.Cost++
-
-			//fmt.Println("  push", neighbor.pos)
-			heap.Push(openList, neighbor)
-			res.StepRecord.Steps = append(res.StepRecord.Steps, MazeStep{
-				Pos:  neighbor.pos,
-				Type: "push", // 标记为已检查
-				Dir:  [2]int{0, 0},
-			})
 		}
-	}
-
-	// 使用jps.go中的rebuildPath函数重建路径
-	res.Path = rebuildPath(current)
-
-	return res
+		return noJumpPoint
+	})
+	return jpsResult(pm.maze, res, found)
 }
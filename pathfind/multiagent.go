@@ -0,0 +1,536 @@
+package pathfind
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// 多智能体寻路：为同一张地图上的N个智能体规划按时间索引的路径（每个时刻走一格或者原地等待），
+// 任何时刻两个智能体不在同一个格子（点冲突），也不在相邻两格之间互换位置（交换冲突）。
+// 到达终点后智能体停在终点不动
+//
+// Cooperative A*：按顺序逐个规划，已规划的路径写进预约表，后面的智能体在时空A*中避开预约的格子和移动
+// WHCA*：只在window步内避让，执行一半后从新的位置重新规划，不会被早到终点的智能体永久堵住
+// CBS：冲突搜索，底层每个智能体独立规划，高层发现冲突时分成两支分别约束冲突的两个智能体，结果的总代价最小
+
+var (
+	ErrAgentOverlap = errors.New("agents share a start or goal")
+	ErrSearchLimit  = errors.New("search limit reached")
+)
+
+// Agent 一个智能体的起点和终点
+type Agent struct {
+	Start, Goal [2]int
+}
+
+// Conflict 两个智能体之间的冲突：Swap为false时是Time时刻都在Pos，
+// 为true时是Time到Time+1之间A从Pos走到To、B从To走到Pos
+type Conflict struct {
+	A, B int
+	Time int
+	Pos  [2]int
+	To   [2]int
+	Swap bool
+}
+
+// MultiAgentResult 多智能体寻路的结果
+type MultiAgentResult struct {
+	Paths    [][][2]int // Paths[i][t]为第i个智能体在t时刻的位置，所有路径补齐到相同长度
+	Found    bool       // 所有智能体都到达终点并且没有冲突
+	Expanded int        // 底层时空A*的出堆次数之和
+	Nodes    int        // CBS展开的高层节点数
+	Err      error
+}
+
+// Makespan 最后一个智能体到达终点的时刻
+func (r MultiAgentResult) Makespan() int {
+	if len(r.Paths) == 0 {
+		return 0
+	}
+	return len(r.Paths[0]) - 1
+}
+
+// SumOfCosts 每个智能体最后一次到达终点的时刻之和
+func (r MultiAgentResult) SumOfCosts() int {
+	sum := 0
+	for _, path := range r.Paths {
+		sum += arrivalTime(path)
+	}
+	return sum
+}
+
+// 最后一次到达终点（之后不再离开）的时刻
+func arrivalTime(path [][2]int) int {
+	t := len(path) - 1
+	for t > 0 && path[t-1] == path[len(path)-1] {
+		t--
+	}
+	return t
+}
+
+// t时刻的位置，超出路径长度时停在最后一格
+func posAt(path [][2]int, t int) [2]int {
+	if t >= len(path) {
+		return path[len(path)-1]
+	}
+	return path[t]
+}
+
+// FindConflicts 找出所有点冲突和交换冲突，路径长度不同时较短的停在最后一格
+func FindConflicts(paths [][][2]int) []Conflict {
+	conflicts := make([]Conflict, 0)
+	makespan := 0
+	for _, path := range paths {
+		makespan = max(makespan, len(path))
+	}
+	for t := 0; t < makespan; t++ {
+		for a := 0; a < len(paths); a++ {
+			for b := a + 1; b < len(paths); b++ {
+				pa, pb := posAt(paths[a], t), posAt(paths[b], t)
+				if pa == pb {
+					conflicts = append(conflicts, Conflict{A: a, B: b, Time: t, Pos: pa})
+					continue
+				}
+				na, nb := posAt(paths[a], t+1), posAt(paths[b], t+1)
+				if na == pb && nb == pa {
+					conflicts = append(conflicts, Conflict{A: a, B: b, Time: t, Pos: pa, To: na, Swap: true})
+				}
+			}
+		}
+	}
+	return conflicts
+}
+
+// 时空A*需要避开的时空约束
+type spaceTimeConstraints interface {
+	vertex(pos [2]int, t int) bool    // t时刻不能在pos
+	edge(from, to [2]int, t int) bool // t到t+1之间不能从from走到to
+	lastAt(pos [2]int) int            // 最后一个禁止停在pos的时刻，没有时为-1，永久禁止时为math.MaxInt
+}
+
+// 预约表：已规划的智能体占用的格子和移动
+type reservationTable struct {
+	cells  map[[3]int]bool // (行,列,时刻)
+	moves  map[[5]int]bool // (起点行,起点列,终点行,终点列,时刻)
+	last   map[[2]int]int  // 每个格子最后被预约的时刻
+	parked map[[2]int]bool // 停在终点不再离开的格子
+}
+
+func newReservationTable() *reservationTable {
+	return &reservationTable{
+		cells:  make(map[[3]int]bool),
+		moves:  make(map[[5]int]bool),
+		last:   make(map[[2]int]int),
+		parked: make(map[[2]int]bool),
+	}
+}
+
+// 预约从t0时刻开始的路径，park为true时最后一格永久占用
+func (rt *reservationTable) reserve(path [][2]int, t0 int, park bool) {
+	for i, pos := range path {
+		t := t0 + i
+		rt.cells[[3]int{pos[0], pos[1], t}] = true
+		if old, ok := rt.last[pos]; !ok || t > old {
+			rt.last[pos] = t
+		}
+		if i+1 < len(path) {
+			next := path[i+1]
+			rt.moves[[5]int{pos[0], pos[1], next[0], next[1], t}] = true
+		}
+	}
+	if park && len(path) > 0 {
+		rt.parked[path[len(path)-1]] = true
+	}
+}
+
+func (rt *reservationTable) vertex(pos [2]int, t int) bool {
+	if rt.parked[pos] {
+		if last, ok := rt.last[pos]; ok && t >= last {
+			return true
+		}
+	}
+	return rt.cells[[3]int{pos[0], pos[1], t}]
+}
+
+// 另一个智能体同时反方向走过这条边就是交换冲突
+func (rt *reservationTable) edge(from, to [2]int, t int) bool {
+	return rt.moves[[5]int{to[0], to[1], from[0], from[1], t}]
+}
+
+func (rt *reservationTable) lastAt(pos [2]int) int {
+	if rt.parked[pos] {
+		return math.MaxInt
+	}
+	if last, ok := rt.last[pos]; ok {
+		return last
+	}
+	return -1
+}
+
+type stNode struct {
+	pos    [2]int
+	t      int
+	g, f   int
+	parent *stNode
+}
+
+// 时空A*：从t0时刻的start出发，每步走到相邻格子或者原地等待。
+// window为0时找到一个到达goal后可以一直停留的时刻就结束；
+// window大于0时搜索到t0+window为止，停在终点等待不计代价，之后的代价用真实距离估计。
+// dist是从goal出发的积分场，用作启发函数
+func spaceTimeAStar(maze [][]int, start, goal [2]int, t0, window, maxTime int, dist [][]int, cons spaceTimeConstraints) ([][2]int, int) {
+	if dist[start[0]][start[1]] == FlowUnreachable || cons.vertex(start, t0) {
+		return nil, 0
+	}
+	// f相同时优先时刻更晚的节点，更快到达终点
+	open := NewHeap(func(a, b *stNode) bool { return a.f < b.f || a.f == b.f && a.t > b.t })
+	open.Push(&stNode{pos: start, t: t0, f: dist[start[0]][start[1]]})
+	closed := make(map[[3]int]bool)
+	goalFree := cons.lastAt(goal)
+	expanded := 0
+	moves := [][2]int{{0, 0}, {-1, 0}, {0, 1}, {1, 0}, {0, -1}}
+
+	for open.Len() > 0 {
+		cur := open.Pop()
+		key := [3]int{cur.pos[0], cur.pos[1], cur.t}
+		if closed[key] {
+			continue
+		}
+		closed[key] = true
+		expanded++
+
+		if window > 0 && cur.t == t0+window || window == 0 && cur.pos == goal && cur.t > goalFree {
+			path := make([][2]int, cur.t-t0+1)
+			for n := cur; n != nil; n = n.parent {
+				path[n.t-t0] = n.pos
+			}
+			return path, expanded
+		}
+		if cur.t >= maxTime {
+			continue
+		}
+
+		for _, mv := range moves {
+			next := [2]int{cur.pos[0] + mv[0], cur.pos[1] + mv[1]}
+			if !isWalkable(maze, next) || dist[next[0]][next[1]] == FlowUnreachable ||
+				closed[[3]int{next[0], next[1], cur.t + 1}] ||
+				cons.vertex(next, cur.t+1) || next != cur.pos && cons.edge(cur.pos, next, cur.t) {
+				continue
+			}
+			cost := 1
+			if window > 0 && next == goal && cur.pos == goal {
+				cost = 0
+			}
+			g := cur.g + cost
+			open.Push(&stNode{pos: next, t: cur.t + 1, g: g, f: g + dist[next[0]][next[1]], parent: cur})
+		}
+	}
+	return nil, expanded
+}
+
+// 检查起点终点并为每个智能体建立到终点的距离场
+func prepareAgents(maze [][]int, agents []Agent) ([][][]int, error) {
+	starts, goals := make(map[[2]int]bool), make(map[[2]int]bool)
+	dists := make([][][]int, len(agents))
+	for i, a := range agents {
+		if err := validateEnds(maze, a.Start, a.Goal); err != nil {
+			return nil, fmt.Errorf("agent %d: %w", i, err)
+		}
+		if starts[a.Start] || goals[a.Goal] {
+			return nil, fmt.Errorf("agent %d: %w", i, ErrAgentOverlap)
+		}
+		starts[a.Start], goals[a.Goal] = true, true
+		ff, err := NewFlowField(maze, [][2]int{a.Goal})
+		if err != nil {
+			return nil, err
+		}
+		dists[i] = ff.Cost
+	}
+	return dists, nil
+}
+
+// 时空搜索的时间上限：可通行格子数加上智能体数
+func multiAgentMaxTime(maze [][]int, agents []Agent) int {
+	free := 0
+	for _, row := range maze {
+		for _, v := range row {
+			if v == 0 {
+				free++
+			}
+		}
+	}
+	return free + len(agents)
+}
+
+// 补齐路径长度，检查结果
+func finishMultiAgent(res *MultiAgentResult, agents []Agent) {
+	makespan := 0
+	for _, path := range res.Paths {
+		makespan = max(makespan, len(path))
+	}
+	found := true
+	for i, path := range res.Paths {
+		for len(path) < makespan {
+			path = append(path, path[len(path)-1])
+		}
+		res.Paths[i] = path
+		if path[len(path)-1] != agents[i].Goal {
+			found = false
+		}
+	}
+	res.Found = found && len(FindConflicts(res.Paths)) == 0
+}
+
+// FindPathsCooperative Cooperative A*：按agents的顺序逐个规划，排在前面的优先。
+// 某个智能体找不到路径时留在起点，Found为false
+func FindPathsCooperative(maze [][]int, agents []Agent) MultiAgentResult {
+	dists, err := prepareAgents(maze, agents)
+	if err != nil {
+		return MultiAgentResult{Err: err}
+	}
+	var res MultiAgentResult
+	maxTime := multiAgentMaxTime(maze, agents)
+	rt := newReservationTable()
+	res.Paths = make([][][2]int, len(agents))
+	for i, a := range agents {
+		path, expanded := spaceTimeAStar(maze, a.Start, a.Goal, 0, 0, maxTime, dists[i], rt)
+		res.Expanded += expanded
+		if path == nil {
+			path = [][2]int{a.Start}
+		}
+		rt.reserve(path, 0, true)
+		res.Paths[i] = path
+	}
+	finishMultiAgent(&res, agents)
+	return res
+}
+
+// FindPathsWHCA WHCA*：每一轮按顺序在window步内规划并预约，所有智能体执行window/2步后从新位置重新规划，
+// 直到都到达终点或者超过时间上限。window<=1时按2计算
+func FindPathsWHCA(maze [][]int, agents []Agent, window int) MultiAgentResult {
+	dists, err := prepareAgents(maze, agents)
+	if err != nil {
+		return MultiAgentResult{Err: err}
+	}
+	window = max(window, 2)
+	step := window / 2
+	maxTime := multiAgentMaxTime(maze, agents)
+
+	var res MultiAgentResult
+	res.Paths = make([][][2]int, len(agents))
+	for i, a := range agents {
+		res.Paths[i] = [][2]int{a.Start}
+	}
+	arrived := func() bool {
+		for i, a := range agents {
+			if res.Paths[i][len(res.Paths[i])-1] != a.Goal {
+				return false
+			}
+		}
+		return true
+	}
+
+	for t0 := 0; t0 < maxTime && !arrived(); t0 += step {
+		rt := newReservationTable()
+		plans := make([][][2]int, len(agents))
+		for i, a := range agents {
+			pos := res.Paths[i][t0]
+			path, expanded := spaceTimeAStar(maze, pos, a.Goal, t0, window, t0+window, dists[i], rt)
+			res.Expanded += expanded
+			if path == nil {
+				// 被包围时原地等待，可能与优先级更高的智能体冲突，最后的检查会发现
+				path = make([][2]int, window+1)
+				for k := range path {
+					path[k] = pos
+				}
+			}
+			rt.reserve(path, t0, false)
+			plans[i] = path
+		}
+		for i := range agents {
+			res.Paths[i] = append(res.Paths[i], plans[i][1:step+1]...)
+		}
+	}
+
+	// 去掉所有智能体都停在终点之后多余的时刻
+	for makespan := len(res.Paths[0]) - 1; makespan > 0; makespan-- {
+		still := true
+		for _, path := range res.Paths {
+			if path[makespan] != path[makespan-1] {
+				still = false
+				break
+			}
+		}
+		if !still {
+			break
+		}
+		for i := range res.Paths {
+			res.Paths[i] = res.Paths[i][:makespan]
+		}
+	}
+	finishMultiAgent(&res, agents)
+	return res
+}
+
+// CBS中对一个智能体的约束，链表形式，子节点共享父节点的约束
+type cbsConstraint struct {
+	agent  int
+	pos    [2]int
+	to     [2]int
+	t      int
+	edge   bool
+	parent *cbsConstraint
+}
+
+// 一个智能体在CBS节点中需要遵守的约束
+type cbsAgentConstraints struct {
+	vertices map[[3]int]bool
+	edges    map[[5]int]bool
+	last     map[[2]int]int
+}
+
+func newCBSAgentConstraints(c *cbsConstraint, agent int) *cbsAgentConstraints {
+	ac := &cbsAgentConstraints{
+		vertices: make(map[[3]int]bool),
+		edges:    make(map[[5]int]bool),
+		last:     make(map[[2]int]int),
+	}
+	for ; c != nil; c = c.parent {
+		if c.agent != agent {
+			continue
+		}
+		if c.edge {
+			ac.edges[[5]int{c.pos[0], c.pos[1], c.to[0], c.to[1], c.t}] = true
+			continue
+		}
+		ac.vertices[[3]int{c.pos[0], c.pos[1], c.t}] = true
+		if old, ok := ac.last[c.pos]; !ok || c.t > old {
+			ac.last[c.pos] = c.t
+		}
+	}
+	return ac
+}
+
+func (ac *cbsAgentConstraints) vertex(pos [2]int, t int) bool {
+	return ac.vertices[[3]int{pos[0], pos[1], t}]
+}
+
+func (ac *cbsAgentConstraints) edge(from, to [2]int, t int) bool {
+	return ac.edges[[5]int{from[0], from[1], to[0], to[1], t}]
+}
+
+func (ac *cbsAgentConstraints) lastAt(pos [2]int) int {
+	if last, ok := ac.last[pos]; ok {
+		return last
+	}
+	return -1
+}
+
+type cbsNode struct {
+	constraints *cbsConstraint
+	paths       [][][2]int
+	cost        int
+}
+
+func cbsCost(paths [][][2]int) int {
+	cost := 0
+	for _, path := range paths {
+		cost += arrivalTime(path)
+	}
+	return cost
+}
+
+// 第一个冲突，没有时返回false
+func firstConflict(paths [][][2]int) (Conflict, bool) {
+	makespan := 0
+	for _, path := range paths {
+		makespan = max(makespan, len(path))
+	}
+	for t := 0; t < makespan; t++ {
+		for a := 0; a < len(paths); a++ {
+			for b := a + 1; b < len(paths); b++ {
+				pa, pb := posAt(paths[a], t), posAt(paths[b], t)
+				if pa == pb {
+					return Conflict{A: a, B: b, Time: t, Pos: pa}, true
+				}
+				na, nb := posAt(paths[a], t+1), posAt(paths[b], t+1)
+				if na == pb && nb == pa {
+					return Conflict{A: a, B: b, Time: t, Pos: pa, To: na, Swap: true}, true
+				}
+			}
+		}
+	}
+	return Conflict{}, false
+}
+
+// FindPathsCBS 冲突搜索（Conflict-Based Search），结果的总代价（SumOfCosts）最小；
+// 展开的高层节点超过maxNodes时返回ErrSearchLimit和目前代价最小的节点的路径（可能有冲突）
+func FindPathsCBS(maze [][]int, agents []Agent, maxNodes int) MultiAgentResult {
+	dists, err := prepareAgents(maze, agents)
+	if err != nil {
+		return MultiAgentResult{Err: err}
+	}
+	var res MultiAgentResult
+	maxTime := multiAgentMaxTime(maze, agents)
+
+	plan := func(constraints *cbsConstraint, agent int) [][2]int {
+		a := agents[agent]
+		path, expanded := spaceTimeAStar(maze, a.Start, a.Goal, 0, 0, maxTime, dists[agent], newCBSAgentConstraints(constraints, agent))
+		res.Expanded += expanded
+		return path
+	}
+
+	root := &cbsNode{paths: make([][][2]int, len(agents))}
+	for i := range agents {
+		if root.paths[i] = plan(nil, i); root.paths[i] == nil {
+			// 单独规划都到不了终点
+			root.paths[i] = [][2]int{agents[i].Start}
+		}
+	}
+	root.cost = cbsCost(root.paths)
+
+	open := NewHeap(func(a, b *cbsNode) bool { return a.cost < b.cost })
+	open.Push(root)
+	best := root
+	for open.Len() > 0 {
+		node := open.Pop()
+		best = node
+		res.Nodes++
+		conflict, ok := firstConflict(node.paths)
+		if !ok {
+			break
+		}
+		if res.Nodes >= maxNodes {
+			res.Err = ErrSearchLimit
+			break
+		}
+		// 分成两支，分别禁止冲突中的一方
+		for _, agent := range []int{conflict.A, conflict.B} {
+			c := &cbsConstraint{agent: agent, pos: conflict.Pos, t: conflict.Time, parent: node.constraints}
+			if conflict.Swap {
+				c.edge = true
+				if agent == conflict.A {
+					c.pos, c.to = conflict.Pos, conflict.To
+				} else {
+					c.pos, c.to = conflict.To, conflict.Pos
+				}
+			}
+			path := plan(c, agent)
+			if path == nil {
+				continue
+			}
+			child := &cbsNode{constraints: c, paths: append([][][2]int(nil), node.paths...)}
+			child.paths[agent] = path
+			child.cost = cbsCost(child.paths)
+			open.Push(child)
+		}
+	}
+
+	res.Paths = make([][][2]int, len(agents))
+	for i, path := range best.paths {
+		res.Paths[i] = append([][2]int(nil), path...)
+	}
+	finishMultiAgent(&res, agents)
+	return res
+}
@@ -0,0 +1,148 @@
+package pathfind
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// 与FindConflicts独立的检查：每一步走到相邻的可通行格子或者原地等待，
+// 任何时刻没有两个智能体在同一格，也没有两个智能体在相邻两格之间互换位置
+func checkMultiAgentPlan(t *testing.T, name string, maze [][]int, agents []Agent, res MultiAgentResult) {
+	t.Helper()
+	if res.Err != nil && res.Err != ErrSearchLimit {
+		t.Fatalf("%s: err %v", name, res.Err)
+	}
+	if len(res.Paths) != len(agents) {
+		t.Fatalf("%s: %d paths for %d agents", name, len(res.Paths), len(agents))
+	}
+	for i, path := range res.Paths {
+		if len(path) == 0 || path[0] != agents[i].Start {
+			t.Fatalf("%s: agent %d path %v does not start at %v", name, i, path, agents[i].Start)
+		}
+		if len(path) != len(res.Paths[0]) {
+			t.Fatalf("%s: agent %d path length %d, agent 0 %d", name, i, len(path), len(res.Paths[0]))
+		}
+		for k := 1; k < len(path); k++ {
+			if !isWalkable(maze, path[k]) || manhattanDistance(path[k-1], path[k]) > 1 {
+				t.Fatalf("%s: agent %d invalid move %v->%v at t=%d", name, i, path[k-1], path[k], k-1)
+			}
+		}
+		if res.Found && path[len(path)-1] != agents[i].Goal {
+			t.Fatalf("%s: agent %d ends at %v, goal %v", name, i, path[len(path)-1], agents[i].Goal)
+		}
+	}
+	if !res.Found {
+		return
+	}
+	for k := range res.Paths[0] {
+		for a := range res.Paths {
+			for b := a + 1; b < len(res.Paths); b++ {
+				pa, pb := res.Paths[a][k], res.Paths[b][k]
+				if pa == pb {
+					t.Fatalf("%s: agents %d and %d both at %v at t=%d", name, a, b, pa, k)
+				}
+				if k+1 < len(res.Paths[0]) && res.Paths[a][k+1] == pb && res.Paths[b][k+1] == pa {
+					t.Fatalf("%s: agents %d and %d swap %v<->%v at t=%d", name, a, b, pa, pb, k)
+				}
+			}
+		}
+	}
+}
+
+// 在可通行的格子中随机挑选互不相同的起点和终点
+func randomAgents(rng *rand.Rand, maze [][]int, n int) []Agent {
+	var free [][2]int
+	for r := range maze {
+		for c := range maze[r] {
+			if maze[r][c] == 0 {
+				free = append(free, [2]int{r, c})
+			}
+		}
+	}
+	if len(free) < n {
+		return nil
+	}
+	starts, goals := rng.Perm(len(free)), rng.Perm(len(free))
+	agents := make([]Agent, n)
+	for i := range agents {
+		agents[i] = Agent{Start: free[starts[i]], Goal: free[goals[i]]}
+	}
+	return agents
+}
+
+func TestMultiAgentPlansConflictFree(t *testing.T) {
+	rng := rand.New(rand.NewSource(48))
+	for i := 0; i < 150; i++ {
+		maze := randomGrid(rng, 4+rng.Intn(8), 4+rng.Intn(8), rng.Float64()*0.25)
+		agents := randomAgents(rng, maze, 2+rng.Intn(5))
+		if agents == nil {
+			continue
+		}
+
+		ca := FindPathsCooperative(maze, agents)
+		checkMultiAgentPlan(t, "cooperative", maze, agents, ca)
+		whca := FindPathsWHCA(maze, agents, 2+rng.Intn(8))
+		checkMultiAgentPlan(t, "whca", maze, agents, whca)
+		cbs := FindPathsCBS(maze, agents, 2000)
+		checkMultiAgentPlan(t, "cbs", maze, agents, cbs)
+
+		// CBS的总代价最小：不低于各自单独走最短路径的总和，不高于其他方法找到的方案
+		if !cbs.Found {
+			continue
+		}
+		lower := 0
+		for _, a := range agents {
+			lower += len(FindPathDijkstra(maze, a.Start, a.Goal).Path) - 1
+		}
+		if cbs.SumOfCosts() < lower {
+			t.Fatalf("cbs sum of costs %d below the single-agent lower bound %d", cbs.SumOfCosts(), lower)
+		}
+		for name, other := range map[string]MultiAgentResult{"cooperative": ca, "whca": whca} {
+			if other.Found && other.SumOfCosts() < cbs.SumOfCosts() {
+				t.Fatalf("%s sum of costs %d below cbs %d, agents %v", name, other.SumOfCosts(), cbs.SumOfCosts(), agents)
+			}
+		}
+	}
+}
+
+// 走廊里迎面相遇的两个智能体：必须有一个先退进岔口让路。
+// 按固定优先级规划的方法不一定找得到，只要求给出的路径合法；CBS是完备的，一定能找到
+func TestMultiAgentCorridorSwap(t *testing.T) {
+	maze := parseGrid(
+		".....",
+		"#.###")
+	agents := []Agent{{Start: [2]int{0, 0}, Goal: [2]int{0, 4}}, {Start: [2]int{0, 4}, Goal: [2]int{0, 0}}}
+	for name, res := range map[string]MultiAgentResult{
+		"cooperative": FindPathsCooperative(maze, agents),
+		"whca":        FindPathsWHCA(maze, agents, 8),
+		"cbs":         FindPathsCBS(maze, agents, 1000),
+	} {
+		checkMultiAgentPlan(t, name, maze, agents, res)
+		if name == "cbs" && !res.Found {
+			t.Fatal("cbs: no plan for the corridor swap")
+		}
+	}
+}
+
+func TestFindConflicts(t *testing.T) {
+	paths := [][][2]int{
+		{{0, 0}, {0, 1}, {0, 2}},
+		{{0, 2}, {0, 1}},         // t=1与0号同在(0,1)
+		{{1, 1}, {1, 2}, {1, 3}}, // 与3号在t=1到t=2之间互换
+		{{1, 4}, {1, 3}, {1, 2}},
+		{{0, 3}}, // 停在原地，0号t=2时不到(0,3)，没有冲突
+	}
+	conflicts := FindConflicts(paths)
+	want := []Conflict{
+		{A: 0, B: 1, Time: 1, Pos: [2]int{0, 1}},
+		{A: 2, B: 3, Time: 1, Pos: [2]int{1, 2}, To: [2]int{1, 3}, Swap: true},
+	}
+	if len(conflicts) != len(want) {
+		t.Fatalf("conflicts %+v, want %+v", conflicts, want)
+	}
+	for i := range want {
+		if conflicts[i] != want[i] {
+			t.Fatalf("conflict %d = %+v, want %+v", i, conflicts[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package pathfind
+
+import (
+	"fmt"
+)
+
+// FloorPos 多层地图中的一个位置
+type FloorPos struct {
+	Floor int    // 楼层
+	Pos   [2]int // 层内位置
+}
+
+// Portal 连接两个楼层位置的传送边（例如楼梯），双向可走
+type Portal struct {
+	A, B FloorPos
+	Cost int // 通过传送边的代价，<=0时按1计算
+}
+
+type MultiFloorPathResult struct {
+	Path  []FloorPos
+	Cost  int
+	Check int
+	Found bool  // 是否到达终点
+	Err   error // 起点或终点的楼层不存在、越界或者不可通行
+}
+
+// MNode 表示多层搜索中的一个节点
+type MNode struct {
+	pos    FloorPos // 位置
+	g      int      // 从起点到当前点的实际代价
+	f      int      // f = g + h
+	parent *MNode   // 父节点
+}
+
+// 多层之间的启发函数：忽略墙之后的最短距离。
+// 层内两点之间按曼哈顿距离，换层只能通过传送边，传送边两端可以在不同位置，
+// 所以先在传送边端点上做一次反向Dijkstra，得到每个端点到终点的下界；
+// 任意位置的启发值取直接走到终点（同层时）和先走到本层某个端点两者的最小值。
+// 这是放宽后的图上的准确距离，因此既可采纳又一致，A*不需要重新打开关闭的节点
+type floorHeuristic struct {
+	end     FloorPos
+	byFloor map[int][]FloorPos // 每层的传送边端点
+	bound   map[FloorPos]int   // 端点到终点的下界，到不了终点的端点不在表中
+}
+
+func newFloorHeuristic(portals []Portal, end FloorPos) *floorHeuristic {
+	h := &floorHeuristic{end: end, byFloor: make(map[int][]FloorPos), bound: make(map[FloorPos]int)}
+	edges := make(map[FloorPos][]Portal)
+	for _, p := range portals {
+		p.Cost = max(p.Cost, 1)
+		edges[p.A] = append(edges[p.A], p)
+		edges[p.B] = append(edges[p.B], Portal{A: p.B, B: p.A, Cost: p.Cost})
+	}
+	for pos := range edges {
+		h.byFloor[pos.Floor] = append(h.byFloor[pos.Floor], pos)
+	}
+
+	// 端点数量不多，直接用O(n^2)的Dijkstra
+	dist := make(map[FloorPos]int)
+	for pos := range edges {
+		if pos.Floor == end.Floor {
+			dist[pos] = manhattanDistance(pos.Pos, end.Pos)
+		}
+	}
+	for len(dist) > 0 {
+		var cur FloorPos
+		best := -1
+		for pos, d := range dist {
+			if best < 0 || d < best {
+				cur, best = pos, d
+			}
+		}
+		delete(dist, cur)
+		h.bound[cur] = best
+		relax := func(pos FloorPos, d int) {
+			if _, done := h.bound[pos]; done {
+				return
+			}
+			if old, ok := dist[pos]; !ok || d < old {
+				dist[pos] = d
+			}
+		}
+		// 反向走传送边：从另一端走过来的代价
+		for _, p := range edges[cur] {
+			relax(p.B, best+p.Cost)
+		}
+		for _, pos := range h.byFloor[cur.Floor] {
+			relax(pos, best+manhattanDistance(pos.Pos, cur.Pos))
+		}
+	}
+	return h
+}
+
+// 启发值，忽略墙也到不了终点时返回false
+func (h *floorHeuristic) estimate(pos FloorPos) (int, bool) {
+	best, ok := 0, false
+	if pos.Floor == h.end.Floor {
+		best, ok = manhattanDistance(pos.Pos, h.end.Pos), true
+	}
+	for _, x := range h.byFloor[pos.Floor] {
+		if b, reach := h.bound[x]; reach {
+			if d := manhattanDistance(pos.Pos, x.Pos) + b; !ok || d < best {
+				best, ok = d, true
+			}
+		}
+	}
+	return best, ok
+}
+
+// FindPathMultiFloor 使用A*算法在多层地图中寻路，楼梯等传送点作为额外的边
+// floors[i]是第i层的地图，0表示可通行；找不到路径时Path为空
+func FindPathMultiFloor(floors [][][]int, portals []Portal, start, end FloorPos) MultiFloorPathResult {
+	for _, p := range []FloorPos{start, end} {
+		if p.Floor < 0 || p.Floor >= len(floors) {
+			return MultiFloorPathResult{Path: make([]FloorPos, 0), Err: fmt.Errorf("floor %d: %w", p.Floor, ErrOutOfBounds)}
+		}
+	}
+	if err := validatePos(floors[start.Floor], "start", start.Pos); err != nil {
+		return MultiFloorPathResult{Path: make([]FloorPos, 0), Err: err}
+	}
+	if err := validatePos(floors[end.Floor], "end", end.Pos); err != nil {
+		return MultiFloorPathResult{Path: make([]FloorPos, 0), Err: err}
+	}
+
+	// 建立传送点索引
+	links := make(map[FloorPos][]Portal)
+	for _, p := range portals {
+		links[p.A] = append(links[p.A], p)
+		links[p.B] = append(links[p.B], Portal{A: p.B, B: p.A, Cost: p.Cost})
+	}
+
+	heuristic := newFloorHeuristic(portals, end)
+	openList := NewHeap(func(a, b *MNode) bool { return a.f < b.f })
+	closedSet := make(map[FloorPos]bool)
+
+	if h, ok := heuristic.estimate(start); ok {
+		openList.Push(&MNode{pos: start, f: h})
+	}
+
+	// 定义方向：上、右、下、左
+	dirs := [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}}
+
+	var res MultiFloorPathResult
+	var found *MNode
+
+	for openList.Len() > 0 {
+		current := openList.Pop()
+		if current.pos == end {
+			found = current
+			break
+		}
+		if closedSet[current.pos] {
+			continue
+		}
+		closedSet[current.pos] = true
+
+		push := func(next FloorPos, cost int) {
+			res.Check++
+			if closedSet[next] {
+				return
+			}
+			h, ok := heuristic.estimate(next)
+			if !ok {
+				return
+			}
+			neighbor := &MNode{
+				pos:    next,
+				g:      current.g + cost,
+				parent: current,
+			}
+			neighbor.f = neighbor.g + h
+			res.Cost++
+			openList.Push(neighbor)
+		}
+
+		// 层内移动
+		maze := floors[current.pos.Floor]
+		for _, dir := range dirs {
+			nextPos := [2]int{current.pos.Pos[0] + dir[0], current.pos.Pos[1] + dir[1]}
+			if !isWalkable(maze, nextPos) {
+				continue
+			}
+			push(FloorPos{Floor: current.pos.Floor, Pos: nextPos}, 1)
+		}
+
+		// 通过楼梯换层
+		for _, p := range links[current.pos] {
+			if p.B.Floor < 0 || p.B.Floor >= len(floors) || !isWalkable(floors[p.B.Floor], p.B.Pos) {
+				continue
+			}
+			push(p.B, max(p.Cost, 1))
+		}
+	}
+
+	// 重建路径
+	res.Path = make([]FloorPos, 0)
+	res.Found = found != nil
+	for node := found; node != nil; node = node.parent {
+		res.Path = append([]FloorPos{node.pos}, res.Path...)
+	}
+	return res
+}
@@ -0,0 +1,196 @@
+package pathfind
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// 在层内移动和传送边组成的图上直接做Dijkstra，作为多层A*的参照
+func bruteForceMultiFloor(floors [][][]int, portals []Portal, start, end FloorPos) (int, bool) {
+	dist := map[FloorPos]int{start: 0}
+	done := make(map[FloorPos]bool)
+	for {
+		var cur FloorPos
+		best := -1
+		for pos, d := range dist {
+			if !done[pos] && (best < 0 || d < best) {
+				cur, best = pos, d
+			}
+		}
+		if best < 0 {
+			return 0, false
+		}
+		if cur == end {
+			return best, true
+		}
+		done[cur] = true
+		relax := func(next FloorPos, cost int) {
+			if next.Floor < 0 || next.Floor >= len(floors) || !isWalkable(floors[next.Floor], next.Pos) {
+				return
+			}
+			if old, ok := dist[next]; !ok || best+cost < old {
+				dist[next] = best + cost
+			}
+		}
+		for _, dir := range [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}} {
+			relax(FloorPos{cur.Floor, [2]int{cur.Pos[0] + dir[0], cur.Pos[1] + dir[1]}}, 1)
+		}
+		for _, p := range portals {
+			if p.A == cur {
+				relax(p.B, max(p.Cost, 1))
+			}
+			if p.B == cur {
+				relax(p.A, max(p.Cost, 1))
+			}
+		}
+	}
+}
+
+// 路径的代价，同时检查每一步是层内相邻的可通行格子或者一条传送边
+func multiFloorPathCost(t *testing.T, floors [][][]int, portals []Portal, path []FloorPos) int {
+	t.Helper()
+	cost := 0
+	for i := 1; i < len(path); i++ {
+		a, b := path[i-1], path[i]
+		if !isWalkable(floors[b.Floor], b.Pos) {
+			t.Fatalf("path enters blocked cell %v", b)
+		}
+		if a.Floor == b.Floor && manhattanDistance(a.Pos, b.Pos) == 1 {
+			cost++
+			continue
+		}
+		step := -1
+		for _, p := range portals {
+			if p.A == a && p.B == b || p.B == a && p.A == b {
+				if c := max(p.Cost, 1); step < 0 || c < step {
+					step = c
+				}
+			}
+		}
+		if step < 0 {
+			t.Fatalf("invalid step %v -> %v", a, b)
+		}
+		cost += step
+	}
+	return cost
+}
+
+func TestMultiFloorCrossFloor(t *testing.T) {
+	floors := [][][]int{
+		parseGrid(
+			"...",
+			".#.",
+			"..."),
+		parseGrid(
+			"...",
+			"...",
+			"..."),
+	}
+	portals := []Portal{{A: FloorPos{0, [2]int{2, 2}}, B: FloorPos{1, [2]int{2, 2}}}}
+	start, end := FloorPos{0, [2]int{0, 0}}, FloorPos{1, [2]int{0, 2}}
+	res := FindPathMultiFloor(floors, portals, start, end)
+	if !res.Found || res.Err != nil {
+		t.Fatalf("found=%v err=%v", res.Found, res.Err)
+	}
+	if res.Path[0] != start || res.Path[len(res.Path)-1] != end {
+		t.Fatalf("path %v does not connect %v and %v", res.Path, start, end)
+	}
+	// 走到楼梯4步，换层1步，上楼后再走2步
+	if cost := multiFloorPathCost(t, floors, portals, res.Path); cost != 7 {
+		t.Fatalf("path cost %d, want 7: %v", cost, res.Path)
+	}
+}
+
+// 传送边两端位置不同时，启发值不能按两端同位置估计，否则会错过经过远处楼梯的最短路径
+func TestMultiFloorPortalAtDifferentPositions(t *testing.T) {
+	open := func() [][]int { return randomGrid(rand.New(rand.NewSource(0)), 11, 11, 0) }
+	floors := [][][]int{open(), open()}
+	portals := []Portal{
+		{A: FloorPos{0, [2]int{0, 0}}, B: FloorPos{1, [2]int{10, 10}}, Cost: 1}, // 楼上一端在远处
+		{A: FloorPos{0, [2]int{0, 2}}, B: FloorPos{1, [2]int{0, 2}}, Cost: 1},
+	}
+	start, end := FloorPos{0, [2]int{0, 1}}, FloorPos{1, [2]int{10, 9}}
+	res := FindPathMultiFloor(floors, portals, start, end)
+	if cost := multiFloorPathCost(t, floors, portals, res.Path); !res.Found || cost != 3 {
+		t.Fatalf("found=%v cost %d, want 3 via the far stair: %v", res.Found, cost, res.Path)
+	}
+}
+
+func TestMultiFloorBlockedStair(t *testing.T) {
+	floors := [][][]int{
+		parseGrid("..."),
+		parseGrid(".#."),
+	}
+	stairs := []Portal{
+		{A: FloorPos{0, [2]int{0, 0}}, B: FloorPos{1, [2]int{0, 0}}},
+		{A: FloorPos{0, [2]int{0, 1}}, B: FloorPos{1, [2]int{0, 1}}}, // 楼上这一端是墙
+	}
+	start, end := FloorPos{0, [2]int{0, 2}}, FloorPos{1, [2]int{0, 0}}
+
+	res := FindPathMultiFloor(floors, stairs, start, end)
+	if cost := multiFloorPathCost(t, floors, stairs, res.Path); !res.Found || cost != 3 {
+		t.Fatalf("found=%v cost %d, want 3 via the open stair: %v", res.Found, cost, res.Path)
+	}
+	if res := FindPathMultiFloor(floors, stairs[1:], start, end); res.Found || res.Err != nil || len(res.Path) != 0 {
+		t.Fatalf("only stair blocked: found=%v err=%v path %v", res.Found, res.Err, res.Path)
+	}
+}
+
+func TestMultiFloorInvalidEnds(t *testing.T) {
+	floors := [][][]int{parseGrid(".#")}
+	ok := FloorPos{0, [2]int{0, 0}}
+	tests := []struct {
+		name       string
+		start, end FloorPos
+		err        error
+	}{
+		{"楼层不存在", ok, FloorPos{1, [2]int{0, 0}}, ErrOutOfBounds},
+		{"负的楼层", FloorPos{-1, [2]int{0, 0}}, ok, ErrOutOfBounds},
+		{"起点越界", FloorPos{0, [2]int{0, 5}}, ok, ErrOutOfBounds},
+		{"终点是墙", ok, FloorPos{0, [2]int{0, 1}}, ErrBlocked},
+	}
+	for _, tt := range tests {
+		res := FindPathMultiFloor(floors, nil, tt.start, tt.end)
+		if res.Found || !errors.Is(res.Err, tt.err) || len(res.Path) != 0 {
+			t.Errorf("%s: found=%v err=%v, want %v", tt.name, res.Found, res.Err, tt.err)
+		}
+	}
+}
+
+// 随机的多层地图和传送边（两端位置、代价都随机），代价与直接在图上做Dijkstra相同
+func TestMultiFloorOptimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(27))
+	for i := 0; i < 300; i++ {
+		h, w := 2+rng.Intn(8), 2+rng.Intn(8)
+		floors := make([][][]int, 1+rng.Intn(4))
+		for f := range floors {
+			floors[f] = randomGrid(rng, h, w, rng.Float64()*0.35)
+		}
+		randomPos := func() FloorPos {
+			return FloorPos{rng.Intn(len(floors)), [2]int{rng.Intn(h), rng.Intn(w)}}
+		}
+		portals := make([]Portal, rng.Intn(6))
+		for k := range portals {
+			portals[k] = Portal{A: randomPos(), B: randomPos(), Cost: rng.Intn(6)}
+		}
+		start, end := randomPos(), randomPos()
+		floors[start.Floor][start.Pos[0]][start.Pos[1]] = 0
+		floors[end.Floor][end.Pos[0]][end.Pos[1]] = 0
+
+		res := FindPathMultiFloor(floors, portals, start, end)
+		want, found := bruteForceMultiFloor(floors, portals, start, end)
+		if res.Err != nil || res.Found != found {
+			t.Fatalf("case %d %v->%v: found=%v err=%v, dijkstra found=%v", i, start, end, res.Found, res.Err, found)
+		}
+		if !found {
+			continue
+		}
+		if res.Path[0] != start || res.Path[len(res.Path)-1] != end {
+			t.Fatalf("case %d: path %v does not connect %v and %v", i, res.Path, start, end)
+		}
+		if cost := multiFloorPathCost(t, floors, portals, res.Path); cost != want {
+			t.Fatalf("case %d %v->%v: cost %d, dijkstra %d, portals %v", i, start, end, cost, want, portals)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package pathfind
+
+import (
+	"fmt"
+)
+
+// Pathfinder 在网格上寻路的算法，maze中0为通路、1为墙，位置为（行，列）
+type Pathfinder interface {
+	FindPath(maze [][]int, start, end [2]int) PathFindResult
+}
+
+// Preprocessor 需要预处理地图的算法（如JPS+）额外实现的接口
+// Preprocess之后在同一张地图上多次FindPath都直接使用预处理的结果，地图修改后需要重新调用；
+// 没有预处理过或者换了地图时，FindPath会自动先做预处理
+type Preprocessor interface {
+	Pathfinder
+	Preprocess(maze [][]int)
+}
+
+// PathfinderFunc 把寻路函数适配成Pathfinder
+type PathfinderFunc func(maze [][]int, start, end [2]int) PathFindResult
+
+func (f PathfinderFunc) FindPath(maze [][]int, start, end [2]int) PathFindResult {
+	return f(maze, start, end)
+}
+
+// JPSPlus 带预处理的JPS+寻路
+type JPSPlus struct {
+	pm *PreprocessedMaze
+}
+
+func (j *JPSPlus) Preprocess(maze [][]int) {
+	j.pm = PreprocessMaze(maze)
+}
+
+func (j *JPSPlus) FindPath(maze [][]int, start, end [2]int) PathFindResult {
+	if j.pm == nil || !sameMaze(j.pm.maze, maze) {
+		j.Preprocess(maze)
+	}
+	return j.pm.FindPathJPSPlus(start, end)
+}
+
+// 是否为同一张地图（同一块内存），不比较内容
+func sameMaze(a, b [][]int) bool {
+	return len(a) == len(b) && len(a) > 0 && len(a[0]) == len(b[0]) && len(a[0]) > 0 && &a[0][0] == &b[0][0]
+}
+
+// Algorithm 注册的寻路算法
+type Algorithm struct {
+	Name  string            // 注册名，用于URL参数等
+	Title string            // 显示的名字
+	New   func() Pathfinder // 创建一个新实例，带预处理状态的算法每次都返回独立的对象
+}
+
+var (
+	algorithms     []Algorithm
+	algorithmIndex = make(map[string]int)
+)
+
+// Register 注册一个寻路算法，名字重复时panic
+func Register(name, title string, factory func() Pathfinder) {
+	if _, ok := algorithmIndex[name]; ok {
+		panic(fmt.Sprintf("pathfind: algorithm %q registered twice", name))
+	}
+	algorithmIndex[name] = len(algorithms)
+	algorithms = append(algorithms, Algorithm{Name: name, Title: title, New: factory})
+}
+
+// Algorithms 按注册顺序返回所有寻路算法
+func Algorithms() []Algorithm {
+	return append([]Algorithm(nil), algorithms...)
+}
+
+// Lookup 按名字查找寻路算法
+func Lookup(name string) (Algorithm, bool) {
+	i, ok := algorithmIndex[name]
+	if !ok {
+		return Algorithm{}, false
+	}
+	return algorithms[i], true
+}
+
+// 内置算法，注册顺序即页面上的显示顺序；注册的算法都要通过TestAlgorithmsConformance（与Dijkstra对比路径合法和最短）
+func init() {
+	stateless := func(f PathfinderFunc) func() Pathfinder {
+		return func() Pathfinder { return f }
+	}
+	Register("astar", "A*", stateless(FindPathAStar))
+	Register("dijkstra", "Dijkstra", stateless(FindPathDijkstra))
+	Register("bidijkstra", "双向Dijkstra", stateless(FindPathBiDijkstra))
+	Register("biastar", "双向A*", stateless(FindPathBiAStar))
+	Register("bestfirst", "BestFirst", stateless(FindPathBestFirst))
+	Register("jps", "JPS", stateless(FindPathJPS))
+	Register("jpsplus", "JPS+", func() Pathfinder { return &JPSPlus{} })
+	Register("theta", "Theta*", stateless(FindPathThetaStar))
+	Register("lazytheta", "Lazy Theta*", stateless(FindPathLazyThetaStar))
+	Register("hpa", "HPA*", func() Pathfinder { return &HPA{} })
+	Register("dstarlite", "D* Lite", stateless(FindPathDStarLite))
+	Register("alt", "ALT", func() Pathfinder { return &ALT{} })
+}
@@ -0,0 +1,178 @@
+package pathfind
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// 不保证最短路径的算法，只检查路径合法
+var nonOptimal = map[string]bool{
+	"bestfirst": true,
+	"theta":     true,
+	"lazytheta": true,
+	"hpa":       true,
+}
+
+func parseGrid(rows ...string) [][]int {
+	maze := make([][]int, len(rows))
+	for r, row := range rows {
+		maze[r] = make([]int, len(row))
+		for c, ch := range row {
+			if ch == '#' {
+				maze[r][c] = 1
+			}
+		}
+	}
+	return maze
+}
+
+func randomGrid(rng *rand.Rand, height, width int, wallProb float64) [][]int {
+	maze := make([][]int, height)
+	for r := range maze {
+		maze[r] = make([]int, width)
+		for c := range maze[r] {
+			if rng.Float64() < wallProb {
+				maze[r][c] = 1
+			}
+		}
+	}
+	return maze
+}
+
+// 检查res与Dijkstra的结果一致：是否找到相同，路径从start到end、逐步相邻且可以通行，最短算法的长度相同
+func checkAgainstDijkstra(t *testing.T, name string, maze [][]int, start, end [2]int, res PathFindResult) {
+	t.Helper()
+	want := FindPathDijkstra(maze, start, end)
+	if res.Err != nil {
+		t.Fatalf("%s %v->%v: err %v", name, start, end, res.Err)
+	}
+	if res.Found != want.Found {
+		t.Fatalf("%s %v->%v: found=%v, dijkstra found=%v, path %v", name, start, end, res.Found, want.Found, res.Path)
+	}
+	if !res.Found {
+		return
+	}
+	if !validPath(maze, res.Path) || res.Path[0] != start || res.Path[len(res.Path)-1] != end {
+		t.Fatalf("%s %v->%v: invalid path %v", name, start, end, res.Path)
+	}
+	if !nonOptimal[name] && len(res.Path) != len(want.Path) {
+		t.Fatalf("%s %v->%v: path length %d, dijkstra %d", name, start, end, len(res.Path), len(want.Path))
+	}
+}
+
+func TestAlgorithmsAgainstDijkstra(t *testing.T) {
+	tests := []struct {
+		name       string
+		maze       [][]int
+		start, end [2]int
+	}{
+		{"墙隔开的终点", parseGrid(
+			".#.",
+			".#.",
+			".#."), [2]int{0, 0}, [2]int{1, 2}},
+		{"起点即终点", parseGrid(
+			"..",
+			".."), [2]int{1, 1}, [2]int{1, 1}},
+		{"开阔地图", parseGrid(
+			"......",
+			"......",
+			"......"), [2]int{0, 0}, [2]int{2, 5}},
+		{"绕过墙", parseGrid(
+			"......",
+			".####.",
+			"....#.",
+			"###.#.",
+			"......"), [2]int{2, 0}, [2]int{4, 0}},
+		{"竖直后转向", parseGrid(
+			"#.....",
+			"#.###.",
+			"..#...",
+			"#...#."), [2]int{0, 1}, [2]int{3, 3}},
+		{"只能从强迫邻居转弯", parseGrid(
+			"...#...",
+			"#.##.#.",
+			"#......",
+			"####.##"), [2]int{0, 0}, [2]int{3, 4}},
+	}
+	for _, tt := range tests {
+		for _, algo := range Algorithms() {
+			res := algo.New().FindPath(tt.maze, tt.start, tt.end)
+			checkAgainstDijkstra(t, tt.name+"/"+algo.Name, tt.maze, tt.start, tt.end, res)
+		}
+	}
+}
+
+func TestJPSRandomMazes(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	for i := 0; i < 1000; i++ {
+		maze := randomGrid(rng, 2+rng.Intn(20), 2+rng.Intn(20), rng.Float64()*0.5)
+		start := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+		end := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+		maze[start[0]][start[1]], maze[end[0]][end[1]] = 0, 0
+		checkAgainstDijkstra(t, "jps", maze, start, end, FindPathJPS(maze, start, end))
+		checkAgainstDijkstra(t, "jpsplus", maze, start, end, PreprocessMaze(maze).FindPathJPSPlus(start, end))
+	}
+}
+
+func TestJPSRejectsInvalidPath(t *testing.T) {
+	maze := parseGrid(".#.")
+	res := jpsResult(maze, PathFindResult{}, &Node{pos: [2]int{0, 2}, parent: &Node{pos: [2]int{0, 0}}})
+	if res.Found || res.Err != ErrInvalidPath || len(res.Path) != 0 {
+		t.Fatalf("path through wall accepted: %+v", res)
+	}
+}
+
+// 所有注册的算法在随机地图上都要给出合法的路径，最短路径算法的长度与Dijkstra相同；
+// 同一个实例连续在多张地图上使用，顺便检查预处理状态会随地图更新
+func TestAlgorithmsConformance(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, algo := range Algorithms() {
+		p := algo.New()
+		for i := 0; i < 300; i++ {
+			maze := randomGrid(rng, 2+rng.Intn(30), 2+rng.Intn(30), rng.Float64()*0.45)
+			start := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+			end := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+			maze[start[0]][start[1]], maze[end[0]][end[1]] = 0, 0
+			checkAgainstDijkstra(t, algo.Name, maze, start, end, p.FindPath(maze, start, end))
+		}
+	}
+}
+
+func TestAlgorithmsRejectInvalidEnds(t *testing.T) {
+	maze := parseGrid(
+		"..",
+		".#")
+	for _, algo := range Algorithms() {
+		if res := algo.New().FindPath(maze, [2]int{0, 0}, [2]int{1, 1}); res.Found || !errors.Is(res.Err, ErrBlocked) {
+			t.Errorf("%s: end on wall: found=%v err=%v", algo.Name, res.Found, res.Err)
+		}
+		if res := algo.New().FindPath(maze, [2]int{-1, 0}, [2]int{0, 1}); res.Found || !errors.Is(res.Err, ErrOutOfBounds) {
+			t.Errorf("%s: start out of bounds: found=%v err=%v", algo.Name, res.Found, res.Err)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, algo := range Algorithms() {
+		if seen[algo.Name] {
+			t.Fatalf("duplicate algorithm %s", algo.Name)
+		}
+		seen[algo.Name] = true
+		if got, ok := Lookup(algo.Name); !ok || got.Title != algo.Title {
+			t.Fatalf("Lookup(%s) = %v, %v", algo.Name, got, ok)
+		}
+		if a, b := algo.New(), algo.New(); isPreprocessor(a) && a == b {
+			t.Fatalf("%s: New returns shared preprocessor state", algo.Name)
+		}
+	}
+	if _, ok := Lookup("no-such-algorithm"); ok {
+		t.Fatal("Lookup of unknown name succeeded")
+	}
+}
+
+func isPreprocessor(p Pathfinder) bool {
+	_, ok := p.(Preprocessor)
+	return ok
+}
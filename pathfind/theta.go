@@ -0,0 +1,250 @@
+package pathfind
+
+import (
+	"math"
+)
+
+// 任意角度寻路：Theta*和Lazy Theta*
+// 在8方向网格上搜索（斜走时两侧的格子都必须可以通行），节点的父节点不必相邻，
+// 只要两个格子中心之间的连线不经过墙就可以直接连起来，得到的路径由格子中心之间的直线段组成
+
+// 8个方向，前4个为上右下左
+var dirs8 = [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}, {-1, 1}, {1, 1}, {1, -1}, {-1, -1}}
+
+// 两个格子中心之间的直线距离
+func euclidean(a, b [2]int) float64 {
+	return math.Hypot(float64(a[0]-b[0]), float64(a[1]-b[1]))
+}
+
+// 从a的中心走到b的中心经过的格子，按顺序排列且相邻两个格子4连通
+// 直线恰好穿过格子的角时，角两侧的格子都算经过（保守的supercover），不允许从两面墙的缝隙中穿过：
+// 其中一侧的格子放进cells，另一侧的放进corners，视线检查需要两者都可以通行
+func traceLine(a, b [2]int) (cells, corners [][2]int) {
+	dr, dc := b[0]-a[0], b[1]-a[1]
+	nr, nc := abs(dr), abs(dc)
+	sr, sc := sign(dr), sign(dc)
+
+	cells = [][2]int{a}
+	pos := a
+	for ir, ic := 0, 0; ir < nr || ic < nc; {
+		// 比较下一次穿过横线和竖线的位置，决定先走哪个方向
+		decision := (1+2*ic)*nr - (1+2*ir)*nc
+		switch {
+		case decision == 0:
+			// 穿过格子的角，经过一侧的格子再斜走一步
+			cells = append(cells, [2]int{pos[0], pos[1] + sc})
+			corners = append(corners, [2]int{pos[0] + sr, pos[1]})
+			pos = [2]int{pos[0] + sr, pos[1] + sc}
+			ir++
+			ic++
+		case decision < 0:
+			pos[1] += sc
+			ic++
+		default:
+			pos[0] += sr
+			ir++
+		}
+		cells = append(cells, pos)
+	}
+	return cells, corners
+}
+
+// LineOfSight 两个格子中心之间的连线是否不经过墙（包括穿过墙角）
+func LineOfSight(maze [][]int, a, b [2]int) bool {
+	cells, corners := traceLine(a, b)
+	for _, pos := range append(cells, corners...) {
+		if !isWalkable(maze, pos) {
+			return false
+		}
+	}
+	return true
+}
+
+// 8方向的邻居，斜走时两侧的格子都必须可以通行
+func anyAngleNeighbors(maze [][]int, pos [2]int) [][2]int {
+	neighbors := make([][2]int, 0, 8)
+	for _, dir := range dirs8 {
+		next := [2]int{pos[0] + dir[0], pos[1] + dir[1]}
+		if !isWalkable(maze, next) {
+			continue
+		}
+		if dir[0] != 0 && dir[1] != 0 &&
+			(!isWalkable(maze, [2]int{pos[0] + dir[0], pos[1]}) || !isWalkable(maze, [2]int{pos[0], pos[1] + dir[1]})) {
+			continue
+		}
+		neighbors = append(neighbors, next)
+	}
+	return neighbors
+}
+
+type anyAngleNode struct {
+	pos [2]int
+	f   float64
+}
+
+// FindPathThetaStar Theta*：扩展邻居时如果当前节点的父节点能直接看到邻居，就让邻居直接连到父节点上
+func FindPathThetaStar(maze [][]int, start, end [2]int) PathFindResult {
+	return findPathTheta(maze, start, end, false)
+}
+
+// FindPathLazyThetaStar Lazy Theta*：先假设父节点能看到邻居，等邻居出堆时才检查视线，
+// 看不到时再从已关闭的邻居中选一个最好的父节点，视线检查的次数比Theta*少很多
+func FindPathLazyThetaStar(maze [][]int, start, end [2]int) PathFindResult {
+	return findPathTheta(maze, start, end, true)
+}
+
+func findPathTheta(maze [][]int, start, end [2]int, lazy bool) PathFindResult {
+	if err := validateEnds(maze, start, end); err != nil {
+		return PathFindResult{Path: make([][2]int, 0), Err: err}
+	}
+
+	var res PathFindResult
+	g := map[[2]int]float64{start: 0}
+	parent := map[[2]int][2]int{start: start}
+	closed := make(map[[2]int]bool)
+	open := NewHeap(func(a, b anyAngleNode) bool { return a.f < b.f })
+	open.Push(anyAngleNode{start, euclidean(start, end)})
+
+	lineOfSight := func(a, b [2]int) bool {
+		res.Check++
+		return LineOfSight(maze, a, b)
+	}
+	update := func(pos, next [2]int) {
+		// 路径2：从父节点直接连过来；路径1：从pos走一步
+		from, cost := pos, g[pos]+euclidean(pos, next)
+		if p := parent[pos]; lazy || lineOfSight(p, next) {
+			from, cost = p, g[p]+euclidean(p, next)
+		}
+		if old, ok := g[next]; ok && old <= cost {
+			return
+		}
+		g[next] = cost
+		parent[next] = from
+		res.Cost++
+		open.Push(anyAngleNode{next, cost + euclidean(next, end)})
+		res.StepRecord.Steps = append(res.StepRecord.Steps, MazeStep{Pos: next, Type: "push"})
+	}
+
+	found := false
+	for open.Len() > 0 {
+		cur := open.Pop()
+		if closed[cur.pos] {
+			continue
+		}
+		pos := cur.pos
+
+		// Lazy Theta*：出堆时才检查视线，看不到父节点时在已关闭的邻居中重新选择父节点
+		if lazy && pos != start && !lineOfSight(parent[pos], pos) {
+			g[pos] = math.Inf(1)
+			for _, n := range anyAngleNeighbors(maze, pos) {
+				if closed[n] && g[n]+euclidean(n, pos) < g[pos] {
+					g[pos] = g[n] + euclidean(n, pos)
+					parent[pos] = n
+				}
+			}
+		}
+
+		closed[pos] = true
+		res.StepRecord.Steps = append(res.StepRecord.Steps, MazeStep{Pos: pos, Type: "pop"})
+		if pos == end {
+			found = true
+			break
+		}
+
+		for _, next := range anyAngleNeighbors(maze, pos) {
+			if !closed[next] {
+				update(pos, next)
+			}
+		}
+	}
+
+	res.Path = make([][2]int, 0)
+	if !found {
+		return res
+	}
+	res.Found = true
+
+	corners := [][2]int{end}
+	for pos := end; pos != start; {
+		pos = parent[pos]
+		corners = append([][2]int{pos}, corners...)
+	}
+	res.Waypoints = CellCenters(corners)
+	res.Path = expandCorners(corners)
+	return res
+}
+
+// 把拐点之间的直线段展开成4连通的格子序列
+func expandCorners(corners [][2]int) [][2]int {
+	path := [][2]int{corners[0]}
+	for i := 1; i < len(corners); i++ {
+		cells, _ := traceLine(corners[i-1], corners[i])
+		path = append(path, cells[1:]...)
+	}
+	return path
+}
+
+// CellCenters 格子中心的实数坐标（行+0.5，列+0.5）
+func CellCenters(cells [][2]int) [][2]float64 {
+	points := make([][2]float64, len(cells))
+	for i, c := range cells {
+		points[i] = [2]float64{float64(c[0]) + 0.5, float64(c[1]) + 0.5}
+	}
+	return points
+}
+
+// WaypointsLength 拐点之间直线段的总长度
+func WaypointsLength(points [][2]float64) float64 {
+	length := 0.0
+	for i := 1; i < len(points); i++ {
+		length += math.Hypot(points[i][0]-points[i-1][0], points[i][1]-points[i-1][1])
+	}
+	return length
+}
+
+// SmoothPath 路径平滑后处理（拉绳子），适用于任何算法输出的格子路径：
+// 从当前拐点出发沿路径向前走，直到遇到第一个看不到的格子，它前一个格子就是下一个拐点，返回拐点序列
+func SmoothPath(maze [][]int, path [][2]int) [][2]int {
+	if len(path) <= 2 {
+		return append([][2]int(nil), path...)
+	}
+	corners := [][2]int{path[0]}
+	anchor := 0
+	for anchor < len(path)-1 {
+		next := anchor + 1
+		for next+1 < len(path) && LineOfSight(maze, path[anchor], path[next+1]) {
+			next++
+		}
+		corners = append(corners, path[next])
+		anchor = next
+	}
+	return corners
+}
+
+// SmoothResult 对寻路结果做平滑：Waypoints为平滑后的拐点，Path为拐点之间展开的格子
+func SmoothResult(maze [][]int, res PathFindResult) PathFindResult {
+	if len(res.Path) == 0 {
+		return res
+	}
+	corners := SmoothPath(maze, res.Path)
+	res.Waypoints = CellCenters(corners)
+	res.Path = expandCorners(corners)
+	return res
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func sign(x int) int {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,182 @@
+package pathfind
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// 与traceLine独立的参照：坐标放大两倍后格子中心都是整数，连线与某个墙格子的闭区域（包括边和角）相交就看不到
+func bruteForceLineOfSight(maze [][]int, a, b [2]int) bool {
+	ar, ac, br, bc := 2*a[0]+1, 2*a[1]+1, 2*b[0]+1, 2*b[1]+1
+	for r := min(a[0], b[0]); r <= max(a[0], b[0]); r++ {
+		for c := min(a[1], b[1]); c <= max(a[1], b[1]); c++ {
+			if isWalkable(maze, [2]int{r, c}) {
+				continue
+			}
+			// 格子的四个角都严格在连线的同一侧时不相交，包围盒已经重叠
+			pos, neg := 0, 0
+			for _, p := range [][2]int{{2 * r, 2 * c}, {2 * r, 2*c + 2}, {2*r + 2, 2 * c}, {2*r + 2, 2*c + 2}} {
+				switch cross := (br-ar)*(p[1]-ac) - (bc-ac)*(p[0]-ar); {
+				case cross > 0:
+					pos++
+				case cross < 0:
+					neg++
+				}
+			}
+			if pos < 4 && neg < 4 {
+				return false
+			}
+		}
+	}
+	return isWalkable(maze, a) && isWalkable(maze, b)
+}
+
+func TestTraceLine(t *testing.T) {
+	rng := rand.New(rand.NewSource(44))
+	for i := 0; i < 2000; i++ {
+		a := [2]int{rng.Intn(12), rng.Intn(12)}
+		b := [2]int{rng.Intn(12), rng.Intn(12)}
+		cells, corners := traceLine(a, b)
+		if cells[0] != a || cells[len(cells)-1] != b {
+			t.Fatalf("%v->%v: cells %v", a, b, cells)
+		}
+		for k := 1; k < len(cells); k++ {
+			if manhattanDistance(cells[k-1], cells[k]) != 1 {
+				t.Fatalf("%v->%v: cells %v not 4-connected at %d", a, b, cells, k)
+			}
+		}
+		// 角另一侧的格子不会重复出现在cells中
+		for _, corner := range corners {
+			if containsCell(cells, corner) {
+				t.Fatalf("%v->%v: corner cell %v also in cells %v", a, b, corner, cells)
+			}
+		}
+		// 两个方向经过的格子相同
+		back, backCorners := traceLine(b, a)
+		if len(back)+len(backCorners) != len(cells)+len(corners) {
+			t.Fatalf("%v->%v covers %d cells, reverse %d", a, b, len(cells)+len(corners), len(back)+len(backCorners))
+		}
+		for _, c := range append(back, backCorners...) {
+			if !containsCell(cells, c) && !containsCell(corners, c) {
+				t.Fatalf("%v->%v: reverse covers %v", a, b, c)
+			}
+		}
+	}
+}
+
+func TestLineOfSight(t *testing.T) {
+	rng := rand.New(rand.NewSource(440))
+	for i := 0; i < 300; i++ {
+		maze := randomGrid(rng, 1+rng.Intn(12), 1+rng.Intn(12), rng.Float64()*0.4)
+		for k := 0; k < 30; k++ {
+			a := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+			b := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+			got := LineOfSight(maze, a, b)
+			if got != LineOfSight(maze, b, a) {
+				t.Fatalf("maze %d: LineOfSight(%v,%v)=%v is not symmetric", i, a, b, got)
+			}
+			if want := bruteForceLineOfSight(maze, a, b); got != want {
+				t.Fatalf("maze %d: LineOfSight(%v,%v)=%v, brute force %v", i, a, b, got, want)
+			}
+		}
+	}
+
+	// 连线恰好穿过两面墙相接的角，或者只擦过一面墙的角，都看不到
+	squeeze := parseGrid(
+		".#.",
+		"#..",
+		"...")
+	graze := parseGrid(
+		".#.",
+		"...",
+		"...")
+	for name, maze := range map[string][][]int{"squeeze": squeeze, "graze": graze} {
+		for _, ends := range [][2][2]int{{{0, 0}, {1, 1}}, {{0, 0}, {2, 2}}} {
+			if LineOfSight(maze, ends[0], ends[1]) || LineOfSight(maze, ends[1], ends[0]) {
+				t.Errorf("%s: line of sight %v<->%v through a wall corner", name, ends[0], ends[1])
+			}
+		}
+	}
+	if !LineOfSight(graze, [2]int{1, 0}, [2]int{2, 2}) {
+		t.Error("graze: no line of sight (1,0)->(2,2) in the open")
+	}
+}
+
+// 平滑后保留起点和终点，拐点都在原路径上且顺序不变，相邻拐点互相看得到，直线长度不超过原路径，展开后的格子都可以通行
+func TestSmoothPath(t *testing.T) {
+	rng := rand.New(rand.NewSource(441))
+	for i := 0; i < 300; i++ {
+		maze := randomGrid(rng, 2+rng.Intn(20), 2+rng.Intn(20), rng.Float64()*0.35)
+		start := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+		end := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+		maze[start[0]][start[1]], maze[end[0]][end[1]] = 0, 0
+		res := FindPathDijkstra(maze, start, end)
+		if !res.Found {
+			continue
+		}
+
+		corners := SmoothPath(maze, res.Path)
+		if corners[0] != start || corners[len(corners)-1] != end {
+			t.Fatalf("maze %d: corners %v do not keep %v and %v", i, corners, start, end)
+		}
+		k := 0
+		for _, c := range corners {
+			for k < len(res.Path) && res.Path[k] != c {
+				k++
+			}
+			if k == len(res.Path) {
+				t.Fatalf("maze %d: corner %v not on the path in order: corners %v path %v", i, c, corners, res.Path)
+			}
+		}
+		for k := 1; k < len(corners); k++ {
+			if !LineOfSight(maze, corners[k-1], corners[k]) {
+				t.Fatalf("maze %d: no line of sight between corners %v and %v", i, corners[k-1], corners[k])
+			}
+		}
+		if length := WaypointsLength(CellCenters(corners)); length > float64(len(res.Path)-1)+1e-9 {
+			t.Fatalf("maze %d: smoothed length %.3f longer than path %d", i, length, len(res.Path)-1)
+		}
+
+		smoothed := SmoothResult(maze, res)
+		if !validPath(maze, smoothed.Path) || smoothed.Path[0] != start || smoothed.Path[len(smoothed.Path)-1] != end {
+			t.Fatalf("maze %d: expanded smoothed path %v", i, smoothed.Path)
+		}
+	}
+}
+
+// Theta*和Lazy Theta*的拐点之间互相看得到，展开后的格子都可以通行，直线长度不超过最短的格子路径
+func TestThetaStarCorners(t *testing.T) {
+	rng := rand.New(rand.NewSource(442))
+	for i := 0; i < 300; i++ {
+		maze := randomGrid(rng, 2+rng.Intn(20), 2+rng.Intn(20), rng.Float64()*0.35)
+		start := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+		end := [2]int{rng.Intn(len(maze)), rng.Intn(len(maze[0]))}
+		maze[start[0]][start[1]], maze[end[0]][end[1]] = 0, 0
+		want := FindPathDijkstra(maze, start, end)
+
+		for name, find := range map[string]func([][]int, [2]int, [2]int) PathFindResult{
+			"theta": FindPathThetaStar, "lazytheta": FindPathLazyThetaStar,
+		} {
+			res := find(maze, start, end)
+			if res.Found != want.Found {
+				t.Fatalf("maze %d %s %v->%v: found=%v, dijkstra %v", i, name, start, end, res.Found, want.Found)
+			}
+			if !res.Found {
+				continue
+			}
+			if !validPath(maze, res.Path) || res.Path[0] != start || res.Path[len(res.Path)-1] != end {
+				t.Fatalf("maze %d %s: expanded path %v", i, name, res.Path)
+			}
+			for k := 1; k < len(res.Waypoints); k++ {
+				a := [2]int{int(res.Waypoints[k-1][0]), int(res.Waypoints[k-1][1])}
+				b := [2]int{int(res.Waypoints[k][0]), int(res.Waypoints[k][1])}
+				if !LineOfSight(maze, a, b) {
+					t.Fatalf("maze %d %s: no line of sight between corners %v and %v", i, name, a, b)
+				}
+			}
+			if length := WaypointsLength(res.Waypoints); length > float64(len(want.Path)-1)+1e-9 {
+				t.Fatalf("maze %d %s: length %.3f longer than grid path %d", i, name, length, len(want.Path)-1)
+			}
+		}
+	}
+}
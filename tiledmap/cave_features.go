@@ -0,0 +1,329 @@
+package tiledmap
+
+import (
+	"sort"
+)
+
+// CaveFeature 洞穴中通路格子的类别
+type CaveFeature int
+
+const (
+	FeatureWall       CaveFeature = iota // 墙
+	FeatureChamber                       // 开阔的洞室
+	FeatureTunnel                        // 狭窄的隧道
+	FeatureChokepoint                    // 咽喉点：去掉后通路会被分成不连通的几部分
+)
+
+func (f CaveFeature) String() string {
+	switch f {
+	case FeatureChamber:
+		return "chamber"
+	case FeatureTunnel:
+		return "tunnel"
+	case FeatureChokepoint:
+		return "chokepoint"
+	}
+	return "wall"
+}
+
+// DefaultChamberRadius 洞室的最小半径：以格子为中心(2r+1)*(2r+1)的方块全是通路才算开阔
+const DefaultChamberRadius = 1
+
+// CaveRegion 同一类别的连通区域
+type CaveRegion struct {
+	ID     int
+	Kind   CaveFeature
+	Size   int
+	Center [2]int // 区域内离质心最近的格子（行，列）
+}
+
+// Chokepoint 咽喉点以及去掉它后被分出去的较小部分的格子数
+type Chokepoint struct {
+	Pos       [2]int
+	Separated int
+}
+
+// CaveAnalysis 洞穴分析的结果
+type CaveAnalysis struct {
+	Features    [][]CaveFeature // 每个格子的类别
+	Regions     [][]int         // 每个格子所属的区域编号，墙和咽喉点为0
+	RegionList  []CaveRegion    // RegionList[i]的ID为i+1
+	Chokepoints []Chokepoint    // 按Separated从大到小排序
+}
+
+// AnalyzeCave 把通路分为洞室、隧道和咽喉点
+// 洞室：能放下半径为chamberRadius的方块的格子（形态学开运算），其余的通路是隧道；
+// 咽喉点：4连通通路图的割点，只保留去掉后被分出去的部分不少于minSeparated格的点，
+// 用来过滤掉只堵住一小段死胡同的割点
+func AnalyzeCave(maze [][]int, chamberRadius, minSeparated int) CaveAnalysis {
+	height, width := len(maze), len(maze[0])
+	if chamberRadius < 1 {
+		chamberRadius = DefaultChamberRadius
+	}
+
+	features := make([][]CaveFeature, height)
+	for i := range features {
+		features[i] = make([]CaveFeature, width)
+	}
+
+	// 能放下方块的中心格子
+	clearance := chebyshevClearance(maze)
+	for i := 0; i < height; i++ {
+		for j := 0; j < width; j++ {
+			if clearance[i][j] <= chamberRadius {
+				continue
+			}
+			for x := i - chamberRadius; x <= i+chamberRadius; x++ {
+				for y := j - chamberRadius; y <= j+chamberRadius; y++ {
+					features[x][y] = FeatureChamber
+				}
+			}
+		}
+	}
+	for i := 0; i < height; i++ {
+		for j := 0; j < width; j++ {
+			if maze[i][j] == 0 && features[i][j] == FeatureWall {
+				features[i][j] = FeatureTunnel
+			}
+		}
+	}
+
+	res := CaveAnalysis{Features: features}
+	for _, c := range articulationPoints(maze) {
+		if c.Separated >= minSeparated {
+			res.Chokepoints = append(res.Chokepoints, c)
+			features[c.Pos[0]][c.Pos[1]] = FeatureChokepoint
+		}
+	}
+
+	res.Regions, res.RegionList = labelFeatureRegions(features)
+	return res
+}
+
+// 每个通路格子到最近的墙（越界算墙）的切比雪夫距离，墙为0
+func chebyshevClearance(maze [][]int) [][]int {
+	height, width := len(maze), len(maze[0])
+	dist := make([][]int, height)
+	queue := make([]Point, 0)
+	for i := range dist {
+		dist[i] = make([]int, width)
+		for j := range dist[i] {
+			if maze[i][j] == 1 {
+				continue
+			}
+			dist[i][j] = -1
+			if i == 0 || j == 0 || i == height-1 || j == width-1 {
+				dist[i][j] = 1
+				queue = append(queue, Point{i, j})
+			}
+		}
+	}
+	// 与墙相邻（8方向）的格子距离为1
+	for i := 0; i < height; i++ {
+		for j := 0; j < width; j++ {
+			if dist[i][j] != -1 {
+				continue
+			}
+			for dx := -1; dx <= 1 && dist[i][j] == -1; dx++ {
+				for dy := -1; dy <= 1; dy++ {
+					if maze[i+dx][j+dy] == 1 {
+						dist[i][j] = 1
+						queue = append(queue, Point{i, j})
+						break
+					}
+				}
+			}
+		}
+	}
+
+	for head := 0; head < len(queue); head++ {
+		cur := queue[head]
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				nx, ny := cur.x+dx, cur.y+dy
+				if nx >= 0 && nx < height && ny >= 0 && ny < width && dist[nx][ny] == -1 {
+					dist[nx][ny] = dist[cur.x][cur.y] + 1
+					queue = append(queue, Point{nx, ny})
+				}
+			}
+		}
+	}
+	return dist
+}
+
+// 用非递归的Tarjan算法求4连通通路图的割点，Separated为去掉该点后被分出去的最小部分中最大的一个
+func articulationPoints(maze [][]int) []Chokepoint {
+	height, width := len(maze), len(maze[0])
+	n := height * width
+	disc := make([]int, n) // 发现时间，0表示未访问
+	low := make([]int, n)
+	sub := make([]int, n) // DFS子树大小
+	timer := 0
+
+	dirs := []Point{{-1, 0}, {0, 1}, {1, 0}, {0, -1}}
+	type frame struct {
+		node, parent, dir int
+	}
+
+	sizes := componentSizes(maze)
+	points := make([]Chokepoint, 0)
+	for s := 0; s < n; s++ {
+		if maze[s/width][s%width] != 0 || disc[s] != 0 {
+			continue
+		}
+
+		// 连通块的大小，用来计算割掉后另一侧的大小
+		total := sizes[s]
+
+		// 每个割点被分出去的部分里最大的一个
+		separated := make(map[int]int)
+		rootChildren := 0
+
+		timer++
+		disc[s], low[s], sub[s] = timer, timer, 1
+		stack := []frame{{s, -1, 0}}
+		for len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			v := top.node
+			if top.dir < len(dirs) {
+				d := dirs[top.dir]
+				top.dir++
+				x, y := v/width+d.x, v%width+d.y
+				if x < 0 || x >= height || y < 0 || y >= width || maze[x][y] != 0 {
+					continue
+				}
+				u := x*width + y
+				if disc[u] == 0 {
+					timer++
+					disc[u], low[u], sub[u] = timer, timer, 1
+					stack = append(stack, frame{u, v, 0})
+				} else if u != top.parent {
+					low[v] = min(low[v], disc[u])
+				}
+				continue
+			}
+
+			// v的所有邻居处理完毕，回到父节点
+			stack = stack[:len(stack)-1]
+			p := top.parent
+			if p < 0 {
+				continue
+			}
+			low[p] = min(low[p], low[v])
+			sub[p] += sub[v]
+			if p == s {
+				rootChildren++
+			}
+			if low[v] >= disc[p] {
+				part := min(sub[v], total-1-sub[v])
+				if p == s {
+					part = sub[v]
+				}
+				separated[p] = max(separated[p], part)
+			}
+		}
+
+		for v, part := range separated {
+			// 根节点只有一个子树时不是割点
+			if v == s && rootChildren < 2 {
+				continue
+			}
+			if v == s {
+				part = min(part, total-1-part)
+			}
+			points = append(points, Chokepoint{Pos: [2]int{v / width, v % width}, Separated: part})
+		}
+	}
+	// 分出去的部分越大越重要，排在前面
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].Separated != points[j].Separated {
+			return points[i].Separated > points[j].Separated
+		}
+		return points[i].Pos[0]*width+points[i].Pos[1] < points[j].Pos[0]*width+points[j].Pos[1]
+	})
+	return points
+}
+
+// 每个通路格子所在4连通块的格子数，下标为x*width+y
+func componentSizes(maze [][]int) []int {
+	height, width := len(maze), len(maze[0])
+	sizes := make([]int, height*width)
+	queue := make([]int, 0)
+	for s := range sizes {
+		if maze[s/width][s%width] != 0 || sizes[s] != 0 {
+			continue
+		}
+		queue = append(queue[:0], s)
+		sizes[s] = -1
+		for head := 0; head < len(queue); head++ {
+			v := queue[head]
+			x, y := v/width, v%width
+			for _, d := range []Point{{-1, 0}, {0, 1}, {1, 0}, {0, -1}} {
+				nx, ny := x+d.x, y+d.y
+				u := nx*width + ny
+				if nx >= 0 && nx < height && ny >= 0 && ny < width && maze[nx][ny] == 0 && sizes[u] == 0 {
+					sizes[u] = -1
+					queue = append(queue, u)
+				}
+			}
+		}
+		for _, v := range queue {
+			sizes[v] = len(queue)
+		}
+	}
+	return sizes
+}
+
+// 把相同类别（洞室或隧道）的相邻格子标记为同一个区域
+func labelFeatureRegions(features [][]CaveFeature) ([][]int, []CaveRegion) {
+	height, width := len(features), len(features[0])
+	labels := make([][]int, height)
+	for i := range labels {
+		labels[i] = make([]int, width)
+	}
+
+	regions := make([]CaveRegion, 0)
+	dirs := []Point{{-1, 0}, {0, 1}, {1, 0}, {0, -1}}
+	for i := 0; i < height; i++ {
+		for j := 0; j < width; j++ {
+			kind := features[i][j]
+			if (kind != FeatureChamber && kind != FeatureTunnel) || labels[i][j] != 0 {
+				continue
+			}
+
+			id := len(regions) + 1
+			labels[i][j] = id
+			queue := []Point{{i, j}}
+			var sx, sy int
+			for head := 0; head < len(queue); head++ {
+				cur := queue[head]
+				sx += cur.x
+				sy += cur.y
+				for _, d := range dirs {
+					nx, ny := cur.x+d.x, cur.y+d.y
+					if nx >= 0 && nx < height && ny >= 0 && ny < width &&
+						features[nx][ny] == kind && labels[nx][ny] == 0 {
+						labels[nx][ny] = id
+						queue = append(queue, Point{nx, ny})
+					}
+				}
+			}
+
+			// 质心可能落在区域外，取区域内离它最近的格子
+			cx, cy := sx/len(queue), sy/len(queue)
+			center, best := queue[0], -1
+			for _, p := range queue {
+				if d := (p.x-cx)*(p.x-cx) + (p.y-cy)*(p.y-cy); best < 0 || d < best {
+					center, best = p, d
+				}
+			}
+			regions = append(regions, CaveRegion{
+				ID:     id,
+				Kind:   kind,
+				Size:   len(queue),
+				Center: [2]int{center.x, center.y},
+			})
+		}
+	}
+	return labels, regions
+}
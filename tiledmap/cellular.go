@@ -22,8 +22,18 @@ type MazeParams struct {
 	Iterations  int     // 迭代次数
 }
 
-// 将函数改为导出
+// InitializeMaze 每个格子以probability的概率成为障碍块，即密度处处相同的InitializeMazeWithDensity
 func InitializeMaze(size int, probability float64) [][]int {
+	return InitializeMazeWithDensity(size, UniformDensity(probability))
+}
+
+// InitializeMazeWithDensity 按密度场随机生成障碍块，可以让同一张地图里同时有密集的岩区和空旷的洞穴
+func InitializeMazeWithDensity(size int, density DensityField) [][]int {
+	return InitializeMazeRand(newRand(), size, density)
+}
+
+// InitializeMazeRand 和InitializeMazeWithDensity相同，使用r作为随机源
+func InitializeMazeRand(r *rand.Rand, size int, density DensityField) [][]int {
 	maze := make([][]int, size)
 	row := make([]int, size*size) // 一次性分配所有内存
 	for i := range maze {
@@ -31,7 +41,7 @@ func InitializeMaze(size int, probability float64) [][]int {
 	}
 	for i := range maze {
 		for j := range maze[i] {
-			if rand.Float64() < probability {
+			if r.Float64() < density.Density(i, j) {
 				maze[i][j] = 1
 			}
 		}
@@ -44,85 +54,47 @@ func InitializeMaze(size int, probability float64) [][]int {
 	return maze
 }
 
+// CellularMaze 用经典的4-5规则（RuleCave）迭代一次
 func CellularMaze(maze [][]int) {
+	CellularMazeWithRule(maze, ThresholdRule(6, 4, NeighborhoodMoore))
+}
+
+// CellularMazeWithRule 用指定的规则迭代一次，入口和出口区域保持为通路
+func CellularMazeWithRule(maze [][]int, rule CellularRule) {
+	CellularMazeWithSchedule(maze, []CellularStep{{Rule: rule, Iterations: 1}})
+}
+
+// CellularMazeWithSchedule 按规则日程迭代，每次迭代前后入口和出口区域都保持为通路
+func CellularMazeWithSchedule(maze [][]int, schedule []CellularStep) {
 	size := len(maze)
 	setEntranceArea(maze, 0, 0)                                       // 左上角入口
 	setEntranceArea(maze, size-MinEntranceSize, size-MinEntranceSize) // 右下角出口
 
-	for i := range maze {
-		for j := range maze[i] {
-			count := countNeighborsWall(maze, i, j)
-			if maze[i][j] == 1 {
-				if count < 4 {
-					maze[i][j] = 0
-				}
-			} else {
-				if count > 5 {
-					maze[i][j] = 1
-				}
-			}
-		}
-	}
+	RunCellularSchedule(maze, schedule, entranceMask(maze))
 }
 
-func ConnectRegionsByBFS(maze [][]int) {
+// 入口和出口区域的标记
+func entranceMask(maze [][]int) [][]bool {
 	size := len(maze)
-	regions := markConnectedRegions(maze)
-
-	// 获取所有不同的区域编号
-	regionNums := make(map[int]bool)
-	for i := range regions {
-		for j := range regions[i] {
-			if regions[i][j] > 0 {
-				regionNums[regions[i][j]] = true
-			}
-		}
-	}
-
-	// 如果只有一个区域或没有区域，直接返回
-	if len(regionNums) <= 1 {
-		return
-	}
-
-	// 将区域编号转换为切片
-	regionList := make([]int, 0)
-	for num := range regionNums {
-		regionList = append(regionList, num)
-	}
-
-	// 当还有多个区域时，继续连接
-	for len(regionList) > 1 {
-		// 对当前第一区域进行BFS寻路
-		region1 := regionList[0]
-		path := bfsToNearestRegion(maze, regions, region1)
-
-		if path != nil {
-			// 获取连接到的目标区域编号
-			targetRegion := regions[path[len(path)-1][0]][path[len(path)-1][1]]
-
-			// 打通路径
-			for _, pos := range path {
-				maze[pos[0]][pos[1]] = 0
-			}
-
-			// 更新regions数组，将targetRegion合并到region1
-			for i := 0; i < size; i++ {
-				for j := 0; j < size; j++ {
-					if regions[i][j] == targetRegion {
-						regions[i][j] = region1
-					}
-				}
-			}
-
-			// 从列表中移除已合并的区域
-			for i := 0; i < len(regionList); i++ {
-				if regionList[i] == targetRegion {
-					regionList = append(regionList[:i], regionList[i+1:]...)
-					break
+	mask := make([][]bool, size)
+	for i := range mask {
+		mask[i] = make([]bool, len(maze[i]))
+	}
+	for _, corner := range [][2]int{{0, 0}, {size - MinEntranceSize, size - MinEntranceSize}} {
+		for i := corner[0]; i < corner[0]+MinEntranceSize && i < size; i++ {
+			for j := corner[1]; j < corner[1]+MinEntranceSize && j < size; j++ {
+				if i >= 0 && j >= 0 {
+					mask[i][j] = true
 				}
 			}
 		}
 	}
+	return mask
+}
+
+// ConnectRegionsByBFS 每次从最小的连通块出发，BFS到最近的其他区域并打通，直到所有区域连通
+func ConnectRegionsByBFS(maze [][]int) {
+	ConnectCaveRegions(maze, DefaultCaveConnectOptions())
 }
 
 // 设置入口区域
@@ -134,26 +106,6 @@ func setEntranceArea(maze [][]int, startX, startY int) {
 	}
 }
 
-func countNeighborsWall(maze [][]int, x, y int) int {
-	count := 0
-	size := len(maze)
-	for i := -1; i <= 1; i++ {
-		for j := -1; j <= 1; j++ {
-			checkx := x + i
-			checky := y + j
-			if i == 0 && j == 0 {
-				continue
-			}
-			if checkx < 0 || checky < 0 || checkx >= size || checky >= size {
-				count++
-			} else {
-				count += maze[checkx][checky]
-			}
-		}
-	}
-	return count
-}
-
 // 标记连通区域的函数
 func markConnectedRegions(maze [][]int) [][]int {
 	size := len(maze)
@@ -201,25 +153,21 @@ func dfs(maze [][]int, regions [][]int, x, y, region int) {
 	dfs(maze, regions, x, y-1, region) // 左
 }
 
-// 使用BFS寻找到最近的其他区域的路径
-func bfsToNearestRegion(maze [][]int, regions [][]int, sourceRegion int) [][2]int {
+// 使用BFS寻找从seeds所在的连通块到最近的其他区域的路径，区域是否连通由并查集uf判断
+func bfsToNearestRegion(maze [][]int, regions [][]int, uf *UnionFind, seeds [][2]int) [][2]int {
 	size := len(maze)
-	visited := make([][]bool, size)
-	for i := range visited {
-		visited[i] = make([]bool, size)
-	}
+	root := uf.Find(regions[seeds[0][0]][seeds[0][1]])
 
 	// 使用队列存储待访问的点
 	queue := [][2]int{}
 	parent := make(map[[2]int][2]int)
+	visited := make(map[[2]int]bool)
 
-	// 找到源区域的所有边界点作为起点
-	for i := range regions {
-		for j := range regions[i] {
-			if regions[i][j] == sourceRegion && hasAdjacentWall(regions, i, j) {
-				queue = append(queue, [2]int{i, j})
-				visited[i][j] = true
-			}
+	// 找到源连通块的所有边界点作为起点
+	for _, pos := range seeds {
+		if hasAdjacentWall(regions, pos[0], pos[1]) {
+			queue = append(queue, pos)
+			visited[pos] = true
 		}
 	}
 
@@ -227,9 +175,8 @@ func bfsToNearestRegion(maze [][]int, regions [][]int, sourceRegion int) [][2]in
 	dirs := [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}}
 
 	// BFS搜索
-	for len(queue) > 0 {
-		current := queue[0]
-		queue = queue[1:]
+	for head := 0; head < len(queue); head++ {
+		current := queue[head]
 
 		// 检查四个方向
 		for _, dir := range dirs {
@@ -237,20 +184,19 @@ func bfsToNearestRegion(maze [][]int, regions [][]int, sourceRegion int) [][2]in
 
 			if next[0] >= 0 && next[0] < size &&
 				next[1] >= 0 && next[1] < size &&
-				!visited[next[0]][next[1]] {
+				!visited[next] {
 
-				visited[next[0]][next[1]] = true
+				visited[next] = true
 				parent[next] = current
 
-				// 如果找到了另一个区域
-				if regions[next[0]][next[1]] > 0 &&
-					regions[next[0]][next[1]] != sourceRegion {
+				// 如果找到了另一个连通块的区域
+				if r := regions[next[0]][next[1]]; r > 0 && uf.Find(r) != root {
 					// 重建路径
 					path := [][2]int{}
 					currentPos := next
 					for {
 						path = append([][2]int{currentPos}, path...)
-						if regions[currentPos[0]][currentPos[1]] == sourceRegion {
+						if r := regions[currentPos[0]][currentPos[1]]; r > 0 && uf.Find(r) == root {
 							break
 						}
 						currentPos = parent[currentPos]
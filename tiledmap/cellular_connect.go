@@ -0,0 +1,309 @@
+package tiledmap
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// CaveConnectMethod 连接洞穴区域的方式
+type CaveConnectMethod string
+
+const (
+	ConnectNearest CaveConnectMethod = "nearest" // 每次BFS到最近的其他区域
+	ConnectMST     CaveConnectMethod = "mst"     // 按区域质心之间的最小生成树连接
+)
+
+const (
+	MaxTunnelWidth  = 5
+	MaxTunnelJitter = 0.9
+)
+
+// CaveConnectOptions 连接洞穴区域的参数
+type CaveConnectOptions struct {
+	Method        CaveConnectMethod
+	MinRegionSize int        // 小于该格子数的区域直接填成墙，不去连接；入口和出口所在的区域总是保留
+	TunnelWidth   int        // 隧道宽度
+	Jitter        float64    // 隧道每一步横向偏移的概率，0时为BFS最短路径（nearest）或者直线（mst）
+	Rand          *rand.Rand // 隧道偏移使用的随机源，nil时从math/rand的全局随机源取一个种子
+}
+
+// DefaultCaveConnectOptions ConnectRegionsByBFS使用的参数：沿BFS最短路径挖宽度为1的通道
+func DefaultCaveConnectOptions() CaveConnectOptions {
+	return CaveConnectOptions{Method: ConnectNearest, TunnelWidth: 1}
+}
+
+// CaveConnectResult 连接的统计信息
+type CaveConnectResult struct {
+	Regions int // 连接前的区域数
+	Removed int // 因为太小被填掉的区域数
+	Tunnels int // 挖出的隧道数
+}
+
+// ConnectCaveRegions 把所有区域连通起来，区域之间是否连通用并查集维护，不需要每次重新标记整张地图
+func ConnectCaveRegions(maze [][]int, opts CaveConnectOptions) CaveConnectResult {
+	var res CaveConnectResult
+	size := len(maze)
+	if size == 0 {
+		return res
+	}
+	opts.TunnelWidth = min(max(opts.TunnelWidth, 1), MaxTunnelWidth)
+	opts.Jitter = min(max(opts.Jitter, 0), MaxTunnelJitter)
+	if opts.Rand == nil {
+		opts.Rand = newRand()
+	}
+
+	regions := markConnectedRegions(maze)
+	cells := make(map[int][][2]int)
+	for i := range regions {
+		for j := range regions[i] {
+			if r := regions[i][j]; r > 0 {
+				cells[r] = append(cells[r], [2]int{i, j})
+			}
+		}
+	}
+	res.Regions = len(cells)
+
+	res.Removed = pruneSmallRegions(maze, regions, cells, opts.MinRegionSize)
+
+	ids := make([]int, 0, len(cells))
+	for r := range cells {
+		ids = append(ids, r)
+	}
+	sort.Ints(ids)
+	if len(ids) <= 1 {
+		return res
+	}
+
+	maxID := ids[len(ids)-1]
+	uf := NewUnionFind(maxID + 1)
+	// 每个连通块包含的区域，合并时把小的并到大的里
+	members := make(map[int][]int, len(ids))
+	for _, r := range ids {
+		members[r] = []int{r}
+	}
+	union := func(a, b int) {
+		ra, rb := uf.Find(a), uf.Find(b)
+		if ra == rb {
+			return
+		}
+		uf.Union(ra, rb)
+		root, other := uf.Find(ra), rb
+		if root == rb {
+			other = ra
+		}
+		members[root] = append(members[root], members[other]...)
+		delete(members, other)
+	}
+
+	// 挖开一个格子，并把它和周围的区域合并
+	carve := func(pos [2]int, region int) {
+		maze[pos[0]][pos[1]] = 0
+		if regions[pos[0]][pos[1]] == 0 {
+			regions[pos[0]][pos[1]] = region
+			cells[region] = append(cells[region], pos)
+		}
+		for _, d := range [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}, {0, 0}} {
+			x, y := pos[0]+d[0], pos[1]+d[1]
+			if x < 0 || y < 0 || x >= size || y >= size {
+				continue
+			}
+			if r := regions[x][y]; r > 0 {
+				union(r, region)
+			}
+		}
+	}
+	dig := func(from, to [2]int, path [][2]int) {
+		region := regions[from[0]][from[1]]
+		if opts.Jitter > 0 || path == nil {
+			path = jitteredTunnel(opts.Rand, from, to, opts.Jitter, size)
+		}
+		for _, pos := range path {
+			for _, p := range tunnelBrush(pos, opts.TunnelWidth, size) {
+				carve(p, region)
+			}
+		}
+		res.Tunnels++
+	}
+
+	switch opts.Method {
+	case ConnectMST:
+		for _, e := range centroidEdges(ids, cells) {
+			if len(members) <= 1 {
+				break
+			}
+			if uf.Find(e.a) == uf.Find(e.b) {
+				continue
+			}
+			from, to := closestCellPair(cells[e.a], cells[e.b])
+			dig(from, to, nil)
+		}
+	default:
+		// 每次从最小的连通块出发，BFS的范围更小
+		for len(members) > 1 {
+			smallest, smallestSize := -1, 0
+			for root, rs := range members {
+				n := 0
+				for _, r := range rs {
+					n += len(cells[r])
+				}
+				if smallest < 0 || n < smallestSize || (n == smallestSize && root < smallest) {
+					smallest, smallestSize = root, n
+				}
+			}
+
+			seeds := make([][2]int, 0, smallestSize)
+			for _, r := range members[smallest] {
+				seeds = append(seeds, cells[r]...)
+			}
+			path := bfsToNearestRegion(maze, regions, uf, seeds)
+			if path == nil {
+				break
+			}
+			dig(path[0], path[len(path)-1], path)
+		}
+	}
+
+	return res
+}
+
+// 把小于minSize的区域填成墙，入口（左上角）和出口（右下角）所在的区域以及最大的区域总是保留
+func pruneSmallRegions(maze, regions [][]int, cells map[int][][2]int, minSize int) int {
+	if minSize <= 1 {
+		return 0
+	}
+	size := len(maze)
+	keep := map[int]bool{
+		regions[0][0]:           true,
+		regions[size-1][size-1]: true,
+	}
+	largest := 0
+	for r, c := range cells {
+		if largest == 0 || len(c) > len(cells[largest]) {
+			largest = r
+		}
+	}
+	keep[largest] = true
+
+	removed := 0
+	for r, c := range cells {
+		if len(c) >= minSize || keep[r] {
+			continue
+		}
+		for _, pos := range c {
+			maze[pos[0]][pos[1]] = 1
+			regions[pos[0]][pos[1]] = 0
+		}
+		delete(cells, r)
+		removed++
+	}
+	return removed
+}
+
+type regionEdge struct {
+	a, b int
+	dist float64
+}
+
+// 所有区域两两之间按质心距离排序的边，Kruskal按顺序处理即得到最小生成树
+func centroidEdges(ids []int, cells map[int][][2]int) []regionEdge {
+	centroids := make(map[int][2]float64, len(ids))
+	for _, r := range ids {
+		var sx, sy float64
+		for _, pos := range cells[r] {
+			sx += float64(pos[0])
+			sy += float64(pos[1])
+		}
+		n := float64(len(cells[r]))
+		centroids[r] = [2]float64{sx / n, sy / n}
+	}
+
+	edges := make([]regionEdge, 0, len(ids)*(len(ids)-1)/2)
+	for i := 0; i < len(ids); i++ {
+		for j := i + 1; j < len(ids); j++ {
+			ca, cb := centroids[ids[i]], centroids[ids[j]]
+			edges = append(edges, regionEdge{ids[i], ids[j], math.Hypot(ca[0]-cb[0], ca[1]-cb[1])})
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].dist < edges[j].dist })
+	return edges
+}
+
+// 两个区域之间的隧道端点：先取a中离b质心最近的格子，再取b中离它最近的格子
+func closestCellPair(a, b [][2]int) ([2]int, [2]int) {
+	var cx, cy float64
+	for _, pos := range b {
+		cx += float64(pos[0])
+		cy += float64(pos[1])
+	}
+	center := [2]int{int(cx / float64(len(b))), int(cy / float64(len(b)))}
+	from := closestCell(a, center)
+	return from, closestCell(b, from)
+}
+
+func closestCell(cells [][2]int, target [2]int) [2]int {
+	best, bestDist := cells[0], -1
+	for _, pos := range cells {
+		dx, dy := pos[0]-target[0], pos[1]-target[1]
+		if d := dx*dx + dy*dy; bestDist < 0 || d < bestDist {
+			best, bestDist = pos, d
+		}
+	}
+	return best
+}
+
+// 从from走到to的隧道，每一步以jitter的概率横向偏移一格，形成弯曲的自然通道
+func jitteredTunnel(r *rand.Rand, from, to [2]int, jitter float64, size int) [][2]int {
+	path := [][2]int{from}
+	cur := from
+	for cur != to {
+		dx, dy := to[0]-cur[0], to[1]-cur[1]
+		var step [2]int
+		if r.Float64() < jitter {
+			// 垂直于主要方向偏移
+			side := 1 - 2*r.Intn(2)
+			if abs(dx) >= abs(dy) {
+				step = [2]int{0, side}
+			} else {
+				step = [2]int{side, 0}
+			}
+		} else if r.Intn(abs(dx)+abs(dy)) < abs(dx) {
+			step = [2]int{sign(dx), 0}
+		} else {
+			step = [2]int{0, sign(dy)}
+		}
+
+		next := [2]int{cur[0] + step[0], cur[1] + step[1]}
+		if next[0] < 0 || next[1] < 0 || next[0] >= size || next[1] >= size {
+			continue
+		}
+		cur = next
+		path = append(path, cur)
+	}
+	return path
+}
+
+// 以pos为中心宽度为width的方块
+func tunnelBrush(pos [2]int, width, size int) [][2]int {
+	brush := make([][2]int, 0, width*width)
+	lo := -(width - 1) / 2
+	for i := lo; i < lo+width; i++ {
+		for j := lo; j < lo+width; j++ {
+			x, y := pos[0]+i, pos[1]+j
+			if x >= 0 && y >= 0 && x < size && y < size {
+				brush = append(brush, [2]int{x, y})
+			}
+		}
+	}
+	return brush
+}
+
+func sign(x int) int {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,42 @@
+package tiledmap
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// 弯曲的隧道使用opts.Rand：同一个种子的结果相同，连接后只剩一个区域
+func TestConnectCaveRegionsSeeded(t *testing.T) {
+	for _, method := range []CaveConnectMethod{ConnectNearest, ConnectMST} {
+		for seed := int64(0); seed < 10; seed++ {
+			connect := func() ([][]int, CaveConnectResult) {
+				maze := InitializeMazeRand(rand.New(rand.NewSource(seed)), 41, UniformDensity(DefaultProbability))
+				for i := 0; i < DefaultIterations; i++ {
+					CellularMaze(maze)
+				}
+				opts := CaveConnectOptions{Method: method, TunnelWidth: 2, Jitter: 0.5, Rand: rand.New(rand.NewSource(seed))}
+				return maze, ConnectCaveRegions(maze, opts)
+			}
+			a, resA := connect()
+			b, resB := connect()
+			if !reflect.DeepEqual(a, b) || resA != resB {
+				t.Fatalf("%s seed %d: different caves with the same seed", method, seed)
+			}
+			if resA.Tunnels == 0 {
+				continue
+			}
+			ids := make(map[int]bool)
+			for _, row := range markConnectedRegions(a) {
+				for _, id := range row {
+					if id > 0 {
+						ids[id] = true
+					}
+				}
+			}
+			if len(ids) != 1 {
+				t.Fatalf("%s seed %d: %d regions after connecting", method, seed, len(ids))
+			}
+		}
+	}
+}
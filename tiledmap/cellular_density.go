@@ -0,0 +1,86 @@
+package tiledmap
+
+import (
+	"math/rand"
+)
+
+// DensityField 给出初始地图中每个格子生成障碍块的概率
+type DensityField interface {
+	Density(x, y int) float64
+}
+
+// UniformDensity 所有格子使用同一个概率，等价于原来的probability
+type UniformDensity float64
+
+func (d UniformDensity) Density(x, y int) float64 {
+	return float64(d)
+}
+
+// BlockDensity 把地图分成BlockSize大小的块，每块使用Probs中各自的概率
+type BlockDensity struct {
+	BlockSize int
+	Probs     [][]float64 // Probs[x/BlockSize][y/BlockSize]
+}
+
+// NewRandomBlockDensity 为size*size的地图生成分块概率，每块的概率在[probability-spread, probability+spread]中随机，使用r作为随机源
+func NewRandomBlockDensity(r *rand.Rand, size, blockSize int, probability, spread float64) *BlockDensity {
+	if blockSize < 1 {
+		blockSize = 1
+	}
+	blocks := (size + blockSize - 1) / blockSize
+	probs := make([][]float64, blocks)
+	for i := range probs {
+		probs[i] = make([]float64, blocks)
+		for j := range probs[i] {
+			probs[i][j] = clamp01(probability + (r.Float64()*2-1)*spread)
+		}
+	}
+	return &BlockDensity{BlockSize: blockSize, Probs: probs}
+}
+
+func (d *BlockDensity) Density(x, y int) float64 {
+	bx := min(max(x/d.BlockSize, 0), len(d.Probs)-1)
+	by := min(max(y/d.BlockSize, 0), len(d.Probs[bx])-1)
+	return d.Probs[bx][by]
+}
+
+// NoiseDensity 用柏林噪声连续地改变概率：Base + Amplitude * noise
+// Scale是噪声的周期（格子数），越大密集区和稀疏区越大块
+type NoiseDensity struct {
+	Noise     *PerlinNoise
+	Scale     float64
+	Base      float64
+	Amplitude float64
+	FBM       bool
+}
+
+// NewNoiseDensity 柏林噪声的种子从r取
+func NewNoiseDensity(r *rand.Rand, scale, probability, amplitude float64, useFBM bool) *NoiseDensity {
+	return &NoiseDensity{
+		Noise:     NewPerlinNoise(r.Int63()),
+		Scale:     scale,
+		Base:      probability,
+		Amplitude: amplitude,
+		FBM:       useFBM,
+	}
+}
+
+func (d *NoiseDensity) Density(x, y int) float64 {
+	scale := d.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	nx, ny := float64(x)/scale, float64(y)/scale
+	var value float64
+	if d.FBM {
+		value = d.Noise.FBM(nx, ny, 4, 2.0, 0.5)
+	} else {
+		value = d.Noise.Noise2D(nx, ny)
+	}
+	// 柏林噪声的值大致在[-0.5, 0.5]，乘2后让Amplitude接近实际的浮动范围
+	return clamp01(d.Base + d.Amplitude*value*2)
+}
+
+func clamp01(v float64) float64 {
+	return min(max(v, 0), 1)
+}
@@ -0,0 +1,60 @@
+package tiledmap
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestPerlinNoiseSeed(t *testing.T) {
+	sample := func(seed int64) []float64 {
+		p := NewPerlinNoise(seed)
+		var values []float64
+		for i := 0; i < 50; i++ {
+			x, y := float64(i)*0.37, float64(i)*0.91
+			values = append(values, p.Noise2D(x, y), p.FBM(x, y, 4, 2.0, 0.5))
+		}
+		return values
+	}
+	// 中间动一下全局随机源，结果也不受影响
+	a := sample(35)
+	rand.Int63()
+	if b := sample(35); !reflect.DeepEqual(a, b) {
+		t.Fatal("same seed gives different noise")
+	}
+	if reflect.DeepEqual(a, sample(36)) {
+		t.Fatal("different seeds give the same noise")
+	}
+}
+
+// 分块和噪声密度场的随机数都从r取，同一个种子生成的初始地图相同
+func TestDensityFieldsSeeded(t *testing.T) {
+	const size = 64
+	fields := map[string]func(r *rand.Rand) DensityField{
+		"block": func(r *rand.Rand) DensityField { return NewRandomBlockDensity(r, size, 8, 0.45, 0.2) },
+		"noise": func(r *rand.Rand) DensityField { return NewNoiseDensity(r, 16, 0.45, 0.2, true) },
+	}
+	for name, field := range fields {
+		generate := func(seed int64) [][]int {
+			r := rand.New(rand.NewSource(seed))
+			return InitializeMazeRand(r, size, field(r))
+		}
+		for seed := int64(0); seed < 5; seed++ {
+			if !reflect.DeepEqual(generate(seed), generate(seed)) {
+				t.Fatalf("%s seed %d: different maps with the same seed", name, seed)
+			}
+		}
+		if reflect.DeepEqual(generate(1), generate(2)) {
+			t.Fatalf("%s: different seeds give the same map", name)
+		}
+	}
+
+	d := NewRandomBlockDensity(rand.New(rand.NewSource(1)), size, 8, 0.9, 0.3)
+	for x := 0; x < size; x++ {
+		for y := 0; y < size; y++ {
+			if v := d.Density(x, y); v < 0.6 || v > 1 {
+				t.Fatalf("block density %.3f at (%d,%d) outside [0.6, 1]", v, x, y)
+			}
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package tiledmap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Neighborhood 细胞自动机统计邻居的范围
+type Neighborhood string
+
+const (
+	NeighborhoodMoore      Neighborhood = "moore"      // 周围8格
+	NeighborhoodVonNeumann Neighborhood = "vonneumann" // 上下左右4格
+	NeighborhoodMoore2     Neighborhood = "moore2"     // 半径为2的24格
+)
+
+// Neighborhoods 所有支持的邻域，用于页面上的选择
+var Neighborhoods = []Neighborhood{NeighborhoodMoore, NeighborhoodVonNeumann, NeighborhoodMoore2}
+
+// 各邻域的偏移量
+var neighborhoodOffsets = map[Neighborhood][][2]int{
+	NeighborhoodMoore:      squareOffsets(1),
+	NeighborhoodVonNeumann: {{-1, 0}, {0, 1}, {1, 0}, {0, -1}},
+	NeighborhoodMoore2:     squareOffsets(2),
+}
+
+func squareOffsets(radius int) [][2]int {
+	offsets := make([][2]int, 0)
+	for i := -radius; i <= radius; i++ {
+		for j := -radius; j <= radius; j++ {
+			if i != 0 || j != 0 {
+				offsets = append(offsets, [2]int{i, j})
+			}
+		}
+	}
+	return offsets
+}
+
+// 常用规则，墙是“活”的细胞
+const (
+	RuleCave       = "B678/S45678"  // 经典的4-5规则：墙邻居少于4时墙消失，多于5时通路变墙
+	RuleCaveSparse = "B678/S345678" // 墙更容易保留，洞穴更窄
+	RuleSmooth     = "B5678/S45678" // 多数决，用来平滑边缘
+)
+
+// CellularPresets 页面上可以直接选择的规则
+var CellularPresets = map[string]string{
+	"cave":   RuleCave,
+	"sparse": RuleCaveSparse,
+	"smooth": RuleSmooth,
+}
+
+// CellularRule Life-like的B/S规则，墙为活细胞
+// 通路周围的墙数在Birth中时变成墙，墙周围的墙数在Survive中时保留，否则变成通路
+type CellularRule struct {
+	Birth        []bool // 下标为墙邻居数
+	Survive      []bool
+	Neighborhood Neighborhood
+}
+
+// CellularStep 规则日程中的一步：用Rule迭代Iterations次
+type CellularStep struct {
+	Rule       CellularRule
+	Iterations int
+}
+
+// ParseCellularRule 解析B/S记法，例如"B678/S45678"
+// 邻居数超过9时（radius-2邻域）可以用逗号和区间，例如"B13-24/S12,14-24"
+func ParseCellularRule(notation string, nb Neighborhood) (CellularRule, error) {
+	offsets, ok := neighborhoodOffsets[nb]
+	if !ok {
+		return CellularRule{}, fmt.Errorf("unknown neighborhood %q", nb)
+	}
+	rule := CellularRule{
+		Birth:        make([]bool, len(offsets)+1),
+		Survive:      make([]bool, len(offsets)+1),
+		Neighborhood: nb,
+	}
+
+	notation = strings.ToUpper(strings.TrimSpace(notation))
+	if preset, ok := CellularPresets[strings.ToLower(notation)]; ok {
+		notation = preset
+	}
+	parts := strings.Split(notation, "/")
+	if len(parts) != 2 {
+		return CellularRule{}, fmt.Errorf("invalid rule %q, want B.../S...", notation)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return CellularRule{}, fmt.Errorf("invalid rule %q", notation)
+		}
+		var counts []bool
+		switch part[0] {
+		case 'B':
+			counts = rule.Birth
+		case 'S':
+			counts = rule.Survive
+		default:
+			return CellularRule{}, fmt.Errorf("invalid rule part %q", part)
+		}
+		if err := parseRuleCounts(part[1:], counts); err != nil {
+			return CellularRule{}, err
+		}
+	}
+	return rule, nil
+}
+
+// 解析邻居数列表，没有逗号和区间时每一位数字都是一个邻居数
+func parseRuleCounts(str string, counts []bool) error {
+	if str == "" {
+		return nil
+	}
+	var tokens []string
+	if strings.ContainsAny(str, ",-") {
+		tokens = strings.Split(str, ",")
+	} else {
+		tokens = strings.Split(str, "")
+	}
+
+	for _, token := range tokens {
+		lo, hi := token, token
+		if i := strings.Index(token, "-"); i >= 0 {
+			lo, hi = token[:i], token[i+1:]
+		}
+		from, err1 := strconv.Atoi(lo)
+		to, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || from < 0 || from > to {
+			return fmt.Errorf("invalid neighbor count %q", token)
+		}
+		if to >= len(counts) {
+			return fmt.Errorf("neighbor count %d exceeds neighborhood size %d", to, len(counts)-1)
+		}
+		for c := from; c <= to; c++ {
+			counts[c] = true
+		}
+	}
+	return nil
+}
+
+// String 输出B/S记法
+func (r CellularRule) String() string {
+	return "B" + formatRuleCounts(r.Birth) + "/S" + formatRuleCounts(r.Survive)
+}
+
+// 邻居数不超过9时每个数字占一位，否则用逗号分隔并把连续的数字合并成区间
+func formatRuleCounts(counts []bool) string {
+	if len(counts) <= 10 {
+		str := ""
+		for c, ok := range counts {
+			if ok {
+				str += strconv.Itoa(c)
+			}
+		}
+		return str
+	}
+
+	parts := make([]string, 0)
+	for c := 0; c < len(counts); c++ {
+		if !counts[c] {
+			continue
+		}
+		end := c
+		for end+1 < len(counts) && counts[end+1] {
+			end++
+		}
+		if end > c {
+			parts = append(parts, fmt.Sprintf("%d-%d", c, end))
+		} else {
+			parts = append(parts, strconv.Itoa(c))
+		}
+		c = end
+	}
+	return strings.Join(parts, ",")
+}
+
+// ThresholdRule 用阈值描述的规则：墙邻居数不少于birth时通路变墙，不少于survive时墙保留
+func ThresholdRule(birth, survive int, nb Neighborhood) CellularRule {
+	n := len(neighborhoodOffsets[nb])
+	rule := CellularRule{
+		Birth:        make([]bool, n+1),
+		Survive:      make([]bool, n+1),
+		Neighborhood: nb,
+	}
+	for c := 0; c <= n; c++ {
+		rule.Birth[c] = c >= birth
+		rule.Survive[c] = c >= survive
+	}
+	return rule
+}
+
+// ParseCellularSchedule 解析规则日程，每一步为"规则:次数"，用分号分隔，次数省略时为1
+// 例如"B678/S45678:4;smooth:2"，规则也可以是CellularPresets中的名字
+func ParseCellularSchedule(schedule string, nb Neighborhood) ([]CellularStep, error) {
+	steps := make([]CellularStep, 0)
+	for _, item := range strings.Split(schedule, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		notation, iterations := item, 1
+		if i := strings.LastIndex(item, ":"); i >= 0 {
+			n, err := strconv.Atoi(strings.TrimSpace(item[i+1:]))
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid iteration count in %q", item)
+			}
+			notation, iterations = item[:i], n
+		}
+		rule, err := ParseCellularRule(notation, nb)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, CellularStep{Rule: rule, Iterations: iterations})
+	}
+	return steps, nil
+}
+
+// ApplyCellularRule 对整个地图执行一次规则，使用双缓冲，结果与扫描顺序无关
+// 越界的格子按墙计算，keep不为nil时其中为true的格子保持为通路
+func ApplyCellularRule(maze [][]int, rule CellularRule, keep [][]bool) {
+	next := make([][]int, len(maze))
+	for i := range maze {
+		next[i] = make([]int, len(maze[i]))
+	}
+	stepCellular(maze, next, rule, keep)
+	for i := range maze {
+		copy(maze[i], next[i])
+	}
+}
+
+// RunCellularSchedule 依次执行日程中的每一步
+func RunCellularSchedule(maze [][]int, schedule []CellularStep, keep [][]bool) {
+	if len(maze) == 0 {
+		return
+	}
+	cur := maze
+	next := make([][]int, len(maze))
+	for i := range maze {
+		next[i] = make([]int, len(maze[i]))
+	}
+	for _, step := range schedule {
+		for iter := 0; iter < step.Iterations; iter++ {
+			stepCellular(cur, next, step.Rule, keep)
+			cur, next = next, cur
+		}
+	}
+	// 结果在另一块缓冲里时拷贝回来
+	if &cur[0] != &maze[0] {
+		for i := range maze {
+			copy(maze[i], cur[i])
+		}
+	}
+}
+
+// 读取src执行一次规则，结果写入dst
+func stepCellular(src, dst [][]int, rule CellularRule, keep [][]bool) {
+	offsets := neighborhoodOffsets[rule.Neighborhood]
+	if offsets == nil {
+		offsets = neighborhoodOffsets[NeighborhoodMoore]
+	}
+	height := len(src)
+	for i := 0; i < height; i++ {
+		width := len(src[i])
+		for j := 0; j < width; j++ {
+			if keep != nil && keep[i][j] {
+				dst[i][j] = 0
+				continue
+			}
+			count := 0
+			for _, o := range offsets {
+				x, y := i+o[0], j+o[1]
+				if x < 0 || y < 0 || x >= height || y >= width {
+					count++
+				} else {
+					count += src[x][y]
+				}
+			}
+			alive := src[i][j] == 1
+			if (alive && count < len(rule.Survive) && rule.Survive[count]) ||
+				(!alive && count < len(rule.Birth) && rule.Birth[count]) {
+				dst[i][j] = 1
+			} else {
+				dst[i][j] = 0
+			}
+		}
+	}
+}
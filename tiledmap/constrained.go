@@ -0,0 +1,129 @@
+package tiledmap
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+// DefaultMaxAttempts 约束生成默认的最大尝试次数
+const DefaultMaxAttempts = 50
+
+// ErrConstraintsNotMet 尝试次数用完仍然没有满足所有条件
+var ErrConstraintsNotMet = errors.New("constraints not met within retry budget")
+
+// GeneratedMap 生成器输出中用于检查条件的部分
+type GeneratedMap struct {
+	Grid       [][]int // 0为通路，非0为障碍
+	Start, End [2]int  // 计算路径长度的起终点（行，列）
+	Rooms      int     // 房间数，没有房间的地图为0
+}
+
+// Constraint 一个验收条件，metrics为AnalyzeMap(Grid, Start, End)的结果
+type Constraint struct {
+	Name   string
+	Accept func(m GeneratedMap, metrics MapMetrics) bool
+}
+
+// MinFloorRatio 通路占比不低于ratio，过滤太封闭的地图
+func MinFloorRatio(ratio float64) Constraint {
+	return Constraint{
+		Name:   fmt.Sprintf("floorRatio>=%g", ratio),
+		Accept: func(_ GeneratedMap, metrics MapMetrics) bool { return metrics.FloorRatio >= ratio },
+	}
+}
+
+// MaxFloorRatio 通路占比不高于ratio，过滤太空旷的地图
+func MaxFloorRatio(ratio float64) Constraint {
+	return Constraint{
+		Name:   fmt.Sprintf("floorRatio<=%g", ratio),
+		Accept: func(_ GeneratedMap, metrics MapMetrics) bool { return metrics.FloorRatio <= ratio },
+	}
+}
+
+// MinPathLength 起点到终点的最短路径不少于n格，不连通时不满足
+func MinPathLength(n int) Constraint {
+	return Constraint{
+		Name: fmt.Sprintf("pathLength>=%d", n),
+		Accept: func(_ GeneratedMap, metrics MapMetrics) bool {
+			return metrics.PathLength > 0 && metrics.PathLength >= n
+		},
+	}
+}
+
+// MinRoomCount 房间数不少于n
+func MinRoomCount(n int) Constraint {
+	return Constraint{
+		Name:   fmt.Sprintf("rooms>=%d", n),
+		Accept: func(m GeneratedMap, _ MapMetrics) bool { return m.Rooms >= n },
+	}
+}
+
+// SingleRegion 所有通路4连通
+func SingleRegion() Constraint {
+	return Constraint{
+		Name:   "singleRegion",
+		Accept: func(_ GeneratedMap, metrics MapMetrics) bool { return metrics.RegionCount == 1 },
+	}
+}
+
+// RetryOptions 重试参数
+type RetryOptions struct {
+	MaxAttempts int   // <=0时为DefaultMaxAttempts
+	Seed        int64 // 第一次尝试的种子，第i次（从0开始）使用Seed+i；0时随机选择
+}
+
+// ConstrainedResult 约束生成的结果
+type ConstrainedResult[T any] struct {
+	Map      T
+	Info     GeneratedMap
+	Metrics  MapMetrics
+	Seed     int64          // 生成这张地图的种子，以它为Seed、MaxAttempts为1可以重新得到同一张地图
+	Attempts int            // 实际尝试的次数
+	Failures map[string]int // 每个条件在所有尝试中不满足的次数
+	Accepted bool           // 是否满足所有条件
+}
+
+// GenerateWithConstraints 反复调用gen直到生成的地图满足所有条件或者用完尝试次数
+// 每次尝试用种子新建一个随机源传给gen，gen的所有随机数都从r取就可以按种子复现；
+// 用完尝试次数时返回不满足条件最少的一张地图以及ErrConstraintsNotMet
+func GenerateWithConstraints[T any](gen func(r *rand.Rand) (T, GeneratedMap), constraints []Constraint, opts RetryOptions) (ConstrainedResult[T], error) {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.Seed == 0 {
+		opts.Seed = rand.Int63n(1<<31) + 1
+	}
+
+	res := ConstrainedResult[T]{Failures: make(map[string]int)}
+	bestFailed := -1
+	for i := 0; i < opts.MaxAttempts; i++ {
+		seed := opts.Seed + int64(i)
+		m, info := gen(rand.New(rand.NewSource(seed)))
+		metrics := AnalyzeMap(info.Grid, info.Start, info.End)
+
+		failed := 0
+		for _, c := range constraints {
+			if !c.Accept(info, metrics) {
+				res.Failures[c.Name]++
+				failed++
+			}
+		}
+		res.Attempts = i + 1
+
+		if bestFailed < 0 || failed < bestFailed {
+			bestFailed = failed
+			res.Map, res.Info, res.Metrics, res.Seed = m, info, metrics, seed
+		}
+		if failed == 0 {
+			res.Accepted = true
+			return res, nil
+		}
+	}
+	return res, ErrConstraintsNotMet
+}
+
+// 没有指定随机源的生成函数使用的随机源，种子取自math/rand的全局随机源
+func newRand() *rand.Rand {
+	return rand.New(rand.NewSource(rand.Int63()))
+}
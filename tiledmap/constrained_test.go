@@ -0,0 +1,94 @@
+package tiledmap
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// 与约束生成页面相同的几种生成器，所有随机数都从r取
+var seededGenerators = map[string]func(r *rand.Rand) ([][]int, GeneratedMap){
+	"maze": func(r *rand.Rand) ([][]int, GeneratedMap) {
+		res := GenerateMazeDetailed(31, 31, MazeOptions{Algorithm: MazeDFS, TurnProb: 0.4, Rand: r})
+		path := FindPathBetween(res.Maze, res.Start, res.End)
+		AccuMazeBetween(res.Maze, path, 0.7, res.Start, res.End)
+		ErosionMazeRand(r, res.Maze, 0.5)
+		return res.Maze, GeneratedMap{Grid: res.Maze, Start: res.Start, End: res.End}
+	},
+	"wilson": func(r *rand.Rand) ([][]int, GeneratedMap) {
+		res := GenerateMazeDetailed(31, 31, MazeOptions{Algorithm: MazeWilson, TargetRatio: 0.3, Rand: r})
+		return res.Maze, GeneratedMap{Grid: res.Maze, Start: res.Start, End: res.End}
+	},
+	"eller": func(r *rand.Rand) ([][]int, GeneratedMap) {
+		res := GenerateMazeDetailed(31, 31, MazeOptions{Algorithm: MazeEller, Rand: r})
+		return res.Maze, GeneratedMap{Grid: res.Maze, Start: res.Start, End: res.End}
+	},
+	"cellular": func(r *rand.Rand) ([][]int, GeneratedMap) {
+		maze := InitializeMazeRand(r, 31, UniformDensity(DefaultProbability))
+		for i := 0; i < DefaultIterations; i++ {
+			CellularMaze(maze)
+		}
+		start, end := MazeCorners(maze)
+		return maze, GeneratedMap{Grid: maze, Start: start, End: end}
+	},
+	"dungeon": func(r *rand.Rand) ([][]int, GeneratedMap) {
+		d := GenerateDungeonRand(r, 41, 41, 12, 5, 11, CorridorOptions{Width: 1, WindingPercent: 0.5, KeepDeadEnds: 0.3})
+		d.GenerateMazeBetweenRooms()
+		d.ConnectPassagesByDFS()
+		d.ConnectAllRegions(0.2)
+		d.FillDeadEnds()
+		return d.Tiles, GeneratedMap{Grid: d.Tiles, Start: [2]int{1, 1}, End: [2]int{39, 39}, Rooms: len(d.Rooms)}
+	},
+}
+
+// 用结果中的种子、只尝试一次重新生成，得到的地图完全相同
+func TestGenerateWithConstraintsReplay(t *testing.T) {
+	for name, gen := range seededGenerators {
+		constraints := []Constraint{MinFloorRatio(0.3), MaxFloorRatio(0.9)}
+		res, _ := GenerateWithConstraints(gen, constraints, RetryOptions{MaxAttempts: 20})
+
+		// 中间使用全局随机源不影响复现
+		rand.Int63()
+		again, _ := GenerateWithConstraints(gen, constraints, RetryOptions{MaxAttempts: 1, Seed: res.Seed})
+		if again.Seed != res.Seed || again.Attempts != 1 {
+			t.Fatalf("%s: replay seed %d attempts %d, want seed %d", name, again.Seed, again.Attempts, res.Seed)
+		}
+		if !reflect.DeepEqual(again.Map, res.Map) || again.Info.End != res.Info.End || again.Accepted != res.Accepted {
+			t.Fatalf("%s: replay with seed %d produced a different map", name, res.Seed)
+		}
+	}
+}
+
+// 同时进行的约束生成各自使用自己的随机源，互不干扰
+func TestGenerateWithConstraintsConcurrent(t *testing.T) {
+	gen := seededGenerators["dungeon"]
+	want, _ := GenerateWithConstraints(gen, nil, RetryOptions{MaxAttempts: 1, Seed: 7})
+
+	done := make(chan [][]int)
+	for i := 0; i < 8; i++ {
+		go func() {
+			res, _ := GenerateWithConstraints(gen, nil, RetryOptions{MaxAttempts: 1, Seed: 7})
+			done <- res.Map
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		if got := <-done; !reflect.DeepEqual(got, want.Map) {
+			t.Fatal("concurrent generation with the same seed produced a different map")
+		}
+	}
+}
+
+// 每种迷宫算法用同一个种子都生成同一个迷宫
+func TestMazeAlgorithmsSeeded(t *testing.T) {
+	for _, algo := range MazeAlgorithms {
+		for _, strategy := range GrowingTreeStrategies {
+			opts := MazeOptions{Algorithm: algo, GrowingTree: strategy, TurnProb: 0.4, TargetRatio: 0.2}
+			opts.Rand = rand.New(rand.NewSource(3))
+			want := GenerateMazeDetailed(25, 21, opts)
+			opts.Rand = rand.New(rand.NewSource(3))
+			if got := GenerateMazeDetailed(25, 21, opts); !reflect.DeepEqual(got, want) {
+				t.Fatalf("%s/%s: same seed produced a different maze", algo, strategy)
+			}
+		}
+	}
+}
@@ -11,18 +11,44 @@ type Room struct {
 	Width, Height int // 房间大小
 }
 
+// CorridorOptions 控制迷宫通道的形态
+type CorridorOptions struct {
+	Width          int     // 通道宽度，1-3
+	WindingPercent float64 // 转弯系数，0时尽量走直线，1时完全随机（参考原文的windingPercent）
+	KeepDeadEnds   float64 // 堵死胡同时保留的死胡同比例，0时全部堵上
+}
+
+const (
+	MinCorridorWidth = 1
+	MaxCorridorWidth = 3
+)
+
+// DefaultCorridorOptions 返回默认的通道参数：1格宽、完全随机、堵上所有死胡同
+func DefaultCorridorOptions() CorridorOptions {
+	return CorridorOptions{
+		Width:          1,
+		WindingPercent: 1.0,
+		KeepDeadEnds:   0,
+	}
+}
+
 type Dungeon struct {
-	Width  int
-	Height int
-	Tiles  [][]int
-	Rooms  []Room
+	Width    int
+	Height   int
+	Tiles    [][]int
+	Rooms    []Room
+	Corridor CorridorOptions
+	Rand     *rand.Rand // 各个阶段使用的随机源，nil时在第一次使用前从math/rand的全局随机源取一个种子
+
+	regions *regionCache // 连通区域的标记，在各个阶段之间复用，只通过setTile修改地图时才能保持正确
 }
 
 func NewDungeon(width, height int) *Dungeon {
 	d := &Dungeon{
-		Width:  width,
-		Height: height,
-		Tiles:  make([][]int, height),
+		Width:    width,
+		Height:   height,
+		Tiles:    make([][]int, height),
+		Corridor: DefaultCorridorOptions(),
 	}
 	// 初始化为墙
 	for y := 0; y < height; y++ {
@@ -34,6 +60,14 @@ func NewDungeon(width, height int) *Dungeon {
 	return d
 }
 
+// 随机源，第一次使用时才创建，之后的阶段继续使用同一个
+func (d *Dungeon) rng() *rand.Rand {
+	if d.Rand == nil {
+		d.Rand = newRand()
+	}
+	return d.Rand
+}
+
 func (d *Dungeon) AddRoom(room Room) bool {
 	// 检查房间是否超出边界
 	if room.X < 1 || room.Y < 1 ||
@@ -54,23 +88,21 @@ func (d *Dungeon) AddRoom(room Room) bool {
 	// 添加房间
 	for y := room.Y; y < room.Y+room.Height; y++ {
 		for x := room.X; x < room.X+room.Width; x++ {
-			d.Tiles[y][x] = 0
+			d.setTile(x, y, 0)
 		}
 	}
 	d.Rooms = append(d.Rooms, room)
 	return true
 }
 
-func (d *Dungeon) GenerateMazeBetweenRooms() {
-	// 标记所有房间区域（包括边界）
+// 标记所有房间区域（包括房间周围的一格边界）
+func (d *Dungeon) markRoomArea() [][]bool {
 	roomArea := make([][]bool, d.Height)
 	for i := range roomArea {
 		roomArea[i] = make([]bool, d.Width)
 	}
 
-	// 标记房间区域
 	for _, room := range d.Rooms {
-		// 包括房间周围的一格边界
 		for y := room.Y - 1; y <= room.Y+room.Height; y++ {
 			if y < 0 || y >= d.Height {
 				continue
@@ -83,23 +115,155 @@ func (d *Dungeon) GenerateMazeBetweenRooms() {
 			}
 		}
 	}
+	return roomArea
+}
+
+// 通道宽度，限制在[MinCorridorWidth, MaxCorridorWidth]
+func (d *Dungeon) corridorWidth() int {
+	w := d.Corridor.Width
+	if w < MinCorridorWidth {
+		w = MinCorridorWidth
+	}
+	if w > MaxCorridorWidth {
+		w = MaxCorridorWidth
+	}
+	return w
+}
+
+// 迷宫格子的间距：一个通道格子（width*width）加一格墙
+func (d *Dungeon) cellPitch() int {
+	return d.corridorWidth() + 1
+}
+
+// 判断以(x,y)为左上角的通道格子是否完整地落在地图内部
+func (d *Dungeon) cellInBounds(x, y int) bool {
+	w := d.corridorWidth()
+	return x >= 1 && y >= 1 && x+w <= d.Width-1 && y+w <= d.Height-1
+}
+
+// 把矩形区域设置为指定的值
+func (d *Dungeon) fillRect(x, y, w, h, v int) {
+	for yy := y; yy < y+h; yy++ {
+		for xx := x; xx < x+w; xx++ {
+			d.setTile(xx, yy, v)
+		}
+	}
+}
+
+// 修改一个tile，同时维护缓存的连通区域：挖开时增量合并，把通路填成墙可能拆开区域，缓存作废
+func (d *Dungeon) setTile(x, y, v int) {
+	old := d.Tiles[y][x]
+	d.Tiles[y][x] = v
+	if d.regions == nil || old == v {
+		return
+	}
+	if v == 0 {
+		d.regions.carve(x, y)
+	} else {
+		d.regions = nil
+	}
+}
+
+// 以(x,y)为左上角的通道格子与dir方向相邻格子之间的墙段
+func (d *Dungeon) wallBetween(x, y int, dir [2]int) (wx, wy, ww, wh int) {
+	w := d.corridorWidth()
+	switch {
+	case dir[0] > 0:
+		return x + w, y, 1, w
+	case dir[0] < 0:
+		return x - 1, y, 1, w
+	case dir[1] > 0:
+		return x, y + w, w, 1
+	default:
+		return x, y - 1, w, 1
+	}
+}
+
+// 墙段上是否有打通的格子
+func (d *Dungeon) wallOpened(x, y int, dir [2]int) bool {
+	wx, wy, ww, wh := d.wallBetween(x, y, dir)
+	for yy := wy; yy < wy+wh; yy++ {
+		for xx := wx; xx < wx+ww; xx++ {
+			if d.Tiles[yy][xx] == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 打通两个相邻格子之间的墙段
+func (d *Dungeon) openWall(x, y int, dir [2]int) {
+	wx, wy, ww, wh := d.wallBetween(x, y, dir)
+	d.fillRect(wx, wy, ww, wh, 0)
+}
+
+// 通道格子的左上角坐标，tile不在通道格子里时返回false
+func (d *Dungeon) cellOrigin(x, y int) (int, int, bool) {
+	p := d.cellPitch()
+	if (x-1)%p == p-1 || (y-1)%p == p-1 {
+		return 0, 0, false
+	}
+	return x - (x-1)%p, y - (y-1)%p, true
+}
+
+// tile是否位于两个格子之间的墙段上（只在一条墙线上，而不是墙线的交叉点）
+func (d *Dungeon) onWallSegment(x, y int) bool {
+	p := d.cellPitch()
+	onX := (x-1)%p == p-1
+	onY := (y-1)%p == p-1
+	return onX != onY
+}
+
+// 墙段所在的完整区间：tile位于两个通道格子之间的墙上时，返回整段墙
+func (d *Dungeon) wallSegmentAt(x, y int) (wx, wy, ww, wh int) {
+	p := d.cellPitch()
+	w := d.corridorWidth()
+	if (x-1)%p == p-1 && (y-1)%p != p-1 {
+		// 竖直的墙段
+		return x, y - (y-1)%p, 1, w
+	}
+	if (y-1)%p == p-1 && (x-1)%p != p-1 {
+		// 水平的墙段
+		return x - (x-1)%p, y, w, 1
+	}
+	return x, y, 1, 1
+}
+
+func (d *Dungeon) GenerateMazeBetweenRooms() {
+	// 标记所有房间区域（包括边界）
+	roomArea := d.markRoomArea()
+	w := d.corridorWidth()
+	p := d.cellPitch()
 
 	// 在非房间区域生成迷宫
-	// 确保只在2的倍数位置生成墙
-	for y := 1; y < d.Height-1; y += 2 {
-		for x := 1; x < d.Width-1; x += 2 {
+	// 通道格子只出现在间隔为p的位置，格子之间保留一格墙
+	for y := 1; y+w <= d.Height-1; y += p {
+		for x := 1; x+w <= d.Width-1; x += p {
 			// 跳过房间区域
-			if roomArea[y][x] {
+			if blockInArea(roomArea, x, y, w) {
 				continue
 			}
 
 			// 在当前位置生成迷宫单元
-			d.Tiles[y][x] = 0 // 设置为通道
+			d.fillRect(x, y, w, w, 0) // 设置为通道
 		}
 	}
 
 }
 
+// 判断w*w的格子是否与标记区域有重叠
+func blockInArea(area [][]bool, x, y, w int) bool {
+	for yy := y; yy < y+w; yy++ {
+		for xx := x; xx < x+w; xx++ {
+			if area[yy][xx] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (d *Dungeon) ConnectPassagesByDFS() {
 	// 创建访问标记数组
 	visited := make([][]bool, d.Height)
@@ -107,89 +271,82 @@ func (d *Dungeon) ConnectPassagesByDFS() {
 		visited[i] = make([]bool, d.Width)
 	}
 
-	// 标记房间区域
-	roomArea := make([][]bool, d.Height)
-	for i := range roomArea {
-		roomArea[i] = make([]bool, d.Width)
-	}
-
 	// 标记所有房间区域（包括边界）
-	for _, room := range d.Rooms {
-		for y := room.Y - 1; y <= room.Y+room.Height; y++ {
-			if y < 0 || y >= d.Height {
-				continue
-			}
-			for x := room.X - 1; x <= room.X+room.Width; x++ {
-				if x < 0 || x >= d.Width {
-					continue
-				}
-				roomArea[y][x] = true
-			}
-		}
-	}
+	roomArea := d.markRoomArea()
+	w := d.corridorWidth()
+	p := d.cellPitch()
 
 	// 方向数组：上下左右
 	dirs := [][2]int{
 		{-1, 0}, {1, 0}, {0, -1}, {0, 1},
 	}
 
-	// DFS递归函数
-	var dfs func(x, y int)
-	dfs = func(x, y int) {
+	// DFS递归函数，lastDir为进入当前格子的方向（-1表示起点）
+	var dfs func(x, y, lastDir int)
+	dfs = func(x, y, lastDir int) {
 		visited[y][x] = true
 
 		// 随机打乱方向
-		randDirs := make([][2]int, len(dirs))
-		copy(randDirs, dirs)
-		rand.Shuffle(len(randDirs), func(i, j int) {
-			randDirs[i], randDirs[j] = randDirs[j], randDirs[i]
+		order := []int{0, 1, 2, 3}
+		d.rng().Shuffle(len(order), func(i, j int) {
+			order[i], order[j] = order[j], order[i]
 		})
 
+		// 不转弯时，优先沿用之前的方向
+		if lastDir >= 0 && d.rng().Float64() >= d.Corridor.WindingPercent {
+			for i, o := range order {
+				if o == lastDir {
+					order[0], order[i] = order[i], order[0]
+					break
+				}
+			}
+		}
+
 		// 向四个方向扩展
-		for _, dir := range randDirs {
-			// 计算新位置（跳两格）
-			nx := x + dir[0]*2
-			ny := y + dir[1]*2
+		for _, o := range order {
+			dir := dirs[o]
+			// 计算新位置（跳过一个格子加一格墙）
+			nx := x + dir[0]*p
+			ny := y + dir[1]*p
 
 			// 检查边界
-			if nx < 1 || nx >= d.Width-1 || ny < 1 || ny >= d.Height-1 {
+			if !d.cellInBounds(nx, ny) {
 				continue
 			}
 
 			// 跳过已访问点的和房间区域
-			if visited[ny][nx] || roomArea[ny][nx] {
+			if visited[ny][nx] || blockInArea(roomArea, nx, ny, w) {
 				continue
 			}
 
 			// 如果是通道点，连接并继续DFS
 			if d.Tiles[ny][nx] == 0 {
 				// 打通中间的墙
-				mx := x + dir[0]
-				my := y + dir[1]
-				d.Tiles[my][mx] = 0
+				d.openWall(x, y, dir)
 
 				// 继续DFS
-				dfs(nx, ny)
+				dfs(nx, ny, o)
 			}
 		}
 	}
 
 	// 遍历地图寻找通道点开始DFS
-	for y := 1; y < d.Height-1; y += 2 {
-		for x := 1; x < d.Width-1; x += 2 {
+	for y := 1; y+w <= d.Height-1; y += p {
+		for x := 1; x+w <= d.Width-1; x += p {
 			// 跳过已访问的点和房间区域
-			if visited[y][x] || roomArea[y][x] {
+			if visited[y][x] || blockInArea(roomArea, x, y, w) {
 				continue
 			}
 
 			// 如果是通道点，开始DFS
 			if d.Tiles[y][x] == 0 {
-				dfs(x, y)
+				dfs(x, y, -1)
 			}
 		}
 	}
 }
 
+// findConnectedRegions 每次都用BFS重新计算所有连通区域，FindConnectionInfoBruteForce（旧实现）使用
 func (d *Dungeon) findConnectedRegions() []ConnectedRegion {
 	visited := make([][]bool, d.Height)
 	for i := range visited {
@@ -244,6 +401,22 @@ func abs(x int) int {
 }
 
 func GenerateDungeon(width, height, roomCount, minSize, maxSize int) *Dungeon {
+	return GenerateDungeonWithOptions(width, height, roomCount, minSize, maxSize, DefaultCorridorOptions())
+}
+
+// GenerateDungeonWithOptions 按指定的通道参数生成地牢的房间部分
+// 通道宽度大于1时，地图尺寸和房间都会对齐到 通道宽度+1 的网格上，保证房间与通道之间只隔一格墙
+func GenerateDungeonWithOptions(width, height, roomCount, minSize, maxSize int, opts CorridorOptions) *Dungeon {
+	return generateDungeon(nil, width, height, roomCount, minSize, maxSize, opts, nil)
+}
+
+// GenerateDungeonRand 和GenerateDungeonWithOptions相同，房间以及之后连接通道等阶段都使用r作为随机源
+func GenerateDungeonRand(r *rand.Rand, width, height, roomCount, minSize, maxSize int, opts CorridorOptions) *Dungeon {
+	return generateDungeon(r, width, height, roomCount, minSize, maxSize, opts, nil)
+}
+
+// generateDungeon 生成房间，fixedRooms 会在随机房间之前优先放入地图，r为nil时使用新的随机源
+func generateDungeon(r *rand.Rand, width, height, roomCount, minSize, maxSize int, opts CorridorOptions, fixedRooms []Room) *Dungeon {
 	// 确保宽度和高度为奇数
 	if width%2 == 0 {
 		width++
@@ -271,19 +444,48 @@ func GenerateDungeon(width, height, roomCount, minSize, maxSize int) *Dungeon {
 		minSize, maxSize = maxSize, minSize
 	}
 
+	// 宽度和高度对齐到格子间距（1格通道时即为奇数）
+	probe := &Dungeon{Corridor: opts}
+	p := probe.cellPitch()
+	if r := (width - 1) % p; r != 0 {
+		width += p - r
+	}
+	if r := (height - 1) % p; r != 0 {
+		height += p - r
+	}
+
 	dungeon := NewDungeon(width, height)
+	dungeon.Corridor = opts
+	dungeon.Rand = r
+	rng := dungeon.rng()
+	for _, room := range fixedRooms {
+		dungeon.AddRoom(room)
+	}
+
+	// 房间尺寸以格子为单位：n个格子的房间占 n*p-1 个tile
+	minCells := (minSize + p) / p
+	maxCells := (maxSize + 1) / p
+	if maxCells < minCells {
+		maxCells = minCells
+	}
 
 	// 尝试添加指定数量的房间
 	attempts := 0
 	for len(dungeon.Rooms) < roomCount && attempts < 10000 {
-		// 生成范围内的随机奇数尺寸
-		sizeRange := (maxSize - minSize) / 2
-		roomWidth := minSize + (rand.Intn(sizeRange+1) * 2)
-		roomHeight := minSize + (rand.Intn(sizeRange+1) * 2)
-
-		// 确保房间位置为奇数
-		x := int(rand.Intn((width-roomWidth-2)/2))*2 + 1
-		y := int(rand.Intn((height-roomHeight-2)/2))*2 + 1
+		// 生成范围内的随机尺寸
+		sizeRange := maxCells - minCells
+		roomWidth := (minCells+rng.Intn(sizeRange+1))*p - 1
+		roomHeight := (minCells+rng.Intn(sizeRange+1))*p - 1
+
+		// 确保房间位置对齐到格子
+		xRange := (width - roomWidth - 2) / p
+		yRange := (height - roomHeight - 2) / p
+		if xRange <= 0 || yRange <= 0 {
+			attempts++
+			continue
+		}
+		x := int(rng.Intn(xRange))*p + 1
+		y := int(rng.Intn(yRange))*p + 1
 
 		room := Room{
 			X:      x,
@@ -318,6 +520,13 @@ func NewUnionFind(size int) *UnionFind {
 	return &UnionFind{parent: parent, rank: rank}
 }
 
+// 加入一个新的集合，返回它的编号
+func (uf *UnionFind) add() int {
+	uf.parent = append(uf.parent, len(uf.parent))
+	uf.rank = append(uf.rank, 0)
+	return len(uf.parent) - 1
+}
+
 // 查找根节点
 func (uf *UnionFind) Find(x int) int {
 	if uf.parent[x] != x {
@@ -373,7 +582,7 @@ func (d *Dungeon) ConnectAllRegions(extraPathProb float32) {
 	// 3. 随机打乱连接区顺序
 	randConnections := make([]ConnectionZone, len(connections))
 	copy(randConnections, connections)
-	rand.Shuffle(len(randConnections), func(i, j int) {
+	d.rng().Shuffle(len(randConnections), func(i, j int) {
 		randConnections[i], randConnections[j] = randConnections[j], randConnections[i]
 	})
 
@@ -386,14 +595,14 @@ func (d *Dungeon) ConnectAllRegions(extraPathProb float32) {
 			// 打通这个连接区
 			for _, cell := range conn.Cells {
 				// 随机选择一个格子打通
-				if rand.Float32() < extraPathProb { // 20%的概率打通一个格子
-					d.Tiles[cell.y][cell.x] = 0
+				if d.rng().Float32() < extraPathProb { // 20%的概率打通一个格子
+					d.openDoor(cell)
 				}
 			}
 			// 至少确保打通一个格子
 			if len(conn.Cells) > 0 {
-				randomCell := conn.Cells[rand.Intn(len(conn.Cells))]
-				d.Tiles[randomCell.y][randomCell.x] = 0
+				randomCell := conn.Cells[d.rng().Intn(len(conn.Cells))]
+				d.openDoor(randomCell)
 			}
 
 			// 在并查集中合并这两个区域
@@ -402,6 +611,12 @@ func (d *Dungeon) ConnectAllRegions(extraPathProb float32) {
 	}
 }
 
+// 打通连接点所在的整段墙，使门的宽度与通道宽度一致
+func (d *Dungeon) openDoor(cell Cell) {
+	wx, wy, ww, wh := d.wallSegmentAt(cell.x, cell.y)
+	d.fillRect(wx, wy, ww, wh, 0)
+}
+
 // 添加必要的类型定义
 type Cell struct {
 	x, y int
@@ -417,20 +632,63 @@ type ConnectedRegion struct {
 	cells []struct{ x, y int }
 }
 
+// FindConnectionInfo 计算所有连通区域以及区域之间的连接区
+// 只扫描一遍墙：位于墙段上、两侧分属不同区域的墙就是这两个区域之间的连接点
 func (d *Dungeon) FindConnectionInfo() ([]ConnectedRegion, []ConnectionZone) {
+	regions, labels := d.labelRegions()
+	var connections []ConnectionZone
+	zoneIndex := make(map[[2]int]int) // 区域对 -> connections中的下标
+
+	addConn := func(r1, r2 int, cell Cell) {
+		if r1 > r2 {
+			r1, r2 = r2, r1
+		}
+		key := [2]int{r1, r2}
+		idx, ok := zoneIndex[key]
+		if !ok {
+			idx = len(connections)
+			zoneIndex[key] = idx
+			connections = append(connections, ConnectionZone{Region1: r1, Region2: r2})
+		}
+		connections[idx].Cells = append(connections[idx].Cells, cell)
+	}
+
+	for y := 1; y < d.Height-1; y++ {
+		for x := 1; x < d.Width-1; x++ {
+			// 门只开在两个格子之间的墙段上，不开在墙的交叉点
+			if d.Tiles[y][x] != 1 || !d.onWallSegment(x, y) {
+				continue
+			}
+			if l, r := labels[y][x-1], labels[y][x+1]; l >= 0 && r >= 0 && l != r {
+				addConn(l, r, Cell{x: x, y: y})
+			}
+			if u, b := labels[y-1][x], labels[y+1][x]; u >= 0 && b >= 0 && u != b {
+				addConn(u, b, Cell{x: x, y: y})
+			}
+		}
+	}
+	return regions, connections
+}
+
+// FindConnectionInfoBruteForce 逐对比较两个区域的所有格子来寻找连接区
+// 复杂度是格子数的平方，仅用于和 FindConnectionInfo 做性能对比
+func (d *Dungeon) FindConnectionInfoBruteForce() ([]ConnectedRegion, []ConnectionZone) {
 	regions := d.findConnectedRegions()
 	var connections []ConnectionZone
 
 	// 寻找可能的连接区域
-	// TODO：这里可以性能优化
 	for i := 0; i < len(regions); i++ {
 		for j := i + 1; j < len(regions); j++ {
 			// 寻找两个区域之间的连接点
 			var connCells []Cell
 			for _, cell1 := range regions[i].cells {
 				for _, cell2 := range regions[j].cells {
-					if abs(cell1.x-cell2.x)+abs(cell1.y-cell2.y) == 2 {
-						connCells = append(connCells, Cell{x: (cell1.x + cell2.x) / 2, y: (cell1.y + cell2.y) / 2})
+					if abs(cell1.x-cell2.x)+abs(cell1.y-cell2.y) == 2 && (cell1.x == cell2.x || cell1.y == cell2.y) {
+						mid := Cell{x: (cell1.x + cell2.x) / 2, y: (cell1.y + cell2.y) / 2}
+						// 门只开在两个格子之间的墙段上，不开在墙的交叉点
+						if d.onWallSegment(mid.x, mid.y) {
+							connCells = append(connCells, mid)
+						}
 					}
 				}
 			}
@@ -446,35 +704,168 @@ func (d *Dungeon) FindConnectionInfo() ([]ConnectedRegion, []ConnectionZone) {
 	return regions, connections
 }
 
+// FillDeadEnds 把死胡同堵上，Corridor.KeepDeadEnds 比例的死胡同会被保留下来
 func (d *Dungeon) FillDeadEnds() {
-	changed := true
-	for changed {
-		changed = false
-		for y := 1; y < d.Height-1; y++ {
-			for x := 1; x < d.Width-1; x++ {
-				if d.Tiles[y][x] == 0 {
-					// 计算周围的墙数量
-					walls := 0
-					if d.Tiles[y-1][x] == 1 {
-						walls++
-					}
-					if d.Tiles[y+1][x] == 1 {
-						walls++
-					}
-					if d.Tiles[y][x-1] == 1 {
-						walls++
-					}
-					if d.Tiles[y][x+1] == 1 {
-						walls++
-					}
+	roomArea := d.markRoomArea()
+	w := d.corridorWidth()
+	p := d.cellPitch()
+	dirs := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+
+	// 死胡同：只有一个方向打通的通道格子
+	isDeadEnd := func(x, y int) bool {
+		if d.Tiles[y][x] != 0 || blockInArea(roomArea, x, y, w) {
+			return false
+		}
+		return len(d.openDirs(x, y, dirs)) == 1
+	}
 
-					// 如果是死胡同（三面墙）
-					if walls == 3 {
-						d.Tiles[y][x] = 1
-						changed = true
-					}
+	// 找出所有初始的死胡同
+	var deadEnds []Cell
+	for y := 1; y+w <= d.Height-1; y += p {
+		for x := 1; x+w <= d.Width-1; x += p {
+			if isDeadEnd(x, y) {
+				deadEnds = append(deadEnds, Cell{x: x, y: y})
+			}
+		}
+	}
+
+	// 随机保留一部分死胡同，保留的死胡同整条都不会被堵上
+	keep := make(map[Cell]bool)
+	if d.Corridor.KeepDeadEnds > 0 {
+		d.rng().Shuffle(len(deadEnds), func(i, j int) {
+			deadEnds[i], deadEnds[j] = deadEnds[j], deadEnds[i]
+		})
+		keepCount := int(float64(len(deadEnds))*d.Corridor.KeepDeadEnds + 0.5)
+		for _, c := range deadEnds[:min(keepCount, len(deadEnds))] {
+			keep[c] = true
+		}
+	}
+
+	queue := deadEnds
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		if keep[cur] || !isDeadEnd(cur.x, cur.y) {
+			continue
+		}
+
+		// 堵上当前格子以及通往它的墙段
+		open := d.openDirs(cur.x, cur.y, dirs)[0]
+		d.fillRect(cur.x, cur.y, w, w, 1)
+		wx, wy, ww, wh := d.wallBetween(cur.x, cur.y, open)
+		d.fillRect(wx, wy, ww, wh, 1)
+
+		// 相邻的格子可能变成新的死胡同
+		next := Cell{x: cur.x + open[0]*p, y: cur.y + open[1]*p}
+		if d.cellInBounds(next.x, next.y) {
+			queue = append(queue, next)
+		}
+	}
+}
+
+// 通道格子打通的方向
+func (d *Dungeon) openDirs(x, y int, dirs [][2]int) [][2]int {
+	var open [][2]int
+	for _, dir := range dirs {
+		wx, wy, _, _ := d.wallBetween(x, y, dir)
+		if wx <= 0 || wy <= 0 || wx >= d.Width-1 || wy >= d.Height-1 {
+			continue
+		}
+		if d.wallOpened(x, y, dir) {
+			open = append(open, dir)
+		}
+	}
+	return open
+}
+
+// StraightenCorridors 拉直通道：把多余的之字形拐弯合并掉，返回调整的格子数
+// 对一个拐角格子B（两侧连接A和C），若A、C对面的格子D是空的，
+// 则尝试用A-D-C替换A-B-C，只有总拐弯数变少时才接受
+func (d *Dungeon) StraightenCorridors() int {
+	roomArea := d.markRoomArea()
+	w := d.corridorWidth()
+	p := d.cellPitch()
+	dirs := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+
+	// 普通的通道格子：不在房间里，且恰好连接两个方向
+	corridorDirs := func(x, y int) [][2]int {
+		if !d.cellInBounds(x, y) || d.Tiles[y][x] != 0 || blockInArea(roomArea, x, y, w) {
+			return nil
+		}
+		open := d.openDirs(x, y, dirs)
+		if len(open) != 2 {
+			return nil
+		}
+		return open
+	}
+	isTurn := func(a, b [2]int) int {
+		if a[0]+b[0] == 0 && a[1]+b[1] == 0 {
+			return 0
+		}
+		return 1
+	}
+	// 把格子的连接方向中的from替换为to后的拐弯数
+	turnsWith := func(open [][2]int, from, to [2]int) int {
+		other := open[0]
+		if other == from {
+			other = open[1]
+		}
+		return isTurn(other, to)
+	}
+
+	changed := 0
+	for pass := 0; pass < d.Width*d.Height; pass++ {
+		improved := false
+		for y := 1; y+w <= d.Height-1; y += p {
+			for x := 1; x+w <= d.Width-1; x += p {
+				openB := corridorDirs(x, y)
+				if openB == nil || isTurn(openB[0], openB[1]) == 0 {
+					continue
+				}
+				da, dc := openB[0], openB[1]
+				ax, ay := x+da[0]*p, y+da[1]*p
+				cx, cy := x+dc[0]*p, y+dc[1]*p
+				openA := corridorDirs(ax, ay)
+				openC := corridorDirs(cx, cy)
+				if openA == nil || openC == nil {
+					continue
+				}
+
+				// D为A、B、C所在2x2格子里剩下的那个
+				dx, dy := ax+cx-x, ay+cy-y
+				if !d.cellInBounds(dx, dy) || d.Tiles[dy][dx] == 0 || blockInArea(roomArea, dx, dy, w) {
+					continue
+				}
+				if len(d.openDirs(dx, dy, dirs)) > 0 {
+					continue
+				}
+
+				backA := [2]int{-da[0], -da[1]}
+				backC := [2]int{-dc[0], -dc[1]}
+				before := turnsWith(openA, backA, backA) + 1 + turnsWith(openC, backC, backC)
+				// A到D的方向与B到C相同，C到D的方向与B到A相同
+				after := turnsWith(openA, backA, dc) + 1 + turnsWith(openC, backC, da)
+				if after >= before {
+					continue
 				}
+
+				// 堵上B，打通D
+				d.fillRect(x, y, w, w, 1)
+				for _, dir := range openB {
+					wx, wy, ww, wh := d.wallBetween(x, y, dir)
+					d.fillRect(wx, wy, ww, wh, 1)
+				}
+				d.fillRect(dx, dy, w, w, 0)
+				d.openWall(ax, ay, dc)
+				d.openWall(cx, cy, da)
+				changed++
+				improved = true
 			}
 		}
+		if !improved {
+			break
+		}
 	}
+	return changed
 }
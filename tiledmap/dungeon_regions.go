@@ -0,0 +1,98 @@
+package tiledmap
+
+// 地牢的连通区域标记
+// 生成过程中房间、迷宫、开门都只会把墙挖开，区域只会合并，所以标记可以在各个阶段之间复用：
+// 挖开一个格子时把它和相邻的区域在并查集中合并，查询时再压缩成连续的编号；
+// 只有把通路填成墙（堵死胡同、拉直通道）时才需要整体重算
+
+// regionCache 缓存的区域标记
+type regionCache struct {
+	labels [][]int // 每个tile的区域编号，墙为-1；编号合并后以uf.Find的结果为准
+	uf     *UnionFind
+}
+
+// 挖开(x,y)：与相邻的区域合并，没有相邻区域时新建一个
+func (c *regionCache) carve(x, y int) {
+	if c.labels[y][x] >= 0 {
+		return
+	}
+	id := -1
+	for _, dir := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+		nx, ny := x+dir[0], y+dir[1]
+		if ny < 0 || ny >= len(c.labels) || nx < 0 || nx >= len(c.labels[ny]) {
+			continue
+		}
+		if l := c.labels[ny][nx]; l >= 0 {
+			if id < 0 {
+				id = l
+			} else {
+				c.uf.Union(id, l)
+			}
+		}
+	}
+	if id < 0 {
+		id = c.uf.add()
+	}
+	c.labels[y][x] = id
+}
+
+// 把编号压缩成0开始的连续编号（按区域第一个格子的行优先顺序，与BFS标记的编号相同），
+// 缓存也换成压缩后的编号；返回的labels是独立的副本
+func (c *regionCache) compact() ([]ConnectedRegion, [][]int) {
+	height := len(c.labels)
+	width := 0
+	if height > 0 {
+		width = len(c.labels[0])
+	}
+	labels := make([][]int, height)
+	row := make([]int, width*height)       // 一次性分配所有内存
+	newId := make([]int, len(c.uf.parent)) // 根编号 -> 压缩后的编号，还没有分配时为-1
+	for i := range newId {
+		newId[i] = -1
+	}
+	var regions []ConnectedRegion
+	for y := range c.labels {
+		labels[y] = row[y*width : (y+1)*width]
+		for x, l := range c.labels[y] {
+			if l < 0 {
+				labels[y][x] = -1
+				continue
+			}
+			root := c.uf.Find(l)
+			id := newId[root]
+			if id < 0 {
+				id = len(regions)
+				newId[root] = id
+				regions = append(regions, ConnectedRegion{id: id})
+			}
+			labels[y][x] = id
+			c.labels[y][x] = id
+			regions[id].cells = append(regions[id].cells, struct{ x, y int }{x, y})
+		}
+	}
+	c.uf = NewUnionFind(len(regions))
+	return regions, labels
+}
+
+// labelRegions 计算所有连通区域，同时返回每个tile所属的区域编号（墙为-1）
+// 第一次调用时扫描整张地图建立缓存，之后直接使用增量维护的缓存
+func (d *Dungeon) labelRegions() ([]ConnectedRegion, [][]int) {
+	if d.regions == nil {
+		c := &regionCache{labels: make([][]int, d.Height), uf: NewUnionFind(0)}
+		for y := range c.labels {
+			c.labels[y] = make([]int, d.Width)
+			for x := range c.labels[y] {
+				c.labels[y][x] = -1
+			}
+		}
+		for y := 1; y < d.Height-1; y++ {
+			for x := 1; x < d.Width-1; x++ {
+				if d.Tiles[y][x] == 0 {
+					c.carve(x, y)
+				}
+			}
+		}
+		d.regions = c
+	}
+	return d.regions.compact()
+}
@@ -0,0 +1,142 @@
+package tiledmap
+
+import (
+	"sort"
+	"testing"
+)
+
+// 生成到连接区域之前的地牢：房间和迷宫通道之间还没有打通
+func unconnectedDungeon(size int, opts CorridorOptions) *Dungeon {
+	d := GenerateDungeonWithOptions(size, size, size, 5, 11, opts)
+	d.GenerateMazeBetweenRooms()
+	d.ConnectPassagesByDFS()
+	return d
+}
+
+// 连接区展开成 区域对+格子 的集合，和格子的顺序无关
+func connectorSet(conns []ConnectionZone) map[[4]int]bool {
+	set := make(map[[4]int]bool)
+	for _, c := range conns {
+		for _, cell := range c.Cells {
+			set[[4]int{c.Region1, c.Region2, cell.x, cell.y}] = true
+		}
+	}
+	return set
+}
+
+// 区域按编号排序后的格子，和格子的顺序无关
+func regionCells(regions []ConnectedRegion) [][]int {
+	out := make([][]int, len(regions))
+	for i, r := range regions {
+		if r.id != i {
+			return nil
+		}
+		for _, c := range r.cells {
+			out[i] = append(out[i], c.y*100000+c.x)
+		}
+		sort.Ints(out[i])
+	}
+	return out
+}
+
+func sameRegions(a, b []ConnectedRegion) bool {
+	ca, cb := regionCells(a), regionCells(b)
+	if ca == nil || cb == nil || len(ca) != len(cb) {
+		return false
+	}
+	for i := range ca {
+		if len(ca[i]) != len(cb[i]) {
+			return false
+		}
+		for j := range ca[i] {
+			if ca[i][j] != cb[i][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestFindConnectionInfoMatchesBruteForce(t *testing.T) {
+	for _, width := range []int{1, 2, 3} {
+		for i := 0; i < 5; i++ {
+			opts := DefaultCorridorOptions()
+			opts.Width = width
+			d := unconnectedDungeon(41+10*i, opts)
+			regions, conns := d.FindConnectionInfo()
+			bruteRegions, bruteConns := d.FindConnectionInfoBruteForce()
+			if !sameRegions(regions, bruteRegions) {
+				t.Fatalf("width %d: regions differ: %d vs %d", width, len(regions), len(bruteRegions))
+			}
+			got, want := connectorSet(conns), connectorSet(bruteConns)
+			if len(got) != len(want) || len(got) == 0 {
+				t.Fatalf("width %d: %d connectors, brute force %d", width, len(got), len(want))
+			}
+			for k := range want {
+				if !got[k] {
+					t.Fatalf("width %d: connector %v missing", width, k)
+				}
+			}
+		}
+	}
+}
+
+// 增量维护的区域标记与从头计算的结果相同：开门只会合并区域，堵死胡同会让缓存作废
+func TestRegionCacheMatchesFresh(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		d := unconnectedDungeon(61, DefaultCorridorOptions())
+		d.FindConnectionInfo()
+		if d.regions == nil {
+			t.Fatal("FindConnectionInfo did not cache the region labels")
+		}
+		d.ConnectAllRegions(0.2)
+		if d.regions == nil {
+			t.Fatal("opening doors invalidated the cache instead of updating it")
+		}
+		cached, _ := d.labelRegions()
+		if len(cached) != 1 {
+			t.Fatalf("%d regions after ConnectAllRegions, want 1", len(cached))
+		}
+		if !sameRegions(cached, d.findConnectedRegions()) {
+			t.Fatal("cached regions differ from a fresh BFS after opening doors")
+		}
+
+		d.FillDeadEnds()
+		after, _ := d.labelRegions()
+		if !sameRegions(after, d.findConnectedRegions()) {
+			t.Fatal("regions differ from a fresh BFS after filling dead ends")
+		}
+	}
+}
+
+func benchmarkDungeon(b *testing.B) *Dungeon {
+	d := unconnectedDungeon(500, DefaultCorridorOptions())
+	b.ResetTimer()
+	return d
+}
+
+// 每次都从头标记区域，和旧实现公平比较
+func BenchmarkFindConnectionInfo(b *testing.B) {
+	d := benchmarkDungeon(b)
+	for i := 0; i < b.N; i++ {
+		d.regions = nil
+		d.FindConnectionInfo()
+	}
+}
+
+// 使用缓存的区域标记，相当于生成过程中第二次查询
+func BenchmarkFindConnectionInfoCached(b *testing.B) {
+	d := benchmarkDungeon(b)
+	d.FindConnectionInfo()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.FindConnectionInfo()
+	}
+}
+
+func BenchmarkFindConnectionInfoBruteForce(b *testing.B) {
+	d := benchmarkDungeon(b)
+	for i := 0; i < b.N; i++ {
+		d.FindConnectionInfoBruteForce()
+	}
+}
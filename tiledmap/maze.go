@@ -1,6 +1,7 @@
 package tiledmap
 
 import (
+	"math"
 	"math/rand"
 )
 
@@ -8,66 +9,88 @@ type Point struct {
 	x, y int
 }
 
+// GenerateMaze 使用深度优先回溯生成迷宫，turnProb控制转弯的偏好
+// 使用显式栈代替递归，避免大尺寸迷宫时递归过深
 func GenerateMaze(size int, turnProb float64) [][]int {
-	maze := make([][]int, size)
-	for i := range maze {
-		maze[i] = make([]int, size)
-		for j := range maze[i] {
-			maze[i][j] = 1 // 1表示墙
-		}
-	}
+	return GenerateMazeWithOptions(size, MazeOptions{Algorithm: MazeDFS, TurnProb: turnProb})
+}
 
-	var dfs func(p Point, lastp int)
-	dfs = func(p Point, lastp int) {
-		maze[p.x][p.y] = 0 // 0表示路径
-		dirs := []Point{{-2, 0}, {2, 0}, {0, -2}, {0, 2}}
+// 深度优先回溯：每进入一个格子，按turnProb决定优先转弯还是优先沿用之前的方向
+func carveMazeDFS(g *mazeGrid, turnProb float64) {
+	dirs := []Point{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
 
-		pos := []int{0, 1, 2, 3}
+	// 栈帧：当前格子、方向顺序、下一个要尝试的方向
+	type frame struct {
+		p     Point
+		order [4]int
+		next  int
+	}
+
+	newFrame := func(p Point, lastp int) frame {
+		pos := [4]int{0, 1, 2, 3}
 
-		if rand.Float64() < turnProb {
+		if g.rng.Float64() < turnProb {
 			if lastp <= 1 {
-				pos = []int{pos[2], pos[3], pos[0], pos[1]}
+				pos = [4]int{pos[2], pos[3], pos[0], pos[1]}
 			}
 		} else {
 			if lastp > 1 {
-				pos = []int{pos[2], pos[3], pos[0], pos[1]}
+				pos = [4]int{pos[2], pos[3], pos[0], pos[1]}
 			}
 		}
 
 		pos1 := pos[:2]
 		pos2 := pos[2:]
 
-		rand.Shuffle(len(pos1), func(i, j int) {
+		g.rng.Shuffle(len(pos1), func(i, j int) {
 			pos1[i], pos1[j] = pos1[j], pos1[i]
 		})
 
-		rand.Shuffle(len(pos2), func(i, j int) {
+		g.rng.Shuffle(len(pos2), func(i, j int) {
 			pos2[i], pos2[j] = pos2[j], pos2[i]
 		})
 
-		for _, pp := range pos {
-			next := Point{p.x + dirs[pp].x, p.y + dirs[pp].y}
-			if next.x >= 0 && next.x < size && next.y >= 0 && next.y < size && maze[next.x][next.y] == 1 {
-				maze[p.x+dirs[pp].x/2][p.y+dirs[pp].y/2] = 0
-				dfs(next, pp)
-			}
-		}
+		g.carve(p)
+		return frame{p: p, order: pos}
 	}
 
-	dfs(Point{0, 0}, 0)
-	maze[0][0] = 0
-	maze[size-1][size-1] = 0
+	stack := []frame{newFrame(g.start, 0)}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.next >= len(top.order) {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		pp := top.order[top.next]
+		top.next++
 
-	return maze
+		next := Point{top.p.x + dirs[pp].x, top.p.y + dirs[pp].y}
+		if g.inBounds(next) && !g.visited(next) {
+			g.link(top.p, next)
+			stack = append(stack, newFrame(next, pp))
+		}
+	}
+}
+
+// MazeCorners 返回默认的起点和终点：左上角和右下角
+func MazeCorners(maze [][]int) (start, end [2]int) {
+	return [2]int{0, 0}, [2]int{len(maze) - 1, len(maze[0]) - 1}
 }
 
 func AccuMaze(maze [][]int, path [][]bool, accPrecent float64) int {
-	size := len(maze)
+	start, end := MazeCorners(maze)
+	return AccuMazeBetween(maze, path, accPrecent, start, end)
+}
+
+// AccuMazeBetween 填充断头路来堆积障碍，start和end永远不会被填上
+func AccuMazeBetween(maze [][]int, path [][]bool, accPrecent float64, start, end [2]int) int {
+	height, width := len(maze), len(maze[0])
 	dirs := []Point{{0, 1}, {1, 0}, {0, -1}, {-1, 0}}
+	ends := [2]Point{{start[0], start[1]}, {end[0], end[1]}}
 	totalCount := 0
 
 	// 计算初始断头路数量
-	initialDeadEnds := countDeadEnds(maze, size, path)
+	initialDeadEnds := countDeadEnds(maze, path)
 	if initialDeadEnds == 0 {
 		return 0
 	}
@@ -76,9 +99,9 @@ func AccuMaze(maze [][]int, path [][]bool, accPrecent float64) int {
 	queue := make([]Point, 0)
 
 	// 首次遍历找出所有断头路
-	for i := 0; i < size; i++ {
-		for j := 0; j < size; j++ {
-			if isDeadEnd(Point{i, j}, maze) {
+	for i := 0; i < height; i++ {
+		for j := 0; j < width; j++ {
+			if isDeadEnd(Point{i, j}, maze, ends) {
 				queue = append(queue, Point{i, j})
 			}
 		}
@@ -90,12 +113,12 @@ func AccuMaze(maze [][]int, path [][]bool, accPrecent float64) int {
 		queue = queue[1:]
 
 		// 如果是起点或终点，跳过
-		if (cur.x == 0 && cur.y == 0) || (cur.x == size-1 && cur.y == size-1) {
+		if cur == ends[0] || cur == ends[1] {
 			continue
 		}
 
 		// 如果当前点仍然是断头路（可能在处理其他点时被改变）
-		if isDeadEnd(cur, maze) {
+		if isDeadEnd(cur, maze, ends) {
 			// 填充当前断头路
 			maze[cur.x][cur.y] = 1
 			totalCount++
@@ -103,9 +126,9 @@ func AccuMaze(maze [][]int, path [][]bool, accPrecent float64) int {
 			// 检查周围的点是否变成新的断头路
 			for _, d := range dirs {
 				next := Point{cur.x + d.x, cur.y + d.y}
-				if next.x >= 0 && next.x < size && next.y >= 0 && next.y < size &&
+				if next.x >= 0 && next.x < height && next.y >= 0 && next.y < width &&
 					maze[next.x][next.y] == 0 &&
-					isDeadEnd(next, maze) {
+					isDeadEnd(next, maze, ends) {
 					queue = append(queue, next)
 				}
 			}
@@ -116,17 +139,117 @@ func AccuMaze(maze [][]int, path [][]bool, accPrecent float64) int {
 }
 
 func ErosionMaze(maze [][]int, erosionPercent float64) int {
-	size := len(maze)
+	return ErosionMazeRand(newRand(), maze, erosionPercent)
+}
+
+// ErosionMazeRand 和ErosionMaze相同，使用r作为随机源
+func ErosionMazeRand(r *rand.Rand, maze [][]int, erosionPercent float64) int {
+	return erodeMaze(r, maze, erosionPercent, nil)
+}
+
+// ErosionResult 限制路径缩短的侵蚀结果
+type ErosionResult struct {
+	Eroded         int // 侵蚀掉的墙数
+	Rejected       int // 因为会让路径缩短太多而保留的墙数
+	OriginalLength int // 侵蚀前起点到终点最短路径的长度（格子数），不连通时为0
+	FinalLength    int // 侵蚀后起点到终点最短路径的长度（格子数）
+}
+
+// ErosionMazeBounded 和ErosionMaze一样按权重侵蚀，但是拒绝会让start到end的最短路径
+// 缩短超过maxShorten比例的墙，maxShorten为1时等价于ErosionMaze
+// 通过维护到起点和终点的距离场判断：打通墙w后经过w的最短路径为 min(ds[a]) + min(de[b]) + 2
+func ErosionMazeBounded(maze [][]int, erosionPercent, maxShorten float64, start, end [2]int) ErosionResult {
+	return ErosionMazeBoundedRand(newRand(), maze, erosionPercent, maxShorten, start, end)
+}
+
+// ErosionMazeBoundedRand 和ErosionMazeBounded相同，使用r作为随机源
+func ErosionMazeBoundedRand(r *rand.Rand, maze [][]int, erosionPercent, maxShorten float64, start, end [2]int) ErosionResult {
+	var res ErosionResult
+
+	ds := mazeDistances(maze, start)
+	de := mazeDistances(maze, end)
+	origin := ds[end[0]][end[1]]
+	if origin < 0 {
+		// 起点终点不连通时没有路径可以保护
+		res.Eroded = ErosionMazeRand(r, maze, erosionPercent)
+		return res
+	}
+	res.OriginalLength = origin + 1
+
+	// 允许的最短路径步数
+	minSteps := int(math.Ceil(float64(origin) * (1 - maxShorten)))
+	dirs := []Point{{0, 1}, {1, 0}, {0, -1}, {-1, 0}}
+
+	accept := func(p Point) bool {
+		bestS, bestE := -1, -1
+		for _, d := range dirs {
+			nx, ny := p.x+d.x, p.y+d.y
+			if v := ds[nx][ny]; v >= 0 && (bestS < 0 || v < bestS) {
+				bestS = v
+			}
+			if v := de[nx][ny]; v >= 0 && (bestE < 0 || v < bestE) {
+				bestE = v
+			}
+		}
+		if bestS >= 0 && bestE >= 0 && bestS+bestE+2 < minSteps {
+			res.Rejected++
+			return false
+		}
+		// 接受后把距离的减小传播出去
+		relaxDistances(maze, ds, p)
+		relaxDistances(maze, de, p)
+		return true
+	}
+
+	res.Eroded = erodeMaze(r, maze, erosionPercent, accept)
+	res.FinalLength = ds[end[0]][end[1]] + 1
+	return res
+}
+
+// 格子p刚被挖开，用BFS把它带来的距离减小传播到整个距离场
+func relaxDistances(maze [][]int, dist [][]int, p Point) {
+	height, width := len(maze), len(maze[0])
+	dirs := []Point{{0, 1}, {1, 0}, {0, -1}, {-1, 0}}
+
+	for _, d := range dirs {
+		nx, ny := p.x+d.x, p.y+d.y
+		if v := dist[nx][ny]; v >= 0 && (dist[p.x][p.y] < 0 || v+1 < dist[p.x][p.y]) {
+			dist[p.x][p.y] = v + 1
+		}
+	}
+	if dist[p.x][p.y] < 0 {
+		return
+	}
+
+	queue := []Point{p}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, d := range dirs {
+			nx, ny := cur.x+d.x, cur.y+d.y
+			if nx >= 0 && nx < height && ny >= 0 && ny < width && maze[nx][ny] == 0 &&
+				(dist[nx][ny] < 0 || dist[nx][ny] > dist[cur.x][cur.y]+1) {
+				dist[nx][ny] = dist[cur.x][cur.y] + 1
+				queue = append(queue, Point{nx, ny})
+			}
+		}
+	}
+}
+
+// 按权重随机侵蚀墙，accept不为nil时由它决定选中的墙能否被侵蚀，被拒绝的墙不再成为候选
+func erodeMaze(r *rand.Rand, maze [][]int, erosionPercent float64, accept func(Point) bool) int {
+	height, width := len(maze), len(maze[0])
 	dirs := []Point{{0, 1}, {1, 0}, {0, -1}, {-1, 0}}
 
 	// 初始化候选点队列和权重映射
 	candidates := make([]Point, 0)
 	weightMap := make(map[Point]float64)
+	rejected := make(map[Point]bool)
 
 	// 计算初始可侵蚀点和总墙数
 	totalWalls := 0
-	for i := 1; i < size-1; i++ {
-		for j := 1; j < size-1; j++ {
+	for i := 1; i < height-1; i++ {
+		for j := 1; j < width-1; j++ {
 			if maze[i][j] == 1 {
 				totalWalls++
 				p := Point{i, j}
@@ -150,26 +273,34 @@ func ErosionMaze(maze [][]int, erosionPercent float64) int {
 	eroded := 0
 	for eroded < targetCount && len(candidates) > 0 {
 		// 根据权重选择一个点
-		selectedIdx := selectPointByWeight(candidates, weightMap)
+		selectedIdx := selectPointByWeight(r, candidates, weightMap)
 		if selectedIdx < 0 {
 			break
 		}
 
-		// 侵蚀选中的点
+		// 从候选列表中移除选中的点
 		selected := candidates[selectedIdx]
-		maze[selected.x][selected.y] = 0
-		eroded++
-
-		// 从候选列表中移除已侵蚀的点
 		candidates[selectedIdx] = candidates[len(candidates)-1]
 		candidates = candidates[:len(candidates)-1]
 		delete(weightMap, selected)
 
+		// 侵蚀选中的点
+		maze[selected.x][selected.y] = 0
+		if accept != nil && !accept(selected) {
+			maze[selected.x][selected.y] = 1
+			rejected[selected] = true
+			continue
+		}
+		eroded++
+
 		// 更新受影响点的权重
 		for _, d := range dirs {
 			nx, ny := selected.x+d.x, selected.y+d.y
-			if nx >= 1 && nx < size-1 && ny >= 1 && ny < size-1 && maze[nx][ny] == 1 {
+			if nx >= 1 && nx < height-1 && ny >= 1 && ny < width-1 && maze[nx][ny] == 1 {
 				p := Point{nx, ny}
+				if rejected[p] {
+					continue
+				}
 				if weight := calculateWeight(p, maze, dirs); weight > 0 {
 					weightMap[p] = weight
 					if !containsPoint(candidates, p) {
@@ -208,7 +339,7 @@ func calculateWeight(p Point, maze [][]int, dirs []Point) float64 {
 }
 
 // 根据权重选择点
-func selectPointByWeight(candidates []Point, weightMap map[Point]float64) int {
+func selectPointByWeight(r *rand.Rand, candidates []Point, weightMap map[Point]float64) int {
 	if len(candidates) == 0 {
 		return -1
 	}
@@ -218,7 +349,7 @@ func selectPointByWeight(candidates []Point, weightMap map[Point]float64) int {
 		totalWeight += weightMap[p]
 	}
 
-	randWeight := rand.Float64() * totalWeight
+	randWeight := r.Float64() * totalWeight
 	cumWeight := 0.0
 
 	for i, p := range candidates {
@@ -241,15 +372,15 @@ func containsPoint(candidates []Point, p Point) bool {
 	return false
 }
 
-// 辅助函数���判断一个点是否是断头路
-func isDeadEnd(p Point, maze [][]int) bool {
-	size := len(maze)
+// 辅助函数：判断一个点是否是断头路
+func isDeadEnd(p Point, maze [][]int, ends [2]Point) bool {
+	height, width := len(maze), len(maze[0])
 	if maze[p.x][p.y] != 0 {
 		return false
 	}
 
 	// 如果是起点或终点，不算断头路
-	if (p.x == 0 && p.y == 0) || (p.x == size-1 && p.y == size-1) {
+	if p == ends[0] || p == ends[1] {
 		return false
 	}
 
@@ -258,7 +389,7 @@ func isDeadEnd(p Point, maze [][]int) bool {
 	pathCount := 0
 	for _, d := range dirs {
 		nx, ny := p.x+d.x, p.y+d.y
-		if nx >= 0 && nx < size && ny >= 0 && ny < size && maze[nx][ny] == 0 {
+		if nx >= 0 && nx < height && ny >= 0 && ny < width && maze[nx][ny] == 0 {
 			pathCount++
 		}
 	}
@@ -266,12 +397,12 @@ func isDeadEnd(p Point, maze [][]int) bool {
 }
 
 // 计算非最短路径上的空白格子数量
-func countDeadEnds(maze [][]int, size int, path [][]bool) int {
+func countDeadEnds(maze [][]int, path [][]bool) int {
 	count := 0
 
 	// 遍历整个迷宫
-	for i := 0; i < size; i++ {
-		for j := 0; j < size; j++ {
+	for i := range maze {
+		for j := range maze[i] {
 			// 如果是通路(值为0)且不在最短路径上
 			if maze[i][j] == 0 && !path[i][j] {
 				count++
@@ -283,16 +414,26 @@ func countDeadEnds(maze [][]int, size int, path [][]bool) int {
 }
 
 func FindPath(maze [][]int) [][]bool {
-	size := len(maze)
-	path := make([][]bool, size)
+	start, end := MazeCorners(maze)
+	return FindPathBetween(maze, start, end)
+}
+
+// FindPathBetween 用BFS寻找start到end的最短路径，返回路径上的格子标记，找不到时全为false
+func FindPathBetween(maze [][]int, start, end [2]int) [][]bool {
+	height, width := len(maze), len(maze[0])
+	path := make([][]bool, height)
 	for i := range path {
-		path[i] = make([]bool, size)
+		path[i] = make([]bool, width)
 	}
 
+	src := Point{start[0], start[1]}
+	dst := Point{end[0], end[1]}
+
 	// 使用队列进行BFS搜索
-	queue := []Point{{0, 0}}
+	queue := []Point{src}
 	// 记录每个点的前驱节点,用于回溯路径
 	parent := make(map[Point]Point)
+	path[src.x][src.y] = true
 
 	dirs := []Point{{0, 1}, {1, 0}, {0, -1}, {-1, 0}}
 	found := false
@@ -301,14 +442,14 @@ func FindPath(maze [][]int) [][]bool {
 		cur := queue[0]
 		queue = queue[1:]
 
-		if cur.x == size-1 && cur.y == size-1 {
+		if cur == dst {
 			found = true
 			break
 		}
 
 		for _, d := range dirs {
 			next := Point{cur.x + d.x, cur.y + d.y}
-			if next.x >= 0 && next.x < size && next.y >= 0 && next.y < size &&
+			if next.x >= 0 && next.x < height && next.y >= 0 && next.y < width &&
 				maze[next.x][next.y] == 0 && !path[next.x][next.y] {
 				queue = append(queue, next)
 				parent[next] = cur
@@ -324,12 +465,12 @@ func FindPath(maze [][]int) [][]bool {
 	}
 	if found {
 		// 从终点回溯到起点,标记路径
-		cur := Point{size - 1, size - 1}
-		for cur.x != 0 || cur.y != 0 {
+		cur := dst
+		for cur != src {
 			path[cur.x][cur.y] = true
 			cur = parent[cur]
 		}
-		path[0][0] = true
+		path[src.x][src.y] = true
 	}
 
 	return path
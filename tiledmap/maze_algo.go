@@ -0,0 +1,623 @@
+package tiledmap
+
+import (
+	"math/rand"
+)
+
+// MazeAlgorithm 迷宫生成算法
+type MazeAlgorithm string
+
+const (
+	MazeDFS         MazeAlgorithm = "dfs"         // 深度优先回溯（支持转弯偏好）
+	MazePrim        MazeAlgorithm = "prim"        // 随机Prim
+	MazeKruskal     MazeAlgorithm = "kruskal"     // 随机Kruskal
+	MazeWilson      MazeAlgorithm = "wilson"      // Wilson，均匀生成树
+	MazeEller       MazeAlgorithm = "eller"       // Eller，逐行生成
+	MazeGrowingTree MazeAlgorithm = "growingtree" // Growing Tree
+	MazeHuntAndKill MazeAlgorithm = "huntandkill" // Hunt-and-Kill
+)
+
+// MazeAlgorithms 所有可选的迷宫生成算法，按展示顺序排列
+var MazeAlgorithms = []MazeAlgorithm{
+	MazeDFS, MazePrim, MazeKruskal, MazeWilson, MazeEller, MazeGrowingTree, MazeHuntAndKill,
+}
+
+// GrowingTreeStrategy Growing Tree算法每一步从活动列表中挑选格子的方式
+type GrowingTreeStrategy string
+
+const (
+	GrowingTreeNewest GrowingTreeStrategy = "newest" // 总是选最新的，等价于深度优先回溯
+	GrowingTreeRandom GrowingTreeStrategy = "random" // 随机选，效果接近Prim
+	GrowingTreeOldest GrowingTreeStrategy = "oldest" // 总是选最旧的，生成很长的直通道
+	GrowingTreeMixed  GrowingTreeStrategy = "mixed"  // 一半概率选最新的，一半概率随机选
+)
+
+// GrowingTreeStrategies 所有可选的Growing Tree挑选方式
+var GrowingTreeStrategies = []GrowingTreeStrategy{
+	GrowingTreeNewest, GrowingTreeRandom, GrowingTreeOldest, GrowingTreeMixed,
+}
+
+// MazeOptions 迷宫生成参数
+type MazeOptions struct {
+	Algorithm   MazeAlgorithm       // 生成算法，默认为深度优先回溯
+	TurnProb    float64             // 转弯偏好，只对深度优先回溯生效
+	GrowingTree GrowingTreeStrategy // Growing Tree的挑选方式
+	Start       *[2]int             // 入口（行，列），nil时为左上角
+	End         *[2]int             // 出口（行，列），nil时为右下角
+
+	// 唯一路径的目标长度（格子数，包含起点和终点），0表示不控制
+	TargetLength int
+	// 唯一路径的目标长度占所有通路格子的比例，TargetLength为0时生效
+	TargetRatio float64
+	// 允许的相对误差，默认为DefaultLengthTolerance
+	LengthTolerance float64
+	// 达不到目标时最多重新生成的次数，默认为DefaultLengthAttempts
+	MaxAttempts int
+	// 随机源，nil时从math/rand的全局随机源取一个种子
+	Rand *rand.Rand
+}
+
+const (
+	DefaultLengthTolerance = 0.05
+	DefaultLengthAttempts  = 20
+)
+
+// MazeResult 迷宫生成的详细结果
+type MazeResult struct {
+	Maze         [][]int
+	Start, End   [2]int // 入口和出口的实际位置
+	PathLength   int    // 入口到出口唯一路径的长度（格子数）
+	TargetLength int    // 目标长度，0表示没有控制
+	Attempts     int    // 生成的次数
+}
+
+// GenerateMazeWithOptions 按指定算法生成size*size的迷宫
+func GenerateMazeWithOptions(size int, opts MazeOptions) [][]int {
+	return GenerateRectMaze(size, size, opts)
+}
+
+// GenerateRectMaze 按指定算法生成height行width列的迷宫
+// 所有算法生成的都是相同格式的网格：偶数坐标为格子，格子之间隔一格墙
+// 宽高为偶数时最后一列（行）没有格子，入口和出口的实际位置见 MazeEnds
+func GenerateRectMaze(width, height int, opts MazeOptions) [][]int {
+	return GenerateMazeDetailed(width, height, opts).Maze
+}
+
+// GenerateMazeDetailed 生成迷宫并返回入口、出口以及唯一路径的长度
+// 指定了目标长度时：没有指定出口则按到入口的距离挑选出口格子；指定了出口则重新生成，
+// 直到路径长度落在误差范围内或者用完重试次数，返回最接近目标的那个迷宫
+func GenerateMazeDetailed(width, height int, opts MazeOptions) MazeResult {
+	start, end := MazeEnds(width, height, opts)
+	if opts.Rand == nil {
+		opts.Rand = newRand()
+	}
+
+	target := opts.TargetLength
+	if target <= 0 && opts.TargetRatio > 0 {
+		// 完美迷宫中通路格子数 = 格子数 + 格子间打通的墙数
+		cells := ((width + 1) / 2) * ((height + 1) / 2)
+		target = int(opts.TargetRatio*float64(2*cells-1) + 0.5)
+	}
+	if target <= 0 {
+		maze := carveMaze(width, height, opts, start, end)
+		return MazeResult{
+			Maze:       maze,
+			Start:      start,
+			End:        end,
+			PathLength: mazeDistances(maze, start)[end[0]][end[1]] + 1,
+			Attempts:   1,
+		}
+	}
+
+	tolerance := opts.LengthTolerance
+	if tolerance <= 0 {
+		tolerance = DefaultLengthTolerance
+	}
+	attempts := opts.MaxAttempts
+	if attempts <= 0 {
+		attempts = DefaultLengthAttempts
+	}
+
+	best := MazeResult{TargetLength: target}
+	for i := 1; i <= attempts; i++ {
+		maze := carveMaze(width, height, opts, start, end)
+		dist := mazeDistances(maze, start)
+
+		goal := end
+		if opts.End == nil {
+			goal = closestByDistance(opts.Rand, dist, start, target)
+		}
+		length := dist[goal[0]][goal[1]] + 1
+
+		if best.Maze == nil || abs(length-target) < abs(best.PathLength-target) {
+			best.Maze, best.End, best.PathLength = maze, goal, length
+		}
+		best.Attempts = i
+		if float64(abs(length-target)) <= tolerance*float64(target) {
+			break
+		}
+	}
+	best.Start = start
+
+	// 按距离挑选的出口可能与默认出口不同，把默认出口挖开的墙（奇数坐标）堵回去
+	if opts.End == nil && best.End != end {
+		r := end[0] &^ 1
+		for _, pos := range [][2]int{{r, end[1]}, end} {
+			if pos[0]%2 == 1 || pos[1]%2 == 1 {
+				best.Maze[pos[0]][pos[1]] = 1
+			}
+		}
+	}
+	return best
+}
+
+// 生成一个迷宫并挖开入口和出口
+func carveMaze(width, height int, opts MazeOptions, start, end [2]int) [][]int {
+	g := newMazeGrid(width, height)
+	g.rng = opts.Rand
+	g.start = Point{start[0] / 2, start[1] / 2}
+
+	switch opts.Algorithm {
+	case MazePrim:
+		carveMazePrim(g)
+	case MazeKruskal:
+		carveMazeKruskal(g)
+	case MazeWilson:
+		carveMazeWilson(g)
+	case MazeEller:
+		carveMazeEller(g)
+	case MazeGrowingTree:
+		carveMazeGrowingTree(g, opts.GrowingTree)
+	case MazeHuntAndKill:
+		carveMazeHuntAndKill(g)
+	default:
+		carveMazeDFS(g, opts.TurnProb)
+	}
+
+	g.carveEnd(start)
+	g.carveEnd(end)
+
+	return g.maze
+}
+
+// BFS计算从start出发到每个通路格子的步数，不可达为-1
+func mazeDistances(maze [][]int, start [2]int) [][]int {
+	height, width := len(maze), len(maze[0])
+	dist := make([][]int, height)
+	for i := range dist {
+		dist[i] = make([]int, width)
+		for j := range dist[i] {
+			dist[i][j] = -1
+		}
+	}
+
+	dirs := []Point{{0, 1}, {1, 0}, {0, -1}, {-1, 0}}
+	queue := []Point{{start[0], start[1]}}
+	dist[start[0]][start[1]] = 0
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, d := range dirs {
+			next := Point{cur.x + d.x, cur.y + d.y}
+			if next.x >= 0 && next.x < height && next.y >= 0 && next.y < width &&
+				maze[next.x][next.y] == 0 && dist[next.x][next.y] < 0 {
+				dist[next.x][next.y] = dist[cur.x][cur.y] + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+	return dist
+}
+
+// 在所有格子（偶数坐标）中挑选路径长度最接近target的一个，相同时随机挑选
+func closestByDistance(r *rand.Rand, dist [][]int, start [2]int, target int) [2]int {
+	best, bestDiff, ties := start, -1, 0
+	for i := 0; i < len(dist); i += 2 {
+		for j := 0; j < len(dist[i]); j += 2 {
+			if dist[i][j] <= 0 {
+				continue
+			}
+			diff := abs(dist[i][j] + 1 - target)
+			switch {
+			case bestDiff < 0 || diff < bestDiff:
+				best, bestDiff, ties = [2]int{i, j}, diff, 1
+			case diff == bestDiff:
+				ties++
+				if r.Intn(ties) == 0 {
+					best = [2]int{i, j}
+				}
+			}
+		}
+	}
+	return best
+}
+
+// MazeEnds 计算迷宫入口和出口的实际位置
+// 指定的位置会被限制在地图内，并对齐到它所在的格子；只有在最后一行（列）没有格子时，
+// 才保留在这一行（列）上，由格子挖一段短通道过去，这样不会在迷宫里引入环
+func MazeEnds(width, height int, opts MazeOptions) (start, end [2]int) {
+	start = [2]int{0, 0}
+	end = [2]int{height - 1, width - 1}
+	if opts.Start != nil {
+		start = *opts.Start
+	}
+	if opts.End != nil {
+		end = *opts.End
+	}
+	return alignMazeEnd(start, width, height), alignMazeEnd(end, width, height)
+}
+
+func alignMazeEnd(pos [2]int, width, height int) [2]int {
+	pos[0] = max(0, min(pos[0], height-1))
+	pos[1] = max(0, min(pos[1], width-1))
+	// 奇数坐标是墙，只有在它后面已经没有格子时才保留
+	if pos[0]%2 == 1 && pos[0]+1 < height {
+		pos[0]--
+	}
+	if pos[1]%2 == 1 && pos[1]+1 < width {
+		pos[1]--
+	}
+	return pos
+}
+
+// mazeGrid 以格子为单位操作迷宫，格子(r,c)对应迷宫中的(2r,2c)
+type mazeGrid struct {
+	maze       [][]int
+	rows, cols int
+	start      Point // 生成的起始格子
+	rng        *rand.Rand
+}
+
+func newMazeGrid(width, height int) *mazeGrid {
+	maze := make([][]int, height)
+	for i := range maze {
+		maze[i] = make([]int, width)
+		for j := range maze[i] {
+			maze[i][j] = 1 // 1表示墙
+		}
+	}
+	return &mazeGrid{maze: maze, rows: (height + 1) / 2, cols: (width + 1) / 2}
+}
+
+// 从入口（出口）所在的格子挖到它的实际位置
+func (g *mazeGrid) carveEnd(pos [2]int) {
+	r, c := pos[0]&^1, pos[1]&^1
+	g.maze[r][c] = 0
+	g.maze[r][pos[1]] = 0
+	g.maze[pos[0]][pos[1]] = 0
+}
+
+func (g *mazeGrid) inBounds(p Point) bool {
+	return p.x >= 0 && p.x < g.rows && p.y >= 0 && p.y < g.cols
+}
+
+// 格子是否已经被挖开
+func (g *mazeGrid) visited(p Point) bool {
+	return g.maze[p.x*2][p.y*2] == 0
+}
+
+func (g *mazeGrid) carve(p Point) {
+	g.maze[p.x*2][p.y*2] = 0 // 0表示路径
+}
+
+// 打通两个相邻格子以及它们之间的墙
+func (g *mazeGrid) link(a, b Point) {
+	g.carve(a)
+	g.carve(b)
+	g.maze[a.x+b.x][a.y+b.y] = 0
+}
+
+var mazeCellDirs = []Point{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+
+// 相邻的所有格子
+func (g *mazeGrid) neighbors(p Point) []Point {
+	var out []Point
+	for _, d := range mazeCellDirs {
+		n := Point{p.x + d.x, p.y + d.y}
+		if g.inBounds(n) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// 相邻格子中已访问（visited为true）或未访问的格子
+func (g *mazeGrid) neighborsVisited(p Point, visited bool) []Point {
+	var out []Point
+	for _, n := range g.neighbors(p) {
+		if g.visited(n) == visited {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// 一行的格子是否都已经被挖开
+func (g *mazeGrid) rowVisited(r int) bool {
+	for c := 0; c < g.cols; c++ {
+		if !g.visited(Point{r, c}) {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *mazeGrid) randomCell() Point {
+	return Point{g.rng.Intn(g.rows), g.rng.Intn(g.cols)}
+}
+
+// 随机Prim：维护一个边界集合，每次随机取出一个边界格子，连到随机一个已访问的邻居上
+func carveMazePrim(g *mazeGrid) {
+	inFrontier := make(map[Point]bool)
+	var frontier []Point
+	addFrontier := func(p Point) {
+		for _, n := range g.neighborsVisited(p, false) {
+			if !inFrontier[n] {
+				inFrontier[n] = true
+				frontier = append(frontier, n)
+			}
+		}
+	}
+
+	g.carve(g.start)
+	addFrontier(g.start)
+
+	for len(frontier) > 0 {
+		i := g.rng.Intn(len(frontier))
+		cur := frontier[i]
+		frontier[i] = frontier[len(frontier)-1]
+		frontier = frontier[:len(frontier)-1]
+
+		in := g.neighborsVisited(cur, true)
+		g.link(cur, in[g.rng.Intn(len(in))])
+		addFrontier(cur)
+	}
+}
+
+// 随机Kruskal：把所有相邻格子之间的墙打乱，依次打通连接两个不同集合的墙
+func carveMazeKruskal(g *mazeGrid) {
+	type edge struct{ a, b Point }
+	var edges []edge
+	for r := 0; r < g.rows; r++ {
+		for c := 0; c < g.cols; c++ {
+			if r+1 < g.rows {
+				edges = append(edges, edge{Point{r, c}, Point{r + 1, c}})
+			}
+			if c+1 < g.cols {
+				edges = append(edges, edge{Point{r, c}, Point{r, c + 1}})
+			}
+		}
+	}
+	g.rng.Shuffle(len(edges), func(i, j int) {
+		edges[i], edges[j] = edges[j], edges[i]
+	})
+
+	uf := NewUnionFind(g.rows * g.cols)
+	id := func(p Point) int { return p.x*g.cols + p.y }
+	for _, e := range edges {
+		if uf.Find(id(e.a)) != uf.Find(id(e.b)) {
+			uf.Union(id(e.a), id(e.b))
+			g.link(e.a, e.b)
+		}
+	}
+	// 只有一个格子时也要挖开
+	g.carve(g.start)
+}
+
+// Wilson：从未访问的格子出发随机游走，直到碰到迷宫，然后把去掉环之后的路径加入迷宫
+// 生成的是所有生成树中的均匀随机一棵
+func carveMazeWilson(g *mazeGrid) {
+	g.carve(g.randomCell())
+
+	// 每个格子在游走中最后一次离开的方向
+	exit := make([]Point, g.rows*g.cols)
+	id := func(p Point) int { return p.x*g.cols + p.y }
+	for r := 0; r < g.rows; r++ {
+		for c := 0; c < g.cols; c++ {
+			start := Point{r, c}
+			if g.visited(start) {
+				continue
+			}
+
+			// 随机游走，后走的方向会覆盖之前的方向，相当于去掉了环
+			cur := start
+			for !g.visited(cur) {
+				ns := g.neighbors(cur)
+				next := ns[g.rng.Intn(len(ns))]
+				exit[id(cur)] = next
+				cur = next
+			}
+
+			// 沿着记录的方向把路径挖开，直到接上原来的迷宫
+			for cur = start; ; {
+				next := exit[id(cur)]
+				reached := g.visited(next)
+				g.link(cur, next)
+				if reached {
+					break
+				}
+				cur = next
+			}
+		}
+	}
+}
+
+// Growing Tree：维护一个活动列表，按strategy挑选一个格子向未访问的邻居扩展，没有可扩展的邻居时移出列表
+func carveMazeGrowingTree(g *mazeGrid, strategy GrowingTreeStrategy) {
+	g.carve(g.start)
+	active := []Point{g.start}
+
+	for len(active) > 0 {
+		var i int
+		switch strategy {
+		case GrowingTreeRandom:
+			i = g.rng.Intn(len(active))
+		case GrowingTreeOldest:
+			i = 0
+		case GrowingTreeMixed:
+			if g.rng.Float64() < 0.5 {
+				i = len(active) - 1
+			} else {
+				i = g.rng.Intn(len(active))
+			}
+		default:
+			i = len(active) - 1
+		}
+
+		cur := active[i]
+		ns := g.neighborsVisited(cur, false)
+		if len(ns) == 0 {
+			active = append(active[:i], active[i+1:]...)
+			continue
+		}
+		next := ns[g.rng.Intn(len(ns))]
+		g.link(cur, next)
+		active = append(active, next)
+	}
+}
+
+// Hunt-and-Kill：随机游走直到无路可走，然后逐行扫描找到一个与迷宫相邻的未访问格子继续游走
+func carveMazeHuntAndKill(g *mazeGrid) {
+	cur := g.randomCell()
+	g.carve(cur)
+	huntRow := 0
+
+	for {
+		// kill：随机游走
+		for {
+			ns := g.neighborsVisited(cur, false)
+			if len(ns) == 0 {
+				break
+			}
+			next := ns[g.rng.Intn(len(ns))]
+			g.link(cur, next)
+			cur = next
+		}
+
+		// hunt：寻找下一个起点，已经全部访问过的行不需要再扫描
+		for huntRow < g.rows && g.rowVisited(huntRow) {
+			huntRow++
+		}
+		found := false
+		for r := huntRow; r < g.rows && !found; r++ {
+			for c := 0; c < g.cols && !found; c++ {
+				p := Point{r, c}
+				if g.visited(p) {
+					continue
+				}
+				in := g.neighborsVisited(p, true)
+				if len(in) > 0 {
+					g.link(p, in[g.rng.Intn(len(in))])
+					cur = p
+					found = true
+				}
+			}
+		}
+		if !found {
+			return
+		}
+	}
+}
+
+// Eller：逐行生成，只需要记住当前一行的集合信息
+func carveMazeEller(g *mazeGrid) {
+	eller := NewEllerMaze(g.cols)
+	eller.rng = g.rng
+	for r := 0; r < g.rows; r++ {
+		rows := eller.NextRow(r == g.rows-1)
+		// 第0行没有上方的墙行
+		if r == 0 {
+			rows = rows[1:]
+		}
+		base := 2*r - len(rows) + 1
+		for i, row := range rows {
+			copy(g.maze[base+i], row)
+		}
+	}
+}
+
+// EllerMaze 按Eller算法逐行生成迷宫，高度不受限制
+// 每次调用NextRow返回两行：与上一行之间的墙行，以及新的格子行
+type EllerMaze struct {
+	cols    int
+	sets    []int  // 当前行每个格子所属的集合
+	down    []bool // 上一行中每个格子是否向下打通
+	nextSet int
+	first   bool
+	rng     *rand.Rand
+}
+
+func NewEllerMaze(cols int) *EllerMaze {
+	return &EllerMaze{
+		cols:  cols,
+		sets:  make([]int, cols),
+		down:  make([]bool, cols),
+		first: true,
+		rng:   newRand(),
+	}
+}
+
+// NextRow 生成下一行，last为true时生成最后一行（把所有集合合并）
+// 返回的每一行长度为 2*cols-1
+func (e *EllerMaze) NextRow(last bool) [][]int {
+	width := 2*e.cols - 1
+	wallRow := make([]int, width)
+	cellRow := make([]int, width)
+	for i := 0; i < width; i++ {
+		wallRow[i] = 1
+		cellRow[i] = 1
+	}
+
+	// 继承上一行向下打通的集合，其余格子分配新的集合
+	for c := 0; c < e.cols; c++ {
+		if e.first || !e.down[c] {
+			e.sets[c] = e.nextSet
+			e.nextSet++
+		} else {
+			wallRow[2*c] = 0
+		}
+		cellRow[2*c] = 0
+	}
+	e.first = false
+
+	// 随机合并相邻的不同集合，最后一行必须全部合并
+	for c := 0; c+1 < e.cols; c++ {
+		if e.sets[c] == e.sets[c+1] {
+			continue
+		}
+		if last || e.rng.Float64() < 0.5 {
+			cellRow[2*c+1] = 0
+			old := e.sets[c+1]
+			for k := range e.sets {
+				if e.sets[k] == old {
+					e.sets[k] = e.sets[c]
+				}
+			}
+		}
+	}
+
+	// 每个集合至少向下打通一个格子
+	if !last {
+		// 按集合第一次出现的顺序处理，map的遍历顺序是随机的，会让同一个种子生成不同的迷宫
+		members := make(map[int][]int)
+		var order []int
+		for c := 0; c < e.cols; c++ {
+			e.down[c] = false
+			if members[e.sets[c]] == nil {
+				order = append(order, e.sets[c])
+			}
+			members[e.sets[c]] = append(members[e.sets[c]], c)
+		}
+		for _, set := range order {
+			cs := members[set]
+			e.down[cs[e.rng.Intn(len(cs))]] = true
+			for _, c := range cs {
+				if e.rng.Float64() < 0.3 {
+					e.down[c] = true
+				}
+			}
+		}
+	}
+
+	return [][]int{wallRow, cellRow}
+}
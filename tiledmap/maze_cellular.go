@@ -0,0 +1,79 @@
+package tiledmap
+
+// 细胞自动机侵蚀的默认参数
+const (
+	DefaultErosionIterations = 2
+	DefaultErosionBirth      = 6 // 通路周围的墙数不少于该值时变成墙
+	DefaultErosionSurvive    = 4 // 墙周围的墙数不少于该值时保留，否则被侵蚀
+)
+
+// CellularErosionMaze 用细胞自动机代替按权重的随机侵蚀
+// 每次迭代统计每个格子周围8格中墙的数量（越界算墙），墙数少于survive的墙变成通路，
+// 墙数不少于birth的通路变成墙。path上的格子始终保持为通路，所以起点到终点一定连通；
+// 迭代结束后把和path不连通的通路填成墙，避免出现走不到的空洞。返回发生变化的格子数
+func CellularErosionMaze(maze [][]int, path [][]bool, iterations, birth, survive int) int {
+	rule := ThresholdRule(birth, survive, NeighborhoodMoore)
+	return CellularErosionMazeSchedule(maze, path, []CellularStep{{Rule: rule, Iterations: iterations}})
+}
+
+// CellularErosionMazeSchedule 按规则日程侵蚀，规则见 ParseCellularSchedule
+func CellularErosionMazeSchedule(maze [][]int, path [][]bool, schedule []CellularStep) int {
+	origin := make([][]int, len(maze))
+	for i := range maze {
+		origin[i] = append([]int(nil), maze[i]...)
+	}
+
+	RunCellularSchedule(maze, schedule, path)
+
+	fillUnreachable(maze, path)
+
+	changed := 0
+	for i := range maze {
+		for j := range maze[i] {
+			if maze[i][j] != origin[i][j] {
+				changed++
+			}
+		}
+	}
+	return changed
+}
+
+// 把和path不连通的通路填成墙
+func fillUnreachable(maze [][]int, path [][]bool) {
+	height, width := len(maze), len(maze[0])
+	reached := make([][]bool, height)
+	queue := make([]Point, 0)
+	for i := range reached {
+		reached[i] = make([]bool, width)
+		for j := range reached[i] {
+			if path[i][j] && maze[i][j] == 0 {
+				reached[i][j] = true
+				queue = append(queue, Point{i, j})
+			}
+		}
+	}
+	if len(queue) == 0 {
+		return
+	}
+
+	dirs := []Point{{0, 1}, {1, 0}, {0, -1}, {-1, 0}}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, d := range dirs {
+			nx, ny := cur.x+d.x, cur.y+d.y
+			if nx >= 0 && nx < height && ny >= 0 && ny < width && maze[nx][ny] == 0 && !reached[nx][ny] {
+				reached[nx][ny] = true
+				queue = append(queue, Point{nx, ny})
+			}
+		}
+	}
+
+	for i := range maze {
+		for j := range maze[i] {
+			if maze[i][j] == 0 && !reached[i][j] {
+				maze[i][j] = 1
+			}
+		}
+	}
+}
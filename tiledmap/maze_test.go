@@ -0,0 +1,63 @@
+package tiledmap
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// 堆积后只剩唯一路径的迷宫，侵蚀会打通捷径
+func accumulatedMaze(r *rand.Rand, size int) ([][]int, [2]int, [2]int) {
+	res := GenerateMazeDetailed(size, size, MazeOptions{Algorithm: MazeDFS, TurnProb: 0.4, Rand: r})
+	path := FindPathBetween(res.Maze, res.Start, res.End)
+	AccuMazeBetween(res.Maze, path, 0.7, res.Start, res.End)
+	return res.Maze, res.Start, res.End
+}
+
+// 侵蚀后起点到终点的最短路径缩短的比例不超过maxShorten，结果中的长度与重新BFS一致
+func TestErosionMazeBoundedShorten(t *testing.T) {
+	for seed := int64(0); seed < 40; seed++ {
+		for _, maxShorten := range []float64{0, 0.1, 0.3, 0.6} {
+			r := rand.New(rand.NewSource(seed))
+			maze, start, end := accumulatedMaze(r, 21+int(seed%3)*10)
+			before := mazeDistances(maze, start)[end[0]][end[1]]
+
+			res := ErosionMazeBoundedRand(r, maze, 0.6, maxShorten, start, end)
+			after := mazeDistances(maze, start)[end[0]][end[1]]
+			if res.OriginalLength != before+1 || res.FinalLength != after+1 {
+				t.Fatalf("seed %d shorten %.1f: lengths %d->%d, bfs %d->%d",
+					seed, maxShorten, res.OriginalLength, res.FinalLength, before+1, after+1)
+			}
+			if minSteps := int(math.Ceil(float64(before) * (1 - maxShorten))); after < minSteps {
+				t.Fatalf("seed %d shorten %.1f: %d steps after erosion, at least %d allowed (before %d)",
+					seed, maxShorten, after, minSteps, before)
+			}
+			if res.Eroded == 0 {
+				t.Fatalf("seed %d shorten %.1f: nothing eroded", seed, maxShorten)
+			}
+		}
+	}
+}
+
+// 同一个种子的结果相同；不限制缩短时与ErosionMazeRand完全一样
+func TestErosionMazeBoundedRandReproducible(t *testing.T) {
+	erode := func(seed int64, maxShorten float64) [][]int {
+		r := rand.New(rand.NewSource(seed))
+		maze, start, end := accumulatedMaze(r, 31)
+		ErosionMazeBoundedRand(r, maze, 0.5, maxShorten, start, end)
+		return maze
+	}
+	for seed := int64(0); seed < 10; seed++ {
+		if !reflect.DeepEqual(erode(seed, 0.2), erode(seed, 0.2)) {
+			t.Fatalf("seed %d: different mazes with the same seed", seed)
+		}
+
+		r := rand.New(rand.NewSource(seed))
+		maze, _, _ := accumulatedMaze(r, 31)
+		ErosionMazeRand(r, maze, 0.5)
+		if !reflect.DeepEqual(erode(seed, 1), maze) {
+			t.Fatalf("seed %d: unbounded erosion differs from ErosionMazeRand", seed)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package tiledmap
+
+import (
+	"sort"
+)
+
+// MapMetrics 地图质量指标，适用于所有0表示通路、非0表示障碍的网格
+type MapMetrics struct {
+	Width              int     `json:"width"`
+	Height             int     `json:"height"`
+	FloorRatio         float64 `json:"floorRatio"`         // 通路格子占比
+	RegionCount        int     `json:"regionCount"`        // 4连通区域数
+	RegionSizes        []int   `json:"regionSizes"`        // 各区域格子数，从大到小
+	LargestRegionRatio float64 `json:"largestRegionRatio"` // 最大区域占全部通路的比例
+	DeadEnds           int     `json:"deadEnds"`           // 死胡同格子数（只有一个通路邻居，起点终点除外）
+	AvgCorridorWidth   float64 `json:"avgCorridorWidth"`   // 平均通道宽度
+	PathLength         int     `json:"pathLength"`         // 起点到终点最短路径的格子数，不连通时为0
+	Tortuosity         float64 `json:"tortuosity"`         // 曲折度：最短路径步数 / 曼哈顿距离
+	ArticulationPoints int     `json:"articulationPoints"` // 割点数
+}
+
+// AnalyzeMap 计算地图的质量指标，start和end为（行，列）
+func AnalyzeMap(grid [][]int, start, end [2]int) MapMetrics {
+	m := MapMetrics{RegionSizes: []int{}}
+	if len(grid) == 0 || len(grid[0]) == 0 {
+		return m
+	}
+	height, width := len(grid), len(grid[0])
+	m.Width, m.Height = width, height
+
+	// 统一转换成0/1，地牢等地图里障碍可能有其他取值
+	walls := make([][]int, height)
+	floors := 0
+	for i := range grid {
+		walls[i] = make([]int, width)
+		for j, v := range grid[i] {
+			if v != 0 {
+				walls[i][j] = 1
+			} else {
+				floors++
+			}
+		}
+	}
+	if floors == 0 {
+		return m
+	}
+	m.FloorRatio = float64(floors) / float64(width*height)
+
+	m.RegionSizes = regionSizes(walls)
+	m.RegionCount = len(m.RegionSizes)
+	m.LargestRegionRatio = float64(m.RegionSizes[0]) / float64(floors)
+
+	m.DeadEnds = CountDeadEnds(walls, start, end)
+	m.AvgCorridorWidth = averageCorridorWidth(walls)
+	m.ArticulationPoints = len(articulationPoints(walls))
+
+	if inGrid(walls, start) && inGrid(walls, end) && walls[start[0]][start[1]] == 0 {
+		if steps := mazeDistances(walls, start)[end[0]][end[1]]; steps >= 0 {
+			m.PathLength = steps + 1
+			if manhattan := abs(end[0]-start[0]) + abs(end[1]-start[1]); manhattan > 0 {
+				m.Tortuosity = float64(steps) / float64(manhattan)
+			}
+		}
+	}
+	return m
+}
+
+// CountDeadEnds 统计死胡同格子（只有一个通路邻居）的数量，start和end不算
+// 与countDeadEnds（统计不在唯一路径上的格子）不同，这里不需要事先求出路径，适用于任何地图
+func CountDeadEnds(grid [][]int, start, end [2]int) int {
+	ends := [2]Point{{start[0], start[1]}, {end[0], end[1]}}
+	count := 0
+	for i := range grid {
+		for j := range grid[i] {
+			if isDeadEnd(Point{i, j}, grid, ends) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func inGrid(grid [][]int, pos [2]int) bool {
+	return pos[0] >= 0 && pos[0] < len(grid) && pos[1] >= 0 && pos[1] < len(grid[0])
+}
+
+// 所有4连通区域的大小，从大到小排序
+func regionSizes(walls [][]int) []int {
+	height, width := len(walls), len(walls[0])
+	visited := make([]bool, height*width)
+	sizes := make([]int, 0)
+	queue := make([]Point, 0)
+	for i := 0; i < height; i++ {
+		for j := 0; j < width; j++ {
+			if walls[i][j] != 0 || visited[i*width+j] {
+				continue
+			}
+			visited[i*width+j] = true
+			queue = append(queue[:0], Point{i, j})
+			for head := 0; head < len(queue); head++ {
+				cur := queue[head]
+				for _, d := range []Point{{-1, 0}, {0, 1}, {1, 0}, {0, -1}} {
+					nx, ny := cur.x+d.x, cur.y+d.y
+					if nx >= 0 && nx < height && ny >= 0 && ny < width && walls[nx][ny] == 0 && !visited[nx*width+ny] {
+						visited[nx*width+ny] = true
+						queue = append(queue, Point{nx, ny})
+					}
+				}
+			}
+			sizes = append(sizes, len(queue))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+	return sizes
+}
+
+// 每个通路格子的宽度取经过它的水平和竖直连续通路长度中较小的一个，再对所有通路格子取平均
+func averageCorridorWidth(walls [][]int) float64 {
+	height, width := len(walls), len(walls[0])
+	horizontal := make([][]int, height)
+	for i := range horizontal {
+		horizontal[i] = make([]int, width)
+		for j := 0; j < width; {
+			if walls[i][j] != 0 {
+				j++
+				continue
+			}
+			k := j
+			for k < width && walls[i][k] == 0 {
+				k++
+			}
+			for x := j; x < k; x++ {
+				horizontal[i][x] = k - j
+			}
+			j = k
+		}
+	}
+
+	total, count := 0, 0
+	for j := 0; j < width; j++ {
+		for i := 0; i < height; {
+			if walls[i][j] != 0 {
+				i++
+				continue
+			}
+			k := i
+			for k < height && walls[k][j] == 0 {
+				k++
+			}
+			for x := i; x < k; x++ {
+				total += min(k-i, horizontal[x][j])
+				count++
+			}
+			i = k
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return float64(total) / float64(count)
+}
@@ -0,0 +1,135 @@
+package tiledmap
+
+import (
+	"math/rand"
+)
+
+// Stair 连接相邻两层的楼梯：第Floor层的下楼梯和第Floor+1层的上楼梯位于同一坐标
+type Stair struct {
+	X, Y  int // 楼梯坐标
+	Floor int // 下楼梯所在的楼层
+}
+
+// MultiFloorDungeon 多层地牢，每一层都是一个完整的Dungeon
+type MultiFloorDungeon struct {
+	Width  int
+	Height int
+	Floors []*Dungeon
+	Stairs []Stair // Stairs[i]连接第i层和第i+1层
+}
+
+// UpStair 返回第floor层的上楼梯，第0层没有上楼梯
+func (m *MultiFloorDungeon) UpStair(floor int) (Stair, bool) {
+	if floor <= 0 || floor-1 >= len(m.Stairs) {
+		return Stair{}, false
+	}
+	return m.Stairs[floor-1], true
+}
+
+// DownStair 返回第floor层的下楼梯，最后一层没有下楼梯
+func (m *MultiFloorDungeon) DownStair(floor int) (Stair, bool) {
+	if floor < 0 || floor >= len(m.Stairs) {
+		return Stair{}, false
+	}
+	return m.Stairs[floor], true
+}
+
+// GenerateMultiFloorDungeon 生成多层地牢
+// 每一层的下楼梯都放在房间里，下一层会在同样的位置先放入同样的房间作为上楼梯所在的房间，
+// 下楼梯从与上楼梯连通的格子中选取（尽量选离上楼梯最远的房间），保证每一层的上下楼梯是连通的
+func GenerateMultiFloorDungeon(floorCount, width, height, roomCount, minSize, maxSize int, extraPathProb float32, opts CorridorOptions) *MultiFloorDungeon {
+	return GenerateMultiFloorDungeonRand(newRand(), floorCount, width, height, roomCount, minSize, maxSize, extraPathProb, opts)
+}
+
+// GenerateMultiFloorDungeonRand 和GenerateMultiFloorDungeon相同，所有楼层以及楼梯的选择都使用r作为随机源
+func GenerateMultiFloorDungeonRand(r *rand.Rand, floorCount, width, height, roomCount, minSize, maxSize int, extraPathProb float32, opts CorridorOptions) *MultiFloorDungeon {
+	if floorCount < 1 {
+		floorCount = 1
+	}
+
+	m := &MultiFloorDungeon{}
+
+	var fixedRooms []Room
+	for floor := 0; floor < floorCount; floor++ {
+		d := generateDungeon(r, width, height, roomCount, minSize, maxSize, opts, fixedRooms)
+		buildDungeon(d, extraPathProb)
+		m.Floors = append(m.Floors, d)
+		m.Width, m.Height = d.Width, d.Height
+
+		if floor == floorCount-1 || len(d.Rooms) == 0 {
+			break
+		}
+
+		// 从上楼梯出发选择下楼梯，第0层没有上楼梯，随机选一个房间作为起点
+		sx, sy := roomCenter(d.Rooms[r.Intn(len(d.Rooms))])
+		if up, ok := m.UpStair(floor); ok {
+			sx, sy = up.X, up.Y
+		}
+		room, x, y := farthestRoomTile(d, sx, sy)
+		m.Stairs = append(m.Stairs, Stair{X: x, Y: y, Floor: floor})
+		fixedRooms = []Room{room}
+	}
+
+	return m
+}
+
+// 依次执行地牢生成的各个阶段
+func buildDungeon(d *Dungeon, extraPathProb float32) {
+	d.GenerateMazeBetweenRooms()
+	d.ConnectPassagesByDFS()
+	d.ConnectAllRegions(extraPathProb)
+	d.FillDeadEnds()
+}
+
+func roomCenter(room Room) (int, int) {
+	return room.X + room.Width/2, room.Y + room.Height/2
+}
+
+// 在与(sx,sy)连通的房间中，选出离它最远的房间，并返回该房间内离它最远的格子
+func farthestRoomTile(d *Dungeon, sx, sy int) (Room, int, int) {
+	dist := floorDistances(d.Tiles, sx, sy)
+
+	bestRoom, bestX, bestY, bestDist := d.Rooms[0], sx, sy, -1
+	for _, room := range d.Rooms {
+		for y := room.Y; y < room.Y+room.Height; y++ {
+			for x := room.X; x < room.X+room.Width; x++ {
+				if dist[y][x] > bestDist {
+					bestRoom, bestX, bestY, bestDist = room, x, y, dist[y][x]
+				}
+			}
+		}
+	}
+	return bestRoom, bestX, bestY
+}
+
+// BFS计算从(sx,sy)出发到每个通道格子的步数，不可达为-1
+func floorDistances(tiles [][]int, sx, sy int) [][]int {
+	height, width := len(tiles), len(tiles[0])
+	dist := make([][]int, height)
+	for i := range dist {
+		dist[i] = make([]int, width)
+		for j := range dist[i] {
+			dist[i][j] = -1
+		}
+	}
+	if tiles[sy][sx] != 0 {
+		return dist
+	}
+
+	dirs := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	queue := []Cell{{x: sx, y: sy}}
+	dist[sy][sx] = 0
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, dir := range dirs {
+			nx, ny := cur.x+dir[0], cur.y+dir[1]
+			if nx >= 0 && nx < width && ny >= 0 && ny < height &&
+				tiles[ny][nx] == 0 && dist[ny][nx] < 0 {
+				dist[ny][nx] = dist[cur.y][cur.x] + 1
+				queue = append(queue, Cell{x: nx, y: ny})
+			}
+		}
+	}
+	return dist
+}
@@ -0,0 +1,74 @@
+package tiledmap
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// 每一层的上楼梯与上一层的下楼梯位置相同，楼梯都在通道上，每一层的所有通道格子都是连通的
+func TestMultiFloorDungeonStairsAndConnectivity(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		opts := DefaultCorridorOptions()
+		opts.Width = 1 + int(seed%2)
+		m := GenerateMultiFloorDungeonRand(rand.New(rand.NewSource(seed)), 4, 41, 41, 8, 5, 9, 0.1, opts)
+		if len(m.Stairs) != len(m.Floors)-1 {
+			t.Fatalf("seed %d: %d floors, %d stairs", seed, len(m.Floors), len(m.Stairs))
+		}
+		for floor, d := range m.Floors {
+			if up, ok := m.UpStair(floor); ok {
+				down, _ := m.DownStair(floor - 1)
+				if up != down || up.Floor != floor-1 {
+					t.Fatalf("seed %d floor %d: up stair %+v, previous floor's down stair %+v", seed, floor, up, down)
+				}
+			}
+
+			sx, sy, open := -1, -1, 0
+			for y := range d.Tiles {
+				for x := range d.Tiles[y] {
+					if d.Tiles[y][x] == 0 {
+						sx, sy = x, y
+						open++
+					}
+				}
+			}
+			for _, stairOf := range []func(int) (Stair, bool){m.UpStair, m.DownStair} {
+				if s, ok := stairOf(floor); ok {
+					if d.Tiles[s.Y][s.X] != 0 {
+						t.Fatalf("seed %d floor %d: stair %+v on a wall", seed, floor, s)
+					}
+					sx, sy = s.X, s.Y
+				}
+			}
+			if open == 0 {
+				t.Fatalf("seed %d floor %d: no passable tiles", seed, floor)
+			}
+			reached := 0
+			for _, row := range floorDistances(d.Tiles, sx, sy) {
+				for _, dist := range row {
+					if dist >= 0 {
+						reached++
+					}
+				}
+			}
+			if reached != open {
+				t.Fatalf("seed %d floor %d: %d of %d passable tiles reachable", seed, floor, reached, open)
+			}
+		}
+	}
+}
+
+func TestMultiFloorDungeonReproducible(t *testing.T) {
+	gen := func() *MultiFloorDungeon {
+		return GenerateMultiFloorDungeonRand(rand.New(rand.NewSource(27)), 3, 31, 31, 6, 5, 9, 0.1, DefaultCorridorOptions())
+	}
+	a, b := gen(), gen()
+	if !reflect.DeepEqual(a.Stairs, b.Stairs) {
+		t.Fatalf("stairs differ with the same seed: %v vs %v", a.Stairs, b.Stairs)
+	}
+	for i := range a.Floors {
+		if !reflect.DeepEqual(a.Floors[i].Tiles, b.Floors[i].Tiles) {
+			t.Fatalf("floor %d differs with the same seed", i)
+		}
+	}
+}
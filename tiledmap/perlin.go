@@ -10,7 +10,9 @@ type PerlinNoise struct {
 	gradients   [][2]float64
 }
 
+// NewPerlinNoise 置换表和梯度都由seed决定，同一个种子的噪声相同
 func NewPerlinNoise(seed int64) *PerlinNoise {
+	r := rand.New(rand.NewSource(seed))
 	p := &PerlinNoise{
 		permutation: make([]int, 256),
 		gradients:   make([][2]float64, 256),
@@ -22,13 +24,13 @@ func NewPerlinNoise(seed int64) *PerlinNoise {
 	}
 	// Fisher-Yates 洗牌算法
 	for i := 255; i > 0; i-- {
-		j := rand.Intn(i + 1)
+		j := r.Intn(i + 1)
 		p.permutation[i], p.permutation[j] = p.permutation[j], p.permutation[i]
 	}
 
 	// 生成随机梯度向量
 	for i := 0; i < 256; i++ {
-		angle := rand.Float64() * 2 * math.Pi
+		angle := r.Float64() * 2 * math.Pi
 		p.gradients[i] = [2]float64{
 			math.Cos(angle),
 			math.Sin(angle),
@@ -0,0 +1,273 @@
+package tiledmap
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// SweepKind 参数扫描的地图类型
+type SweepKind string
+
+const (
+	SweepMaze     SweepKind = "maze"     // 迷宫：turn、acc、erosion、shorten
+	SweepCellular SweepKind = "cellular" // 细胞自动机：probability、iterations
+)
+
+// SweepParamNames 每种地图可以扫描的参数，顺序即CSV中的列顺序
+var SweepParamNames = map[SweepKind][]string{
+	SweepMaze:     {"turn", "acc", "erosion", "shorten"},
+	SweepCellular: {"probability", "iterations"},
+}
+
+// SweepDefaults 没有指定取值的参数使用的默认值
+var SweepDefaults = map[string]float64{
+	"turn":        0.4,
+	"acc":         0.7,
+	"erosion":     0.5,
+	"shorten":     1, // 侵蚀时允许最短路径缩短的最大比例，1为不限制
+	"probability": DefaultProbability,
+	"iterations":  DefaultIterations,
+}
+
+// SweepAxis 一个参数的所有取值
+type SweepAxis struct {
+	Name   string
+	Values []float64
+}
+
+// SweepConfig 参数扫描的配置，所有轴的取值做笛卡尔积，每个组合生成Samples张地图
+type SweepConfig struct {
+	Kind    SweepKind
+	Size    int
+	Samples int
+	Axes    []SweepAxis
+	Workers int   // 并行数，<=0时为CPU数
+	Seed    int64 // 基础种子，第c个组合的第s个样本使用Seed+c*Samples+s；0时随机选择
+}
+
+// SweepSample 一张地图的指标
+type SweepSample struct {
+	Sample  int
+	Seed    int64 // 生成这张地图的种子，用SweepMap可以重新生成
+	Metrics MapMetrics
+}
+
+// SweepCombo 一个参数组合的所有样本
+type SweepCombo struct {
+	Params  map[string]float64
+	Samples []SweepSample
+	Mean    MapMetrics // 各项指标的平均值（RegionSizes为空）
+	Grid    [][]int    // 代表性的地图：通路占比最接近平均值的样本
+}
+
+// SweepResult 参数扫描的结果
+type SweepResult struct {
+	Config SweepConfig // Seed为实际使用的基础种子
+	Combos []SweepCombo
+}
+
+// RunSweep 并行生成所有参数组合的样本并计算质量指标
+// 每个样本使用自己的种子，结果与并行数和执行顺序无关，同一个Seed可以复现整个扫描
+func RunSweep(cfg SweepConfig) SweepResult {
+	if cfg.Samples < 1 {
+		cfg.Samples = 1
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = rand.Int63n(1<<31) + 1
+	}
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	combos := make([]SweepCombo, 0)
+	for _, params := range sweepCombinations(cfg) {
+		combos = append(combos, SweepCombo{Params: params, Samples: make([]SweepSample, cfg.Samples)})
+	}
+	grids := make([][][][]int, len(combos))
+	for i := range grids {
+		grids[i] = make([][][]int, cfg.Samples)
+	}
+
+	type job struct{ combo, sample int }
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				seed := cfg.Seed + int64(j.combo*cfg.Samples+j.sample)
+				grid, start, end := SweepMap(cfg.Kind, cfg.Size, combos[j.combo].Params, seed)
+				combos[j.combo].Samples[j.sample] = SweepSample{Sample: j.sample, Seed: seed, Metrics: AnalyzeMap(grid, start, end)}
+				grids[j.combo][j.sample] = grid
+			}
+		}()
+	}
+	for c := range combos {
+		for s := 0; s < cfg.Samples; s++ {
+			jobs <- job{c, s}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for c := range combos {
+		combos[c].Mean = meanMetrics(combos[c].Samples)
+		best := 0
+		for s, sample := range combos[c].Samples {
+			if math.Abs(sample.Metrics.FloorRatio-combos[c].Mean.FloorRatio) <
+				math.Abs(combos[c].Samples[best].Metrics.FloorRatio-combos[c].Mean.FloorRatio) {
+				best = s
+			}
+		}
+		combos[c].Grid = grids[c][best]
+	}
+
+	return SweepResult{Config: cfg, Combos: combos}
+}
+
+// 所有参数组合，没有出现在Axes中的参数使用默认值
+func sweepCombinations(cfg SweepConfig) []map[string]float64 {
+	base := make(map[string]float64)
+	for _, name := range SweepParamNames[cfg.Kind] {
+		base[name] = SweepDefaults[name]
+	}
+
+	combos := []map[string]float64{base}
+	for _, axis := range cfg.Axes {
+		if len(axis.Values) == 0 {
+			continue
+		}
+		next := make([]map[string]float64, 0, len(combos)*len(axis.Values))
+		for _, combo := range combos {
+			for _, v := range axis.Values {
+				params := make(map[string]float64, len(combo))
+				for k, old := range combo {
+					params[k] = old
+				}
+				params[axis.Name] = v
+				next = append(next, params)
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// SweepMap 按参数和种子生成一张地图，返回地图以及计算路径指标的起终点
+// 所有随机数都从种子新建的随机源取，与约束生成走同样的路径
+func SweepMap(kind SweepKind, size int, params map[string]float64, seed int64) ([][]int, [2]int, [2]int) {
+	r := rand.New(rand.NewSource(seed))
+	switch kind {
+	case SweepCellular:
+		maze := InitializeMazeRand(r, size, UniformDensity(params["probability"]))
+		for i := 0; i < int(params["iterations"]); i++ {
+			CellularMaze(maze)
+		}
+		ConnectRegionsByBFS(maze)
+		start, end := MazeCorners(maze)
+		return maze, start, end
+	default:
+		res := GenerateMazeDetailed(size, size, MazeOptions{Algorithm: MazeDFS, TurnProb: params["turn"], Rand: r})
+		path := FindPathBetween(res.Maze, res.Start, res.End)
+		AccuMazeBetween(res.Maze, path, params["acc"], res.Start, res.End)
+		ErosionMazeBoundedRand(r, res.Maze, params["erosion"], params["shorten"], res.Start, res.End)
+		return res.Maze, res.Start, res.End
+	}
+}
+
+func meanMetrics(samples []SweepSample) MapMetrics {
+	var m MapMetrics
+	if len(samples) == 0 {
+		return m
+	}
+	var regions, deadEnds, path, points float64
+	for _, s := range samples {
+		m.Width, m.Height = s.Metrics.Width, s.Metrics.Height
+		m.FloorRatio += s.Metrics.FloorRatio
+		m.LargestRegionRatio += s.Metrics.LargestRegionRatio
+		m.AvgCorridorWidth += s.Metrics.AvgCorridorWidth
+		m.Tortuosity += s.Metrics.Tortuosity
+		regions += float64(s.Metrics.RegionCount)
+		deadEnds += float64(s.Metrics.DeadEnds)
+		path += float64(s.Metrics.PathLength)
+		points += float64(s.Metrics.ArticulationPoints)
+	}
+	n := float64(len(samples))
+	m.FloorRatio /= n
+	m.LargestRegionRatio /= n
+	m.AvgCorridorWidth /= n
+	m.Tortuosity /= n
+	m.RegionCount = int(math.Round(regions / n))
+	m.DeadEnds = int(math.Round(deadEnds / n))
+	m.PathLength = int(math.Round(path / n))
+	m.ArticulationPoints = int(math.Round(points / n))
+	return m
+}
+
+// SweepMetricNames CSV中指标列的名字
+var SweepMetricNames = []string{
+	"floorRatio", "regionCount", "largestRegionRatio", "deadEnds",
+	"avgCorridorWidth", "pathLength", "tortuosity", "articulationPoints",
+}
+
+// SweepMetricValue 按名字取出指标的值
+func SweepMetricValue(m MapMetrics, name string) float64 {
+	switch name {
+	case "floorRatio":
+		return m.FloorRatio
+	case "regionCount":
+		return float64(m.RegionCount)
+	case "largestRegionRatio":
+		return m.LargestRegionRatio
+	case "deadEnds":
+		return float64(m.DeadEnds)
+	case "avgCorridorWidth":
+		return m.AvgCorridorWidth
+	case "pathLength":
+		return float64(m.PathLength)
+	case "tortuosity":
+		return m.Tortuosity
+	case "articulationPoints":
+		return float64(m.ArticulationPoints)
+	}
+	return 0
+}
+
+// WriteCSV 每个样本一行：参数、样本编号、种子、各项指标
+func (r SweepResult) WriteCSV(w io.Writer) error {
+	names := SweepParamNames[r.Config.Kind]
+	writer := csv.NewWriter(w)
+
+	header := append([]string{"kind"}, names...)
+	header = append(header, "sample", "seed", "width", "height")
+	header = append(header, SweepMetricNames...)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, combo := range r.Combos {
+		for _, s := range combo.Samples {
+			row := []string{string(r.Config.Kind)}
+			for _, name := range names {
+				row = append(row, strconv.FormatFloat(combo.Params[name], 'f', -1, 64))
+			}
+			row = append(row, strconv.Itoa(s.Sample), strconv.FormatInt(s.Seed, 10),
+				strconv.Itoa(s.Metrics.Width), strconv.Itoa(s.Metrics.Height))
+			for _, metric := range SweepMetricNames {
+				row = append(row, strconv.FormatFloat(math.Round(SweepMetricValue(s.Metrics, metric)*1e4)/1e4, 'f', -1, 64))
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
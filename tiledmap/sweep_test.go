@@ -0,0 +1,51 @@
+package tiledmap
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// 同一个种子的扫描结果与并行数无关，每个样本都可以按CSV中的种子单独重新生成
+func TestRunSweepSeeded(t *testing.T) {
+	for _, kind := range []SweepKind{SweepMaze, SweepCellular} {
+		cfg := SweepConfig{Kind: kind, Size: 21, Samples: 3, Seed: 100}
+		name := SweepParamNames[kind][0]
+		cfg.Axes = []SweepAxis{{Name: name, Values: []float64{0.3, 0.5}}}
+
+		cfg.Workers = 1
+		serial := RunSweep(cfg)
+		cfg.Workers = 8
+		parallel := RunSweep(cfg)
+		if !reflect.DeepEqual(serial.Combos, parallel.Combos) {
+			t.Fatalf("%s: results depend on the number of workers", kind)
+		}
+
+		for c, combo := range parallel.Combos {
+			for s, sample := range combo.Samples {
+				if want := cfg.Seed + int64(c*cfg.Samples+s); sample.Seed != want {
+					t.Fatalf("%s: combo %d sample %d seed %d, want %d", kind, c, s, sample.Seed, want)
+				}
+				grid, start, end := SweepMap(kind, cfg.Size, combo.Params, sample.Seed)
+				if metrics := AnalyzeMap(grid, start, end); !reflect.DeepEqual(metrics, sample.Metrics) {
+					t.Fatalf("%s: combo %d sample %d not reproducible from seed %d", kind, c, s, sample.Seed)
+				}
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := parallel.WriteCSV(&buf); err != nil {
+			t.Fatal(err)
+		}
+		rows, err := csv.NewReader(&buf).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		col := len(SweepParamNames[kind]) + 2
+		if rows[0][col] != "seed" || rows[1][col] != strconv.FormatInt(cfg.Seed, 10) {
+			t.Fatalf("%s: csv seed column: header %q, first row %q", kind, rows[0][col], rows[1][col])
+		}
+	}
+}
@@ -3,26 +3,134 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"html"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"mazemap/pathfind"
 	"mazemap/tiledmap"
 )
 
+// astarPage 寻路演示页面的参数
+type astarPage struct {
+	width, height                    int
+	turnProb, accRatio, erosionRatio float64
+	opts                             tiledmap.MazeOptions
+	start, end                       [2]int
+	algos                            []pathfind.Algorithm
+	closest, seal, smooth            bool
+	flow                             bool
+	goals                            [][2]int
+	agentSize                        int
+	clearance                        bool
+	landmarks                        int
+	strategy                         pathfind.LandmarkStrategy
+	compareALT                       bool
+}
+
+func parseAstarPage(req *http.Request) astarPage {
+	size, turnProb, accRatio, erosionRatio := parseMazeParams(req)
+	p := astarPage{
+		turnProb:     turnProb,
+		accRatio:     accRatio,
+		erosionRatio: erosionRatio,
+		opts:         parseMazeOptions(req, turnProb),
+		algos:        parseAlgorithms(req),
+		closest:      req.URL.Query().Get("closest") == "true",
+		seal:         req.URL.Query().Get("seal") == "true",
+		smooth:       req.URL.Query().Get("smooth") == "true",
+		flow:         req.URL.Query().Get("flow") == "true",
+		goals:        parseMazePosList(req.URL.Query().Get("goals")),
+		agentSize:    parseAgentSize(req),
+		clearance:    req.URL.Query().Get("clearance") == "true",
+		compareALT:   req.URL.Query().Get("alt") == "true",
+	}
+	p.width, p.height = parseMazeShape(req, size)
+	p.start, p.end = tiledmap.MazeEnds(p.width, p.height, p.opts)
+	p.landmarks, p.strategy = parseLandmarkParams(req)
+	return p
+}
+
+// 每个选中的寻路算法各一张地图，之后是间隙图、流场和地标对比；迷宫生成之后不再修改
+func (p astarPage) maps() []mapMetricsEntry {
+	var maps mapEntries
+	start, end := p.start, p.end
+
+	// 生成迷宫和寻找路径
+	maze := tiledmap.GenerateRectMaze(p.width, p.height, p.opts)
+	path := tiledmap.FindPathBetween(maze, start, end)
+
+	tiledmap.AccuMazeBetween(maze, path, p.accRatio, start, end)
+	tiledmap.ErosionMaze(maze, p.erosionRatio)
+
+	// 用墙围住出口，演示终点不可达的情况
+	if p.seal {
+		for _, dir := range [][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}} {
+			x, y := end[0]+dir[0], end[1]+dir[1]
+			if x >= 0 && x < len(maze) && y >= 0 && y < len(maze[0]) {
+				maze[x][y] = 1
+			}
+		}
+	}
+
+	// 依次使用选中的寻路算法
+	for _, algo := range p.algos {
+		finder := algo.New()
+		if alt, ok := finder.(*pathfind.ALT); ok {
+			alt.Count, alt.Strategy = p.landmarks, p.strategy
+		}
+		pathFindRes := pathfind.FindPathWithOptions(finder, maze, start, end,
+			pathfind.PathOptions{Closest: p.closest, Smooth: p.smooth, AgentSize: p.agentSize})
+		addPathResult(&maps, maze, pathFindRes, algo.Title+"寻路结果", start, end, p.agentSize) // 带路径的迷宫
+	}
+
+	// 每个格子的间隙值：以它为左上角全部可以通行的最大正方形的边长
+	if p.clearance {
+		addClearance(&maps, maze, start, end, p.agentSize)
+	}
+
+	// 以出口和其他目标为集结点的流场，路径是从入口沿方向场走出来的
+	if p.flow {
+		addFlowField(&maps, maze, append([][2]int{end}, p.goals...), start, end)
+	}
+
+	// 不同选取方式的地标启发函数和曼哈顿距离的扩展格子数对比
+	if p.compareALT {
+		addALTComparison(&maps, pathfind.ClearanceMaze(maze, p.agentSize), start, end, p.landmarks, p.strategy)
+	}
+	return maps
+}
+
 func astarHandler(w http.ResponseWriter, req *http.Request) {
 	printHtmlHead(w, "迷宫寻路演示", true)
 
-	size, turnProb, accRatio, erosionRatio := parseMazeParams(req)
+	p := parseAstarPage(req)
 
 	// 控制表单
 	fmt.Fprintf(w, `
 <div class="all-container">
 	<div class="all-controls">
 		<form>
-			尺寸: <input type="number" name="size" value="%d" min="5" max="99" step="2">
+			宽度: <input type="number" name="width" value="%d" min="2" max="99">
+			高度: <input type="number" name="height" value="%d" min="2" max="99">
+			入口: <input type="text" name="start" value="%s" size="5">
+			出口: <input type="text" name="end" value="%s" size="5">
 			转弯概率: <input type="number" name="turn" value="%0.1f" step="0.1" min="0" max="1">
 			堆积系数: <input type="number" name="acc" value="%0.1f" step="0.1" min="0" max="1">
 			侵蚀系数: <input type="number" name="erosion" value="%0.1f" step="0.1" min="0" max="1">
+			%s
+			<label><input type="checkbox" name="seal" value="true" %s> 封闭出口</label>
+			<label><input type="checkbox" name="closest" value="true" %s> 不可达时走到最近处</label>
+			<label><input type="checkbox" name="smooth" value="true" %s> 路径平滑</label>
+			<label><input type="checkbox" name="flow" value="true" %s> 流场</label>
+			其他目标: <input type="text" name="goals" value="%s" size="12" placeholder="行,列;行,列">
+			体型: <input type="number" name="agentSize" value="%d" min="1" max="5">
+			<label><input type="checkbox" name="clearance" value="true" %s> 间隙图</label>
+			地标: <input type="number" name="landmarks" value="%d" min="1" max="16">
+			<select name="strategy">%s</select>
+			<label><input type="checkbox" name="alt" value="true" %s> 地标对比</label>
 			<input type="submit" value="生成">
 		</form>
 	</div>
@@ -34,51 +142,89 @@ func astarHandler(w http.ResponseWriter, req *http.Request) {
 		<button onclick="stepPlayback()" id="playback-btn">Step</button>
 	</div>
 	<div style="display: flex; gap: 20px; justify-content: center;">`,
-		size, turnProb, accRatio, erosionRatio)
-	// 生成迷宫和寻找路径
-	maze := tiledmap.GenerateMaze(size, turnProb)
-	path := tiledmap.FindPath(maze)
-
-	// 第一个画布：原始迷宫
-	//renderMazePathWithTitle(w, maze, path, "原始迷宫")
-
-	tiledmap.AccuMaze(maze, path, accRatio)
-	tiledmap.ErosionMaze(maze, erosionRatio)
-
-	//renderMazeWithTitle(w, maze, "堆积侵蚀后") // 第三个画布：侵蚀后
+		p.width, p.height, formatMazePos(p.start), formatMazePos(p.end),
+		p.turnProb, p.accRatio, p.erosionRatio, algorithmCheckboxes(p.algos), checkedAttr(p.seal), checkedAttr(p.closest), checkedAttr(p.smooth),
+		checkedAttr(p.flow), html.EscapeString(formatMazePosList(p.goals)), p.agentSize, checkedAttr(p.clearance),
+		p.landmarks, landmarkStrategyOptions(p.strategy), checkedAttr(p.compareALT))
+	renderMapEntries(w, p.maps())
 
-	start := [2]int{0, 0}
-	end := [2]int{size - 1, size - 1}
-
-	// 使用 A* 寻路
-	pathFindRes := pathfind.FindPathAStar(maze, start, end)
-	renderPathWithTitle(w, maze, pathFindRes, "A*寻路结果") // 渲染带路径的迷宫
+	fmt.Fprint(w, "\n</div></div></body></html>")
+}
 
-	// 使用 dijkstra 寻路
-	pathFindRes = pathfind.FindPathDijkstra(maze, start, end)
-	renderPathWithTitle(w, maze, pathFindRes, "Dijkstra寻路结果") // 渲染带路径的迷宫
+// 解析要演示的寻路算法，没有指定时使用所有注册的算法
+func parseAlgorithms(req *http.Request) []pathfind.Algorithm {
+	algos := make([]pathfind.Algorithm, 0)
+	for _, name := range req.URL.Query()["algo"] {
+		if algo, ok := pathfind.Lookup(name); ok {
+			algos = append(algos, algo)
+		}
+	}
+	if len(algos) == 0 {
+		return pathfind.Algorithms()
+	}
+	return algos
+}
 
-	// 使用 bestfirst 寻路
-	pathFindRes = pathfind.FindPathBestFirst(maze, start, end)
-	renderPathWithTitle(w, maze, pathFindRes, "BestFirst寻路结果") // 渲染带路径的迷宫
+func algorithmCheckboxes(selected []pathfind.Algorithm) string {
+	checked := make(map[string]bool)
+	for _, algo := range selected {
+		checked[algo.Name] = true
+	}
+	str := ""
+	for _, algo := range pathfind.Algorithms() {
+		attr := ""
+		if checked[algo.Name] {
+			attr = " checked"
+		}
+		str += fmt.Sprintf(`<label><input type="checkbox" name="algo" value="%s"%s> %s</label>`, algo.Name, attr, algo.Title)
+	}
+	return str
+}
 
-	// 使用 jps 寻路
-	pathFindRes = pathfind.FindPathJPS(maze, start, end)
-	renderPathWithTitle(w, maze, pathFindRes, "JPS寻路结果") // 渲染带路径的迷宫
+func checkedAttr(checked bool) string {
+	if checked {
+		return "checked"
+	}
+	return ""
+}
 
-	// 使用 JPS+ 寻路
-	preprocessedMaze := pathfind.PreprocessMaze(maze)          // 预处理迷宫
-	pathFindRes = preprocessedMaze.FindPathJPSPlus(start, end) // 调用 JPS+ 寻路
-	renderPathWithTitle(w, maze, pathFindRes, "JPS+寻路结果")
+// 寻路结果的状态说明，找到路径时为空
+func pathStatus(found, partial bool, err error) string {
+	switch {
+	case err != nil:
+		return "，参数错误: " + html.EscapeString(err.Error())
+	case found:
+		return ""
+	case partial:
+		return "，终点不可达，显示到最近可达格子的路径"
+	}
+	return "，终点不可达"
+}
 
-	fmt.Fprint(w, "\n</div></div></body></html>")
+func renderPathWithTitle(w http.ResponseWriter, maze [][]int, res pathfind.PathFindResult, title string, start, end [2]int) {
+	path, info, overlay := pathResultView(maze, res, 1)
+	renderStepsData(w, title, res.StepRecord)
+	renderMazePathWithOverlay(w, maze, path, title, info, start, end, overlay)
 }
 
-func renderPathWithTitle(w http.ResponseWriter, maze [][]int, res pathfind.PathFindResult, title string) {
+// 带路径的迷宫，页面上还有供pathfind.js播放的步骤数据
+func addPathResult(maps *mapEntries, maze [][]int, res pathfind.PathFindResult, title string, start, end [2]int, agentSize int) {
+	path, info, overlay := pathResultView(maze, res, agentSize)
+	maps.add(title, maze, start, end, func(w http.ResponseWriter, m tiledmap.MapMetrics) {
+		renderStepsData(w, title, res.StepRecord)
+		renderMazeBox(w, maze, path, title, info, m, overlay)
+	})
+}
 
-	info := fmt.Sprintf(" (成本:%d,检查:%d,长度:%d)", res.Cost, res.Check, len(res.Path))
+// 寻路结果要标出的格子、说明和叠加层。体型大于1的智能体，路径上的位置是左上角，标出它占据过的所有格子
+func pathResultView(maze [][]int, res pathfind.PathFindResult, agentSize int) ([][]bool, string, string) {
+	info := fmt.Sprintf(" (成本:%d,检查:%d,长度:%d%s)", res.Cost, res.Check, len(res.Path), pathStatus(res.Found, res.Partial, res.Err))
 
 	path := res.Path
+	if agentSize > 1 {
+		info += fmt.Sprintf(" 体型%d", agentSize)
+		path = pathfind.Footprint(path, agentSize)
+	}
 	// 将路径转换为map以便快速查找
 	size := len(maze)
 	pathArr := make([][]bool, size)
@@ -94,8 +240,19 @@ func renderPathWithTitle(w http.ResponseWriter, maze [][]int, res pathfind.PathF
 		pathArr[p[0]][p[1]] = true
 	}
 
-	// 只保留步骤数据
-	stepsJSON, _ := json.Marshal(res.StepRecord)
+	// 任意角度路径：画出拐点之间的直线段
+	overlay := ""
+	if len(res.Waypoints) > 0 {
+		info += fmt.Sprintf(" 拐点%d个，直线长度%.1f", len(res.Waypoints), pathfind.WaypointsLength(res.Waypoints))
+		overlay = waypointsOverlay(maze, res.Waypoints)
+	}
+
+	return pathArr, info, overlay
+}
+
+// 只保留步骤数据，供pathfind.js按标题播放
+func renderStepsData(w http.ResponseWriter, title string, steps pathfind.MazeStepRecord) {
+	stepsJSON, _ := json.Marshal(steps)
 	fmt.Fprintf(w, `
 	<script>
 	if (!window.allStepsData) window.allStepsData = {};
@@ -103,6 +260,235 @@ func renderPathWithTitle(w http.ResponseWriter, maze [][]int, res pathfind.PathF
 	//console.log("加载步骤数据:", "%s", window.allStepsData["%s"]);
 	</script>
 	`, title, string(stepsJSON), title, title)
+}
+
+// 解析"行,列;行,列"格式的坐标列表，忽略格式错误的项
+func parseMazePosList(str string) [][2]int {
+	list := make([][2]int, 0)
+	for _, item := range strings.Split(str, ";") {
+		if pos := parseMazePos(strings.TrimSpace(item)); pos != nil {
+			list = append(list, *pos)
+		}
+	}
+	return list
+}
 
-	renderMazePathWithTitle(w, maze, pathArr, title, info)
+func formatMazePosList(list [][2]int) string {
+	strs := make([]string, len(list))
+	for i, pos := range list {
+		strs[i] = formatMazePos(pos)
+	}
+	return strings.Join(strs, ";")
+}
+
+func addFlowField(maps *mapEntries, maze [][]int, goals [][2]int, start, end [2]int) {
+	title := "流场"
+	pathArr := make([][]bool, len(maze))
+	for i := range maze {
+		pathArr[i] = make([]bool, len(maze[0]))
+	}
+	ff, err := pathfind.NewFlowField(maze, goals)
+	if err != nil {
+		info := " (参数错误: " + html.EscapeString(err.Error()) + ")"
+		maps.addMaze(title, info, maze, pathArr, start, end, "")
+		return
+	}
+
+	reachable, maxCost := 0, 0
+	for _, row := range ff.Cost {
+		for _, v := range row {
+			if v != pathfind.FlowUnreachable {
+				reachable++
+				maxCost = max(maxCost, v)
+			}
+		}
+	}
+	path := ff.Path(start)
+	for _, p := range path {
+		pathArr[p[0]][p[1]] = true
+	}
+	info := fmt.Sprintf(" (目标%d个，可达格子%d，最大代价%d，入口路径长度%d)", len(goals), reachable, maxCost, len(path))
+	maps.addMaze(title, info, maze, pathArr, start, end, flowFieldOverlay(maze, ff, goals))
+}
+
+// 每个可达格子画一个指向下一步的箭头，目标画成圆圈
+func flowFieldOverlay(maze [][]int, ff *pathfind.FlowField, goals [][2]int) string {
+	height, width := len(maze), len(maze[0])
+	str := fmt.Sprintf(`<svg class="flow-overlay" width="%d" height="%d">`, (width+2)*9, (height+2)*9)
+	str += `<defs><marker id="flow-arrow" markerWidth="4" markerHeight="4" refX="3" refY="2" orient="auto"><path d="M0,0 L4,2 L0,4 z"/></marker></defs>`
+	for r := 0; r < height; r++ {
+		for c := 0; c < width; c++ {
+			dir := ff.Dir[r][c]
+			if dir == [2]int{0, 0} {
+				continue
+			}
+			x, y := mazePixel(float64(c)+0.5), mazePixel(float64(r)+0.5)
+			dx, dy := 2.5*float64(dir[1]), 2.5*float64(dir[0])
+			str += fmt.Sprintf(`<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" marker-end="url(#flow-arrow)"/>`, x-dx, y-dy, x+dx, y+dy)
+		}
+	}
+	for _, g := range goals {
+		str += fmt.Sprintf(`<circle cx="%.1f" cy="%.1f" r="3"/>`, mazePixel(float64(g[1])+0.5), mazePixel(float64(g[0])+0.5))
+	}
+	return str + `</svg>`
+}
+
+// 解析智能体体型，范围1~5
+func parseAgentSize(req *http.Request) int {
+	if v, err := strconv.Atoi(req.URL.Query().Get("agentSize")); err == nil && v >= 1 && v <= 5 {
+		return v
+	}
+	return 1
+}
+
+// 间隙图：体型为agentSize的智能体能站的格子标为浅绿色，每个格子上写出间隙值
+func addClearance(maps *mapEntries, maze [][]int, start, end [2]int, agentSize int) {
+	clearance := pathfind.ClearanceMap(maze)
+	height, width := len(maze), len(maze[0])
+	overlay := fmt.Sprintf(`<svg class="clearance-overlay" width="%d" height="%d">`, (width+2)*9, (height+2)*9)
+	maxClearance, fits := 0, 0
+	for r := 0; r < height; r++ {
+		for c := 0; c < width; c++ {
+			v := clearance[r][c]
+			if v == 0 {
+				continue
+			}
+			maxClearance = max(maxClearance, v)
+			if v >= agentSize {
+				fits++
+				overlay += fmt.Sprintf(`<rect x="%.1f" y="%.1f" width="8" height="8"/>`, mazePixel(float64(c))-1, mazePixel(float64(r))-1)
+			}
+			overlay += fmt.Sprintf(`<text x="%.1f" y="%.1f">%d</text>`, mazePixel(float64(c)+0.5), mazePixel(float64(r)+0.5), v)
+		}
+	}
+	overlay += `</svg>`
+	info := fmt.Sprintf(" (最大间隙%d，体型%d可站立的格子%d个)", maxClearance, agentSize, fits)
+	empty := make([][]bool, height)
+	for i := range empty {
+		empty[i] = make([]bool, width)
+	}
+	maps.addMaze("间隙图", info, maze, empty, start, end, overlay)
+}
+
+// 解析地标数量（1~16）和选取方式
+func parseLandmarkParams(req *http.Request) (int, pathfind.LandmarkStrategy) {
+	count := pathfind.DefaultLandmarkCount
+	if v, err := strconv.Atoi(req.URL.Query().Get("landmarks")); err == nil && v >= 1 && v <= 16 {
+		count = v
+	}
+	strategy := pathfind.LandmarkFarthest
+	for _, s := range pathfind.LandmarkStrategies {
+		if req.URL.Query().Get("strategy") == string(s) {
+			strategy = s
+		}
+	}
+	return count, strategy
+}
+
+var landmarkStrategyTitles = map[pathfind.LandmarkStrategy]string{
+	pathfind.LandmarkFarthest: "最远点",
+	pathfind.LandmarkRandom:   "随机",
+	pathfind.LandmarkCorners:  "角落",
+}
+
+func landmarkStrategyOptions(selected pathfind.LandmarkStrategy) string {
+	str := ""
+	for _, s := range pathfind.LandmarkStrategies {
+		attr := ""
+		if s == selected {
+			attr = " selected"
+		}
+		str += fmt.Sprintf(`<option value="%s"%s>%s</option>`, s, attr, landmarkStrategyTitles[s])
+	}
+	return str
+}
+
+// altRow 地标对比表格的一行
+type altRow struct {
+	title, landmarks    string
+	preprocess, elapsed time.Duration
+	res                 pathfind.PathFindResult
+}
+
+// 地标对比：表格比较曼哈顿距离和各种选取方式的地标启发函数，地图上画出选中方式的地标和搜索过程
+func addALTComparison(maps *mapEntries, maze [][]int, start, end [2]int, count int, selected pathfind.LandmarkStrategy) {
+	var rows []altRow
+	row := func(title, landmarks string, preprocess time.Duration, find func() pathfind.PathFindResult) pathfind.PathFindResult {
+		t := time.Now()
+		res := find()
+		rows = append(rows, altRow{title: title, landmarks: landmarks, preprocess: preprocess, elapsed: time.Since(t), res: res})
+		return res
+	}
+	withLandmarks := func(lm *pathfind.Landmarks) func() pathfind.PathFindResult {
+		return func() pathfind.PathFindResult {
+			return pathfind.FindPathAStarHeuristic(maze, start, end, func(pos [2]int) int { return lm.Heuristic(pos, end) })
+		}
+	}
+
+	// 没有地标时启发函数就是曼哈顿距离
+	row("曼哈顿距离", "0", 0, withLandmarks(pathfind.NewLandmarks(maze, nil)))
+	var shown pathfind.PathFindResult
+	var shownLandmarks *pathfind.Landmarks
+	for _, s := range pathfind.LandmarkStrategies {
+		t := time.Now()
+		lm := pathfind.NewLandmarks(maze, pathfind.SelectLandmarks(maze, count, s))
+		res := row("ALT "+landmarkStrategyTitles[s], strconv.Itoa(len(lm.Points)), time.Since(t), withLandmarks(lm))
+		if s == selected {
+			shown, shownLandmarks = res, lm
+		}
+	}
+
+	// 小地图存下两两之间的距离，启发函数就是真实距离
+	t := time.Now()
+	table, tableErr := pathfind.NewDistanceTable(maze)
+	if tableErr == nil {
+		row("全源距离表", "-", time.Since(t), func() pathfind.PathFindResult { return table.FindPath(start, end) })
+	}
+
+	title := fmt.Sprintf("ALT（%s）地标", landmarkStrategyTitles[selected])
+	info := fmt.Sprintf(" (扩展:%d,入堆:%d,长度:%d%s)", shown.Expansions(), shown.Cost, len(shown.Path),
+		pathStatus(shown.Found, shown.Partial, shown.Err))
+	pathArr := make([][]bool, len(maze))
+	for i := range maze {
+		pathArr[i] = make([]bool, len(maze[0]))
+	}
+	for _, p := range shown.Path {
+		pathArr[p[0]][p[1]] = true
+	}
+	overlay := landmarksOverlay(maze, shownLandmarks.Points)
+
+	maps.add(title, maze, start, end, func(w http.ResponseWriter, m tiledmap.MapMetrics) {
+		fmt.Fprint(w, `
+	<div class='maze-box'>
+	<h3>地标启发函数对比</h3>
+	<table class="bench-table">
+		<tr><th>启发函数</th><th>地标</th><th>预处理</th><th>扩展</th><th>入堆</th><th>长度</th><th>寻路</th></tr>`)
+		for _, r := range rows {
+			fmt.Fprintf(w, `
+		<tr><td>%s</td><td>%s</td><td>%s</td><td>%d</td><td>%d</td><td>%d%s</td><td>%s</td></tr>`,
+				r.title, r.landmarks, formatDuration(r.preprocess), r.res.Expansions(), r.res.Cost, len(r.res.Path),
+				pathStatus(r.res.Found, r.res.Partial, r.res.Err), formatDuration(r.elapsed))
+		}
+		if tableErr != nil {
+			fmt.Fprintf(w, `
+		<tr><td>全源距离表</td><td colspan="6">通路格子超过%d个，不建表</td></tr>`, pathfind.MaxDistanceTableCells)
+		}
+		fmt.Fprint(w, `
+	</table>
+	</div>`)
+
+		renderStepsData(w, title, shown.StepRecord)
+		renderMazeBox(w, maze, pathArr, title, info, m, overlay)
+	})
+}
+
+// 地标画成带编号的圆圈
+func landmarksOverlay(maze [][]int, points [][2]int) string {
+	height, width := len(maze), len(maze[0])
+	str := fmt.Sprintf(`<svg class="alt-overlay" width="%d" height="%d">`, (width+2)*9, (height+2)*9)
+	for i, p := range points {
+		x, y := mazePixel(float64(p[1])+0.5), mazePixel(float64(p[0])+0.5)
+		str += fmt.Sprintf(`<circle cx="%.1f" cy="%.1f" r="6"/><text x="%.1f" y="%.1f">%d</text>`, x, y, x, y, i+1)
+	}
+	return str + `</svg>`
 }
@@ -2,49 +2,340 @@ package main
 
 import (
 	"fmt"
+	"html"
 	"log"
+	"math/rand"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"mazemap/tiledmap"
 )
 
+// cellularPage 细胞自动机页面的参数
+type cellularPage struct {
+	params      tiledmap.MazeParams
+	nb          tiledmap.Neighborhood
+	scheduleStr string
+	schedule    []tiledmap.CellularStep
+	scheduleErr error
+	density     cellularDensityParams
+	connect     tiledmap.CaveConnectOptions
+	chamber     int
+	minChoke    int
+}
+
+func parseCellularPage(req *http.Request) cellularPage {
+	p := cellularPage{
+		params:  parseCellularParams(req),
+		density: parseCellularDensity(req),
+		connect: parseCaveConnectOptions(req),
+	}
+	p.nb, p.scheduleStr = parseCellularRuleParams(req)
+	p.chamber, p.minChoke = parseCaveFeatureParams(req)
+
+	// 规则日程为空时使用经典的4-5规则迭代iterations次
+	p.schedule = []tiledmap.CellularStep{{
+		Rule:       tiledmap.ThresholdRule(6, 4, tiledmap.NeighborhoodMoore),
+		Iterations: p.params.Iterations,
+	}}
+	if p.scheduleStr != "" {
+		if steps, err := tiledmap.ParseCellularSchedule(p.scheduleStr, p.nb); err == nil {
+			p.schedule = steps
+		} else {
+			p.scheduleErr = err
+		}
+	}
+	return p
+}
+
+// 依次生成随机初始地图、细胞自动机迭代后、连接所有区域后的地图和洞穴特征，大地图只保留连接后的地图
+func (p cellularPage) maps() []mapMetricsEntry {
+	var maps mapEntries
+	r := rand.New(rand.NewSource(rand.Int63()))
+	maze := tiledmap.InitializeMazeRand(r, p.params.Size, p.density.field(r, p.params))
+	start, end := tiledmap.MazeCorners(maze)
+
+	if p.params.Size < 160 {
+		title := fmt.Sprintf("随机迷宫，障碍物率：%d%%", int(p.params.Probability*100))
+		if p.density.mode != densityUniform {
+			title += fmt.Sprintf("±%d%%（%s）", int(p.density.spread*100), densityNames[p.density.mode])
+		}
+		maps.addMaze(title, "", maze, nil, start, end, "")
+	}
+
+	tiledmap.CellularMazeWithSchedule(maze, p.schedule)
+
+	if p.params.Size < 260 {
+		maps.addMaze("细胞自动机："+formatCellularSchedule(p.schedule), "", maze, nil, start, end, "")
+	}
+
+	res := tiledmap.ConnectCaveRegions(maze, p.connect)
+	info := fmt.Sprintf("区域%d个，删除%d个，隧道%d条", res.Regions, res.Removed, res.Tunnels)
+	maps.addMaze(connectMethodNames[p.connect.Method]+"连接所有区域", info, maze, nil, start, end, "")
+
+	// 洞室、隧道和咽喉点，之后不再修改地图，不需要快照
+	if p.params.Size < 260 {
+		analysis := tiledmap.AnalyzeCave(maze, p.chamber, p.minChoke)
+		title := "洞室/隧道/咽喉点"
+		maps.add(title, maze, start, end, func(w http.ResponseWriter, m tiledmap.MapMetrics) {
+			renderCaveFeatures(w, maze, analysis, title, m)
+		})
+	}
+	return maps
+}
+
 func cellularHandler(w http.ResponseWriter, req *http.Request) {
 	printHtmlHead(w, "细胞自动机")
 
-	params := parseCellularParams(req)
+	p := parseCellularPage(req)
 
 	fmt.Fprintf(w, `
 <div class="all-container">
 	<div class="all-controls">
 		<form>
 			尺寸: <input type="number" name="size" value="%d" min="13" max="1000" step="2">
-			障碍物率: <input type="number" name="prob" value="%0.1f" step="0.1" min="0" max="1">
-			迭代次数: <input type="number" name="iter" value="%d" step="0.1" min="0" max="20">
+			障碍物率: <input type="number" name="probability" value="%0.2f" step="0.05" min="0" max="1">
+			迭代次数: <input type="number" name="iterations" value="%d" min="0" max="20">
+			%s
+			邻域: %s
+			规则: <input type="text" name="rule" value="%s" size="24" placeholder="B678/S45678:4;smooth:2">
+			%s
+			洞室半径: <input type="number" name="chamber" value="%d" min="1" max="10">
+			咽喉点最小分割: <input type="number" name="minChoke" value="%d" min="1">
 			<input type="submit" value="生成">
 		</form>
-	</div>
-	<div style="display: flex; gap: 20px; justify-content: center;">`,
-		params.Size, params.Probability, params.Iterations)
+	</div>`,
+		p.params.Size, p.params.Probability, p.params.Iterations,
+		densityControls(p.density), neighborhoodSelect(p.nb), html.EscapeString(p.scheduleStr),
+		caveConnectControls(p.connect), p.chamber, p.minChoke)
+	if p.scheduleErr != nil {
+		fmt.Fprintf(w, "\n<p class=\"error\">规则错误: %s，使用经典4-5规则</p>", html.EscapeString(p.scheduleErr.Error()))
+	}
+	fmt.Fprint(w, `
+	<div style="display: flex; gap: 20px; justify-content: center;">`)
+
+	renderMapEntries(w, p.maps())
+
+	fmt.Fprint(w, "\n</div></div></body></html>")
+}
 
-	maze := tiledmap.InitializeMaze(params.Size, params.Probability)
+// 初始障碍物的分布方式
+const (
+	densityUniform = "uniform" // 均匀
+	densityBlock   = "block"   // 分块随机
+	densityPerlin  = "perlin"  // 柏林噪声
+)
+
+var densityModes = []string{densityUniform, densityBlock, densityPerlin}
+
+var densityNames = map[string]string{
+	densityUniform: "均匀",
+	densityBlock:   "分块",
+	densityPerlin:  "噪声",
+}
+
+const (
+	defaultDensityBlock  = 16
+	defaultDensitySpread = 0.15
+)
 
-	if params.Size < 160 {
-		renderMazeWithTitle(w, maze, fmt.Sprintf("随机迷宫，障碍物率：%d%%", int(params.Probability*100)))
+type cellularDensityParams struct {
+	mode   string
+	block  int     // 分块大小，或者噪声的周期
+	spread float64 // 概率的浮动范围
+}
+
+func parseCellularDensity(req *http.Request) cellularDensityParams {
+	params := cellularDensityParams{
+		mode:   densityUniform,
+		block:  defaultDensityBlock,
+		spread: defaultDensitySpread,
 	}
 
-	for i := 0; i < params.Iterations; i++ {
-		tiledmap.CellularMaze(maze)
+	if mode := req.URL.Query().Get("density"); densityNames[mode] != "" {
+		params.mode = mode
 	}
 
-	if params.Size < 260 {
-		renderMazeWithTitle(w, maze, fmt.Sprintf("细胞自动机迭代：%d次", params.Iterations))
+	if str := req.URL.Query().Get("block"); str != "" {
+		if v, err := strconv.Atoi(str); err == nil && v > 0 && v <= tiledmap.CellularMaxSize {
+			params.block = v
+		}
 	}
 
-	tiledmap.ConnectRegionsByBFS(maze)
-	renderMazeWithTitle(w, maze, "BFS连接所有区域")
+	if str := req.URL.Query().Get("spread"); str != "" {
+		if v, err := strconv.ParseFloat(str, 64); err == nil && v >= 0 && v <= 1 {
+			params.spread = v
+		}
+	}
 
-	fmt.Fprint(w, "\n</div></div></body></html>")
+	return params
+}
+
+// 根据参数构造密度场，随机的分块概率和噪声种子从r取
+func (d cellularDensityParams) field(r *rand.Rand, params tiledmap.MazeParams) tiledmap.DensityField {
+	switch d.mode {
+	case densityBlock:
+		return tiledmap.NewRandomBlockDensity(r, params.Size, d.block, params.Probability, d.spread)
+	case densityPerlin:
+		return tiledmap.NewNoiseDensity(r, float64(d.block), params.Probability, d.spread, true)
+	}
+	return tiledmap.UniformDensity(params.Probability)
+}
+
+func densityControls(d cellularDensityParams) string {
+	str := `分布: <select name="density">`
+	for _, mode := range densityModes {
+		attr := ""
+		if mode == d.mode {
+			attr = " selected"
+		}
+		str += fmt.Sprintf(`<option value="%s"%s>%s</option>`, mode, attr, densityNames[mode])
+	}
+	str += `</select>`
+	str += fmt.Sprintf(`
+			分块: <input type="number" name="block" value="%d" min="1" max="%d">
+			浮动: <input type="number" name="spread" value="%0.2f" step="0.05" min="0" max="1">`,
+		d.block, tiledmap.CellularMaxSize, d.spread)
+	return str
+}
+
+const (
+	defaultMinChoke = 10
+)
+
+// 解析洞穴特征分析的参数：洞室的最小半径和咽喉点至少要分出去的格子数
+func parseCaveFeatureParams(req *http.Request) (chamber, minChoke int) {
+	chamber, minChoke = tiledmap.DefaultChamberRadius, defaultMinChoke
+
+	if str := req.URL.Query().Get("chamber"); str != "" {
+		if v, err := strconv.Atoi(str); err == nil && v >= 1 && v <= 10 {
+			chamber = v
+		}
+	}
+
+	if str := req.URL.Query().Get("minChoke"); str != "" {
+		if v, err := strconv.Atoi(str); err == nil && v >= 1 {
+			minChoke = v
+		}
+	}
+
+	return
+}
+
+// 按类别给格子上色
+func renderCaveFeatures(w http.ResponseWriter, maze [][]int, analysis tiledmap.CaveAnalysis, title string, m tiledmap.MapMetrics) {
+	chambers, tunnels := 0, 0
+	for _, r := range analysis.RegionList {
+		if r.Kind == tiledmap.FeatureChamber {
+			chambers++
+		} else {
+			tunnels++
+		}
+	}
+
+	fmt.Fprintf(w, "\n<div class='maze-box' data-title=\"%s\">", title)
+	fmt.Fprintf(w, "<h3>%s</h3>\n", title)
+	fmt.Fprintf(w, "\n<p style='font-size: 12px; margin-top: -15px; color: #666;'>洞室%d个，隧道%d段，咽喉点%d个</p>\n",
+		chambers, tunnels, len(analysis.Chokepoints))
+	fmt.Fprintf(w, `
+			<div class="wfc-grid" style="grid-template-columns: repeat(%d, 8px);">`, len(maze[0]))
+	for i := range maze {
+		for j := range maze[i] {
+			fmt.Fprintf(w, `<div class="wfc-cell cave-%s"></div>`, analysis.Features[i][j])
+		}
+	}
+	fmt.Fprint(w, "</div>")
+	renderMapMetrics(w, m)
+	fmt.Fprint(w, "</div>")
+}
+
+var connectMethodNames = map[tiledmap.CaveConnectMethod]string{
+	tiledmap.ConnectNearest: "BFS",
+	tiledmap.ConnectMST:     "最小生成树",
+}
+
+// 解析区域连接参数
+func parseCaveConnectOptions(req *http.Request) tiledmap.CaveConnectOptions {
+	opts := tiledmap.DefaultCaveConnectOptions()
+
+	method := tiledmap.CaveConnectMethod(req.URL.Query().Get("connect"))
+	if _, ok := connectMethodNames[method]; ok {
+		opts.Method = method
+	}
+
+	if str := req.URL.Query().Get("minRegion"); str != "" {
+		if v, err := strconv.Atoi(str); err == nil && v >= 0 {
+			opts.MinRegionSize = v
+		}
+	}
+
+	if str := req.URL.Query().Get("tunnelWidth"); str != "" {
+		if v, err := strconv.Atoi(str); err == nil && v >= 1 && v <= tiledmap.MaxTunnelWidth {
+			opts.TunnelWidth = v
+		}
+	}
+
+	if str := req.URL.Query().Get("jitter"); str != "" {
+		if v, err := strconv.ParseFloat(str, 64); err == nil && v >= 0 && v <= tiledmap.MaxTunnelJitter {
+			opts.Jitter = v
+		}
+	}
+
+	return opts
+}
+
+func caveConnectControls(opts tiledmap.CaveConnectOptions) string {
+	str := `连接: <select name="connect">`
+	for _, method := range []tiledmap.CaveConnectMethod{tiledmap.ConnectNearest, tiledmap.ConnectMST} {
+		attr := ""
+		if method == opts.Method {
+			attr = " selected"
+		}
+		str += fmt.Sprintf(`<option value="%s"%s>%s</option>`, method, attr, connectMethodNames[method])
+	}
+	str += `</select>`
+	str += fmt.Sprintf(`
+			最小区域: <input type="number" name="minRegion" value="%d" min="0">
+			隧道宽度: <input type="number" name="tunnelWidth" value="%d" min="1" max="%d">
+			扰动: <input type="number" name="jitter" value="%0.1f" step="0.1" min="0" max="%0.1f">`,
+		opts.MinRegionSize, opts.TunnelWidth, tiledmap.MaxTunnelWidth, opts.Jitter, tiledmap.MaxTunnelJitter)
+	return str
+}
+
+var neighborhoodNames = map[tiledmap.Neighborhood]string{
+	tiledmap.NeighborhoodMoore:      "Moore(8)",
+	tiledmap.NeighborhoodVonNeumann: "von Neumann(4)",
+	tiledmap.NeighborhoodMoore2:     "半径2(24)",
+}
+
+// 解析邻域和规则日程
+func parseCellularRuleParams(req *http.Request) (tiledmap.Neighborhood, string) {
+	nb := tiledmap.Neighborhood(req.URL.Query().Get("neighborhood"))
+	if _, ok := neighborhoodNames[nb]; !ok {
+		nb = tiledmap.NeighborhoodMoore
+	}
+	return nb, strings.TrimSpace(req.URL.Query().Get("rule"))
+}
+
+func neighborhoodSelect(selected tiledmap.Neighborhood) string {
+	str := `<select name="neighborhood">`
+	for _, nb := range tiledmap.Neighborhoods {
+		attr := ""
+		if nb == selected {
+			attr = " selected"
+		}
+		str += fmt.Sprintf(`<option value="%s"%s>%s</option>`, nb, attr, neighborhoodNames[nb])
+	}
+	return str + `</select>`
+}
+
+func formatCellularSchedule(schedule []tiledmap.CellularStep) string {
+	parts := make([]string, 0, len(schedule))
+	for _, step := range schedule {
+		parts = append(parts, fmt.Sprintf("%s×%d", step.Rule, step.Iterations))
+	}
+	return strings.Join(parts, " → ")
 }
 
 // 从请求中解析参数
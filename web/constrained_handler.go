@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"mazemap/tiledmap"
+)
+
+const (
+	defaultConstrainedSize = 41
+	maxConstrainedAttempts = 500
+)
+
+var constrainedKinds = []string{"maze", "cellular", "dungeon"}
+
+var constrainedKindNames = map[string]string{
+	"maze":     "迷宫",
+	"cellular": "细胞自动机",
+	"dungeon":  "地牢",
+}
+
+type constrainedParams struct {
+	kind         string
+	size         int
+	minFloor     float64
+	maxFloor     float64
+	minPath      int
+	minRooms     int
+	singleRegion bool
+	attempts     int
+	seed         int64
+}
+
+func parseConstrainedParams(query url.Values) constrainedParams {
+	params := constrainedParams{
+		kind:     "maze",
+		size:     defaultConstrainedSize,
+		attempts: tiledmap.DefaultMaxAttempts,
+	}
+
+	if kind := query.Get("kind"); constrainedKindNames[kind] != "" {
+		params.kind = kind
+	}
+
+	if v, err := strconv.Atoi(query.Get("size")); err == nil && v >= 13 && v <= 99 {
+		if v%2 == 0 {
+			v++ // 确保为奇数
+		}
+		params.size = v
+	}
+
+	if v, err := strconv.ParseFloat(query.Get("minFloor"), 64); err == nil && v >= 0 && v <= 1 {
+		params.minFloor = v
+	}
+
+	if v, err := strconv.ParseFloat(query.Get("maxFloor"), 64); err == nil && v >= 0 && v <= 1 {
+		params.maxFloor = v
+	}
+
+	if v, err := strconv.Atoi(query.Get("minPath")); err == nil && v >= 0 {
+		params.minPath = v
+	}
+
+	if v, err := strconv.Atoi(query.Get("minRooms")); err == nil && v >= 0 {
+		params.minRooms = v
+	}
+
+	params.singleRegion = query.Get("single") == "true"
+
+	if v, err := strconv.Atoi(query.Get("attempts")); err == nil && v >= 1 && v <= maxConstrainedAttempts {
+		params.attempts = v
+	}
+
+	if v, err := strconv.ParseInt(query.Get("seed"), 10, 64); err == nil {
+		params.seed = v
+	}
+
+	return params
+}
+
+// 把参数转换成验收条件，0表示不限制
+func (p constrainedParams) constraints() []tiledmap.Constraint {
+	constraints := make([]tiledmap.Constraint, 0)
+	if p.minFloor > 0 {
+		constraints = append(constraints, tiledmap.MinFloorRatio(p.minFloor))
+	}
+	if p.maxFloor > 0 {
+		constraints = append(constraints, tiledmap.MaxFloorRatio(p.maxFloor))
+	}
+	if p.minPath > 0 {
+		constraints = append(constraints, tiledmap.MinPathLength(p.minPath))
+	}
+	if p.minRooms > 0 {
+		constraints = append(constraints, tiledmap.MinRoomCount(p.minRooms))
+	}
+	if p.singleRegion {
+		constraints = append(constraints, tiledmap.SingleRegion())
+	}
+	return constraints
+}
+
+// 各种地图使用默认参数的生成器，所有随机数都从r取，按种子可以复现
+func (p constrainedParams) generator() func(r *rand.Rand) ([][]int, tiledmap.GeneratedMap) {
+	size := p.size
+	switch p.kind {
+	case "cellular":
+		return func(r *rand.Rand) ([][]int, tiledmap.GeneratedMap) {
+			maze := tiledmap.InitializeMazeRand(r, size, tiledmap.UniformDensity(tiledmap.DefaultProbability))
+			for i := 0; i < tiledmap.DefaultIterations; i++ {
+				tiledmap.CellularMaze(maze)
+			}
+			start, end := tiledmap.MazeCorners(maze)
+			return maze, tiledmap.GeneratedMap{Grid: maze, Start: start, End: end}
+		}
+	case "dungeon":
+		return func(r *rand.Rand) ([][]int, tiledmap.GeneratedMap) {
+			d := tiledmap.GenerateDungeonRand(r, size, size, 12, 5, 11, tiledmap.DefaultCorridorOptions())
+			d.GenerateMazeBetweenRooms()
+			d.ConnectPassagesByDFS()
+			d.ConnectAllRegions(0.2)
+			d.FillDeadEnds()
+			start, end := dungeonEnds(d)
+			return d.Tiles, tiledmap.GeneratedMap{Grid: d.Tiles, Start: start, End: end, Rooms: len(d.Rooms)}
+		}
+	}
+	return func(r *rand.Rand) ([][]int, tiledmap.GeneratedMap) {
+		res := tiledmap.GenerateMazeDetailed(size, size, tiledmap.MazeOptions{Algorithm: tiledmap.MazeDFS, TurnProb: 0.4, Rand: r})
+		path := tiledmap.FindPathBetween(res.Maze, res.Start, res.End)
+		tiledmap.AccuMazeBetween(res.Maze, path, 0.7, res.Start, res.End)
+		tiledmap.ErosionMazeRand(r, res.Maze, 0.5)
+		return res.Maze, tiledmap.GeneratedMap{Grid: res.Maze, Start: res.Start, End: res.End}
+	}
+}
+
+// constrainedHandler 按验收条件反复生成地图，直到满足条件或者用完尝试次数
+func constrainedHandler(w http.ResponseWriter, req *http.Request) {
+	printHtmlHead(w, "约束生成")
+
+	query := req.URL.Query()
+	params := parseConstrainedParams(query)
+
+	kindOptions := ""
+	for _, kind := range constrainedKinds {
+		selected := ""
+		if kind == params.kind {
+			selected = " selected"
+		}
+		kindOptions += fmt.Sprintf(`<option value="%s"%s>%s</option>`, kind, selected, constrainedKindNames[kind])
+	}
+	checked := ""
+	if params.singleRegion {
+		checked = "checked"
+	}
+	seedStr := ""
+	if params.seed != 0 {
+		seedStr = strconv.FormatInt(params.seed, 10)
+	}
+	fmt.Fprintf(w, `
+<div class="all-container">
+	<div class="all-controls">
+		<form>
+			地图: <select name="kind">%s</select>
+			尺寸: <input type="number" name="size" value="%d" min="13" max="99" step="2">
+			最小通路占比: <input type="number" name="minFloor" value="%g" step="0.05" min="0" max="1">
+			最大通路占比: <input type="number" name="maxFloor" value="%g" step="0.05" min="0" max="1">
+			最短路径: <input type="number" name="minPath" value="%d" min="0">
+			最少房间: <input type="number" name="minRooms" value="%d" min="0">
+			<label><input type="checkbox" name="single" value="true" %s> 单一区域</label>
+			尝试次数: <input type="number" name="attempts" value="%d" min="1" max="%d">
+			种子: <input type="number" name="seed" value="%s" placeholder="随机">
+			<input type="submit" value="生成">
+		</form>
+	</div>`,
+		kindOptions, params.size, params.minFloor, params.maxFloor, params.minPath, params.minRooms,
+		checked, params.attempts, maxConstrainedAttempts, seedStr)
+
+	constraints := params.constraints()
+	res, err := tiledmap.GenerateWithConstraints(params.generator(), constraints,
+		tiledmap.RetryOptions{MaxAttempts: params.attempts, Seed: params.seed})
+
+	if err != nil {
+		fmt.Fprintf(w, "\n<p class=\"error\">%d次尝试都没有满足所有条件，显示不满足条件最少的一张</p>", res.Attempts)
+	}
+
+	// 用找到的种子、尝试一次即可复现
+	replay := url.Values{}
+	for k, v := range query {
+		replay[k] = v
+	}
+	replay.Set("seed", strconv.FormatInt(res.Seed, 10))
+	replay.Set("attempts", "1")
+
+	fmt.Fprint(w, `
+	<div style="display: flex; gap: 20px; justify-content: center;">`)
+	info := fmt.Sprintf(`种子%d，尝试%d次 <a href="?%s">复现</a>`, res.Seed, res.Attempts, replay.Encode())
+	if params.kind == "dungeon" {
+		info += fmt.Sprintf("，房间%d个", res.Info.Rooms)
+	}
+	renderMazeWithInfo(w, res.Map, constrainedKindNames[params.kind], info, res.Info.Start, res.Info.End)
+
+	// 每个条件不满足的次数
+	names := make([]string, 0, len(constraints))
+	for _, c := range constraints {
+		names = append(names, c.Name)
+	}
+	sort.Strings(names)
+	fmt.Fprint(w, `
+		<table class="bench-table">
+			<tr><th>条件</th><th>不满足次数</th></tr>`)
+	for _, name := range names {
+		fmt.Fprintf(w, `
+			<tr><td>%s</td><td>%d</td></tr>`, name, res.Failures[name])
+	}
+	fmt.Fprint(w, "\n\t\t</table>\n</div></div></body></html>")
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mazemap/pathfind"
+	"mazemap/tiledmap"
+)
+
+// D* Lite演示中的一步
+type dstarFrame struct {
+	Pos       [2]int   // 机器人的位置
+	Toggled   [][2]int // 这一步修改的格子
+	Replan    bool     // 这一步是否重新规划
+	Path      [][2]int // 重新规划得到的路径，从Pos开始
+	Cost      int      // D* Lite修复时的入堆次数
+	AStarCost int      // 从当前位置从头运行A*的入堆次数，不可达时为0
+}
+
+type dstarSim struct {
+	Frames []dstarFrame
+	Found  bool // 最后是否到达终点
+}
+
+func dstarHandler(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Query().Get("format") == "json" {
+		dstarJSON(w, req)
+		return
+	}
+	printHtmlHead(w, "D* Lite增量重规划", true)
+
+	size, turnProb, accRatio, erosionRatio := parseMazeParams(req)
+	opts := parseMazeOptions(req, turnProb)
+	width, height := parseMazeShape(req, size)
+	start, end := tiledmap.MazeEnds(width, height, opts)
+
+	fmt.Fprintf(w, `
+<div class="all-container">
+	<div class="all-controls">
+		<form>
+			宽度: <input type="number" name="width" value="%d" min="2" max="99">
+			高度: <input type="number" name="height" value="%d" min="2" max="99">
+			转弯概率: <input type="number" name="turn" value="%0.1f" step="0.1" min="0" max="1">
+			堆积系数: <input type="number" name="acc" value="%0.1f" step="0.1" min="0" max="1">
+			侵蚀系数: <input type="number" name="erosion" value="%0.1f" step="0.1" min="0" max="1">
+			<input type="submit" value="生成">
+		</form>
+	</div>
+	<div class="playback-controls">
+		<button onclick="dstarTogglePlayback()" id="dstar-play-btn">播放</button>
+		<button onclick="dstarReset()">重置</button>
+		<input type="range" min="50" max="1000" value="200" onchange="dstarSpeed(this.value)">
+		<span>点击格子切换墙，机器人从当前位置增量重规划</span>
+	</div>
+	<div style="display: flex; gap: 20px; justify-content: center;">`,
+		width, height, turnProb, accRatio, erosionRatio)
+
+	maze := tiledmap.GenerateRectMaze(width, height, opts)
+	path := tiledmap.FindPathBetween(maze, start, end)
+	tiledmap.AccuMazeBetween(maze, path, accRatio, start, end)
+	tiledmap.ErosionMaze(maze, erosionRatio)
+
+	grid := encodeGrid(maze)
+	sim := simulateDStar(cloneGrid(maze), start, end, nil)
+	simJSON, _ := json.Marshal(sim)
+
+	empty := make([][]bool, len(maze))
+	for i := range maze {
+		empty[i] = make([]bool, len(maze[0]))
+	}
+	overlay := fmt.Sprintf(`<svg class="path-overlay" width="%d" height="%d"><polyline id="dstar-plan" points=""/><circle id="dstar-agent" r="3.5"/></svg>`,
+		(width+2)*9, (height+2)*9)
+	fmt.Fprint(w, "\n<div class='maze-box' id='dstar-box'>")
+	fmt.Fprint(w, "<h3>D* Lite</h3>\n<p id='dstar-info' style='font-size: 12px; margin-top: -15px; color: #666;'></p>\n")
+	renderMazeWithPath(w, maze, empty, false, overlay)
+	fmt.Fprint(w, `</div>`)
+
+	fmt.Fprintf(w, `
+	<script>
+	initDStar({grid: "%s", start: "%s", end: "%s", width: %d, height: %d, sim: %s});
+	</script>`, grid, formatMazePos(start), formatMazePos(end), width, height, string(simJSON))
+
+	fmt.Fprint(w, "\n</div></div></body></html>")
+}
+
+// 按页面上的初始地图和修改记录重新模拟，返回JSON
+func dstarJSON(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	maze, err := decodeGrid(query.Get("grid"))
+	start, end := parseMazePos(query.Get("start")), parseMazePos(query.Get("end"))
+	if err != nil || start == nil || end == nil {
+		http.Error(w, "invalid grid, start or end", http.StatusBadRequest)
+		return
+	}
+	toggles, err := parseToggles(query.Get("toggles"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(simulateDStar(maze, *start, *end, toggles))
+}
+
+// 机器人沿着规划的路径每步走一格，第step步开始时修改toggles[step]中的格子（墙和通路互换），
+// 有修改时让D* Lite从当前位置增量重规划，并记录从头运行A*的工作量作为对比
+func simulateDStar(maze [][]int, start, end [2]int, toggles map[int][][2]int) dstarSim {
+	var sim dstarSim
+	planner := pathfind.NewDStarLite(maze, start, end)
+	pos := start
+	var path [][2]int
+	next := 0
+	for step := 0; step <= len(maze)*len(maze[0])*2; step++ {
+		frame := dstarFrame{Pos: pos}
+		for _, p := range toggles[step] {
+			if p == pos || p == end || p[0] < 0 || p[0] >= len(maze) || p[1] < 0 || p[1] >= len(maze[0]) {
+				continue
+			}
+			planner.SetCell(p, 1-maze[p[0]][p[1]])
+			frame.Toggled = append(frame.Toggled, p)
+		}
+		if step == 0 || len(frame.Toggled) > 0 {
+			res := planner.Plan()
+			frame.Replan, frame.Path, frame.Cost = true, res.Path, res.Cost
+			if res.Found {
+				frame.AStarCost = pathfind.FindPathAStar(maze, pos, end).Cost
+			}
+			path, next = res.Path, 1
+		}
+		sim.Frames = append(sim.Frames, frame)
+		if pos == end {
+			sim.Found = true
+			break
+		}
+		if next >= len(path) {
+			break
+		}
+		pos = path[next]
+		next++
+		planner.Move(pos)
+	}
+	return sim
+}
+
+// 修改记录的格式为 步数:行,列;步数:行,列
+func parseToggles(str string) (map[int][][2]int, error) {
+	toggles := make(map[int][][2]int)
+	if str == "" {
+		return toggles, nil
+	}
+	for _, item := range strings.Split(str, ";") {
+		stepStr, posStr, ok := strings.Cut(item, ":")
+		step, err := strconv.Atoi(stepStr)
+		pos := parseMazePos(posStr)
+		if !ok || err != nil || step < 0 || pos == nil {
+			return nil, fmt.Errorf("invalid toggle %q", item)
+		}
+		toggles[step] = append(toggles[step], *pos)
+	}
+	return toggles, nil
+}
+
+// 地图编码成一行字符串：每行由0和1组成，行之间用.分隔
+func encodeGrid(maze [][]int) string {
+	rows := make([]string, len(maze))
+	for i, row := range maze {
+		var sb strings.Builder
+		for _, v := range row {
+			if v == 0 {
+				sb.WriteByte('0')
+			} else {
+				sb.WriteByte('1')
+			}
+		}
+		rows[i] = sb.String()
+	}
+	return strings.Join(rows, ".")
+}
+
+func decodeGrid(str string) ([][]int, error) {
+	rows := strings.Split(str, ".")
+	if str == "" || len(rows) >= maxSize {
+		return nil, fmt.Errorf("invalid grid")
+	}
+	maze := make([][]int, len(rows))
+	for i, row := range rows {
+		if len(row) != len(rows[0]) || len(row) >= maxSize {
+			return nil, fmt.Errorf("invalid grid")
+		}
+		maze[i] = make([]int, len(row))
+		for j, c := range row {
+			switch c {
+			case '0':
+			case '1':
+				maze[i][j] = 1
+			default:
+				return nil, fmt.Errorf("invalid grid")
+			}
+		}
+	}
+	return maze, nil
+}
+
+func cloneGrid(maze [][]int) [][]int {
+	clone := make([][]int, len(maze))
+	for i, row := range maze {
+		clone[i] = append([]int(nil), row...)
+	}
+	return clone
+}
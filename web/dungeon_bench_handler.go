@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mazemap/tiledmap"
+)
+
+// 超过这个尺寸时不再运行逐对比较的版本，太慢了
+const bruteForceMaxSize = 201
+
+// dungeonBenchHandler 对比连接区检测新旧两种实现的耗时，以及整体生成的耗时
+func dungeonBenchHandler(w http.ResponseWriter, r *http.Request) {
+	printHtmlHead(w, "地牢生成性能")
+
+	rounds := 3
+	if rd := r.URL.Query().Get("rounds"); rd != "" {
+		if val, err := strconv.Atoi(rd); err == nil && val > 0 && val <= 20 {
+			rounds = val
+		}
+	}
+
+	fmt.Fprintf(w, `
+<div class="all-container">
+	<div class="all-controls">
+		<form>
+			轮数: <input type="number" name="rounds" value="%d" min="1" max="20">
+			<input type="submit" value="运行">
+		</form>
+	</div>
+	<table class="bench-table">
+		<tr><th>尺寸</th><th>房间数</th><th>区域数</th><th>连接区数</th><th>逐对比较</th><th>单遍扫描</th><th>缓存区域</th><th>整体生成</th></tr>`, rounds)
+
+	for _, size := range []int{51, 101, 201, 301, 501} {
+		var rooms, regions, conns int
+		var brute, fast, cached, total time.Duration
+		for i := 0; i < rounds; i++ {
+			start := time.Now()
+			d := tiledmap.GenerateDungeon(size, size, size, 5, 11)
+			d.GenerateMazeBetweenRooms()
+			d.ConnectPassagesByDFS()
+			genTime := time.Since(start)
+
+			if size <= bruteForceMaxSize {
+				t := time.Now()
+				d.FindConnectionInfoBruteForce()
+				brute += time.Since(t)
+			}
+
+			t := time.Now()
+			rg, cs := d.FindConnectionInfo()
+			fast += time.Since(t)
+
+			// 第二次查询直接使用第一次标记的区域
+			t = time.Now()
+			d.FindConnectionInfo()
+			cached += time.Since(t)
+
+			t = time.Now()
+			d.ConnectAllRegions(0.1)
+			d.FillDeadEnds()
+			total += genTime + time.Since(t)
+
+			rooms, regions, conns = len(d.Rooms), len(rg), len(cs)
+		}
+
+		bruteStr := "-"
+		if size <= bruteForceMaxSize {
+			bruteStr = formatDuration(brute / time.Duration(rounds))
+		}
+		fmt.Fprintf(w, `
+		<tr><td>%d</td><td>%d</td><td>%d</td><td>%d</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>`,
+			size, rooms, regions, conns, bruteStr, formatDuration(fast/time.Duration(rounds)),
+			formatDuration(cached/time.Duration(rounds)), formatDuration(total/time.Duration(rounds)))
+	}
+
+	fmt.Fprint(w, "\n</table></div></body></html>")
+}
+
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.2fms", float64(d.Microseconds())/1000)
+}
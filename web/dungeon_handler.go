@@ -8,65 +8,134 @@ import (
 	"mazemap/tiledmap"
 )
 
-func dungeonHandler(w http.ResponseWriter, r *http.Request) {
+// dungeonPage 地牢页面的参数，宽高和房间尺寸都是奇数
+type dungeonPage struct {
+	width, height    int
+	rooms            int
+	minSize, maxSize int
+	extraPathProb    float32
+	corridor         tiledmap.CorridorOptions
+	straighten       bool
+}
 
-	printHtmlHead(w, "迷宫生成算法")
+func parseDungeonPage(r *http.Request) dungeonPage {
+	p := dungeonPage{
+		width:         51,
+		height:        51,
+		rooms:         8,
+		minSize:       5,
+		maxSize:       11,
+		extraPathProb: 0.2,
+		corridor:      tiledmap.DefaultCorridorOptions(),
+	}
 
-	// 解析参数，确保为奇数
-	width := 51 // 默认值改为奇数
 	if w := r.URL.Query().Get("width"); w != "" {
 		if val, err := strconv.Atoi(w); err == nil && val > 12 && val <= 100 {
 			if val%2 == 0 {
 				val++ // 确保为奇数
 			}
-			width = val
+			p.width = val
 		}
 	}
 
-	height := 51 // 默认值改为奇数
 	if h := r.URL.Query().Get("height"); h != "" {
 		if val, err := strconv.Atoi(h); err == nil && val > 12 && val <= 100 {
 			if val%2 == 0 {
 				val++ // 确保为奇数
 			}
-			height = val
+			p.height = val
 		}
 	}
 
-	rooms := 8
 	if rm := r.URL.Query().Get("rooms"); rm != "" {
 		if val, err := strconv.Atoi(rm); err == nil && val > 0 && val <= 50 {
-			rooms = val
+			p.rooms = val
 		}
 	}
 
-	minSize := 5
 	if ms := r.URL.Query().Get("minSize"); ms != "" {
 		if val, err := strconv.Atoi(ms); err == nil && val >= 3 && val <= 15 {
 			if val%2 == 0 {
 				val++ // 确保为奇数
 			}
-			minSize = val
+			p.minSize = val
 		}
 	}
 
-	maxSize := 11
 	if ms := r.URL.Query().Get("maxSize"); ms != "" {
 		if val, err := strconv.Atoi(ms); err == nil && val >= 3 && val <= 15 {
 			if val%2 == 0 {
 				val++ // 确保为奇数
 			}
-			maxSize = val
+			p.maxSize = val
 		}
 	}
 
-	extraPathProb := float32(0.2)
 	if ep := r.URL.Query().Get("extraPathProb"); ep != "" {
 		if val, err := strconv.ParseFloat(ep, 64); err == nil && val >= 0 && val <= 1 {
-			extraPathProb = float32(val)
+			p.extraPathProb = float32(val)
+		}
+	}
+
+	if cw := r.URL.Query().Get("corridorWidth"); cw != "" {
+		if val, err := strconv.Atoi(cw); err == nil && val >= tiledmap.MinCorridorWidth && val <= tiledmap.MaxCorridorWidth {
+			p.corridor.Width = val
 		}
 	}
 
+	if wd := r.URL.Query().Get("winding"); wd != "" {
+		if val, err := strconv.ParseFloat(wd, 64); err == nil && val >= 0 && val <= 1 {
+			p.corridor.WindingPercent = val
+		}
+	}
+
+	if kd := r.URL.Query().Get("keepDeadEnds"); kd != "" {
+		if val, err := strconv.ParseFloat(kd, 64); err == nil && val >= 0 && val <= 1 {
+			p.corridor.KeepDeadEnds = val
+		}
+	}
+
+	p.straighten = r.URL.Query().Get("straighten") == "true"
+	return p
+}
+
+// 地牢生成的每个阶段各一张地图
+func (p dungeonPage) maps() []mapMetricsEntry {
+	var maps mapEntries
+
+	// 生成地牢
+	dungeon := tiledmap.GenerateDungeonWithOptions(p.width, p.height, p.rooms, p.minSize, p.maxSize, p.corridor)
+
+	// 第一阶段：生成迷宫
+	dungeon.GenerateMazeBetweenRooms()
+	addDungeonStage(&maps, dungeon, "阶段1: 生成迷宫")
+
+	// 第二阶段：连接通道
+	dungeon.ConnectPassagesByDFS()
+	addDungeonStage(&maps, dungeon, "阶段2: 生成通道")
+
+	// 第三阶段：连接所有区域
+	dungeon.ConnectAllRegions(p.extraPathProb)
+	addDungeonStage(&maps, dungeon, "阶段3: 连接区域")
+
+	// 第四阶段：把死胡同堵上
+	dungeon.FillDeadEnds()
+	addDungeonStage(&maps, dungeon, "阶段4: 堵上死胡同")
+
+	// 第五阶段：拉直通道
+	if p.straighten {
+		dungeon.StraightenCorridors()
+		addDungeonStage(&maps, dungeon, "阶段5: 拉直通道")
+	}
+	return maps
+}
+
+func dungeonHandler(w http.ResponseWriter, r *http.Request) {
+
+	printHtmlHead(w, "迷宫生成算法")
+
+	p := parseDungeonPage(r)
+
 	// 控制表单
 	fmt.Fprintf(w, `
 <div class="all-container">
@@ -78,49 +147,65 @@ func dungeonHandler(w http.ResponseWriter, r *http.Request) {
 			最小房间尺寸: <input type="number" name="minSize" value="%d" min="3" max="15" step="2">
 			最大房间尺寸: <input type="number" name="maxSize" value="%d" min="5" max="15" step="2">
 			额外通路概率: <input type="number" name="extraPathProb" value="%0.1f" step="0.1" min="0" max="1">
+			通道宽度: <input type="number" name="corridorWidth" value="%d" min="1" max="3">
+			转弯系数: <input type="number" name="winding" value="%0.1f" step="0.1" min="0" max="1">
+			保留死胡同: <input type="number" name="keepDeadEnds" value="%0.1f" step="0.1" min="0" max="1">
+			<label><input type="checkbox" name="straighten" value="true" %s> 拉直通道</label>
 			<input type="submit" value="生成">
 		</form>
 	</div>
 	<div style="display: flex; gap: 20px; justify-content: center;">`,
-		width, height, rooms, minSize, maxSize, extraPathProb)
-
-	// 生成地牢
-	dungeon := tiledmap.GenerateDungeon(width, height, rooms, minSize, maxSize)
-
-	// 第一阶段：生成迷宫
-	dungeon.GenerateMazeBetweenRooms()
-	renderDungeonWithTitle(w, dungeon, "阶段1: 生成迷宫")
+		p.width, p.height, p.rooms, p.minSize, p.maxSize, p.extraPathProb,
+		p.corridor.Width, p.corridor.WindingPercent, p.corridor.KeepDeadEnds,
+		func() string {
+			if p.straighten {
+				return "checked"
+			}
+			return ""
+		}())
 
-	// 第二阶段：连接通道
-	dungeon.ConnectPassagesByDFS()
-	renderDungeonWithTitle(w, dungeon, "阶段2: 生成通道")
-
-	// 第三阶段：连接所有区域
-	dungeon.ConnectAllRegions(extraPathProb)
-	renderDungeonWithTitle(w, dungeon, "阶段3: 连接区域")
-
-	// 第四阶段：把死胡同堵上
-	dungeon.FillDeadEnds()
-	renderDungeonWithTitle(w, dungeon, "阶段4: 堵上死胡同")
+	renderMapEntries(w, p.maps())
 
 	fmt.Fprint(w, "\n</div></div></body></html>")
 }
 
-func renderDungeonWithTitle(w http.ResponseWriter, d *tiledmap.Dungeon, title string) {
+// 记录地牢此时的快照，质量指标以第一个和最后一个房间的中心为起终点
+func addDungeonStage(maps *mapEntries, d *tiledmap.Dungeon, title string) {
+	tiles, rooms := cloneGrid(d.Tiles), append([]tiledmap.Room(nil), d.Rooms...)
+	start, end := dungeonEnds(d)
+	maps.add(title, tiles, start, end, func(w http.ResponseWriter, m tiledmap.MapMetrics) {
+		renderDungeonWithTitle(w, tiles, rooms, title, m)
+	})
+}
+
+func renderDungeonWithTitle(w http.ResponseWriter, tiles [][]int, rooms []tiledmap.Room, title string, m tiledmap.MapMetrics) {
 	fmt.Fprintf(w, `
 		<div>
 			<h3 style="text-align: center">%s</h3>
-			<div class="wfc-grid" style="grid-template-columns: repeat(%d, 8px);">`, title, d.Width)
-	renderDungeon(w, d)
-	fmt.Fprint(w, "</div></div>")
+			<div class="wfc-grid" style="grid-template-columns: repeat(%d, 8px);">`, title, len(tiles[0]))
+	renderDungeon(w, tiles, rooms)
+	fmt.Fprint(w, "</div>")
+	renderMapMetrics(w, m)
+	fmt.Fprint(w, "</div>")
+}
+
+// 质量指标以第一个和最后一个房间的中心为起终点（行，列）
+func dungeonEnds(d *tiledmap.Dungeon) (start, end [2]int) {
+	if len(d.Rooms) == 0 {
+		return
+	}
+	first, last := d.Rooms[0], d.Rooms[len(d.Rooms)-1]
+	start = [2]int{first.Y + first.Height/2, first.X + first.Width/2}
+	end = [2]int{last.Y + last.Height/2, last.X + last.Width/2}
+	return
 }
 
-func renderDungeon(w http.ResponseWriter, d *tiledmap.Dungeon) {
+func renderDungeon(w http.ResponseWriter, tiles [][]int, rooms []tiledmap.Room) {
 	// 渲染地牢网格
-	for y := 0; y < d.Height; y++ {
-		for x := 0; x < d.Width; x++ {
+	for y := range tiles {
+		for x := range tiles[y] {
 			cellClass := "wall"
-			if d.Tiles[y][x] == 0 {
+			if tiles[y][x] == 0 {
 				cellClass = "floor"
 			}
 			fmt.Fprintf(w, `<div class="wfc-cell %s"></div>`, cellClass)
@@ -128,7 +213,7 @@ func renderDungeon(w http.ResponseWriter, d *tiledmap.Dungeon) {
 	}
 
 	// 渲染房间编号
-	for i, room := range d.Rooms {
+	for i, room := range rooms {
 		centerX := room.X + room.Width/2
 		centerY := room.Y + room.Height/2
 
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+
+	"mazemap/tiledmap"
 )
 
 // handler echoes r.URL.Path
@@ -19,6 +22,7 @@ func indexHandler(w http.ResponseWriter, req *http.Request) {
 				<ul>
 					<li><a href="/cellular">元胞自动机 (Cellular Automata)</a></li>
 					<li><a href="/dungeon">地下城生成器 (Dungeon Generator)</a></li>
+					<li><a href="/multifloor">多层地下城 (Multi-Floor Dungeon)</a></li>
 					<li><a href="/maze">迷宫生成器 (Maze Generator)</a></li>
 					<li><a href="/perlin">柏林噪声地图 (Perlin Noise Map)</a></li>
 					<li><a href="/wfc">波函数坍缩 (Wave Function Collapse)</a></li>
@@ -28,11 +32,17 @@ func indexHandler(w http.ResponseWriter, req *http.Request) {
 				<h2>寻路算法</h2>
 				<ul>
 					<li><a href="/astar">综合比照 (PathFind MISC)</a></li>
+					<li><a href="/hpa">分层寻路 (HPA*)</a></li>
+					<li><a href="/dstar">增量重规划 (D* Lite)</a></li>
+					<li><a href="/multiagent">多智能体寻路 (CA* / WHCA* / CBS)</a></li>
 				</ul>
 			</div>
 			<div class="algorithms">
 				<h2>调试工具</h2>
 				<ul>
+					<li><a href="/dungeonbench">地牢生成性能对比</a></li>
+					<li><a href="/sweep">参数扫描与批量统计</a></li>
+					<li><a href="/constrained">按条件重试生成</a></li>
 					<li><a href="/hello">查看 Header 信息</a></li>
 					<li><a href="/test/hello">字符串反转测试</a></li>
 				</ul>
@@ -96,41 +106,59 @@ func printHtmlHead(w http.ResponseWriter, title string, includepathjs ...bool) {
     `)
 }
 
-// 渲染带标题的迷宫
-func renderMazeWithTitle(w http.ResponseWriter, maze [][]int, title string) {
-	fmt.Fprintf(w, "\n<div class='maze-box' data-title=\"%s\">", title)
-	fmt.Fprintf(w, "<h3>%s</h3>\n", title)
-	fmt.Fprintf(w, "\n<p style='font-size: 12px; margin-top: -15px; color: #666;'>%s</p>\n", "")
-	renderMazeWithPath(w, maze, nil, false)
-	fmt.Fprintf(w, `</div>`)
+// 渲染带标题和信息的迷宫，不显示路径
+func renderMazeWithInfo(w http.ResponseWriter, maze [][]int, title string, info string, start, end [2]int) {
+	renderMazeBox(w, maze, nil, title, info, tiledmap.AnalyzeMap(maze, start, end), "")
 }
 
-// 渲染带标题和信息的迷宫
-func renderMazePathWithTitle(w http.ResponseWriter, maze [][]int, path [][]bool, title string, info string) {
+// 渲染带标题和信息的迷宫，overlay为叠加在迷宫上的HTML（例如SVG）
+func renderMazePathWithOverlay(w http.ResponseWriter, maze [][]int, path [][]bool, title string, info string, start, end [2]int, overlay string) {
+	renderMazeBox(w, maze, path, title, info, tiledmap.AnalyzeMap(maze, start, end), overlay)
+}
+
+// 渲染带标题、信息和质量指标的迷宫，path为nil时不显示路径
+func renderMazeBox(w http.ResponseWriter, maze [][]int, path [][]bool, title string, info string, m tiledmap.MapMetrics, overlay string) {
 	fmt.Fprintf(w, "\n<div class='maze-box' data-title=\"%s\">", title)
 	fmt.Fprintf(w, "<h3>%s</h3>\n", title)
 	fmt.Fprintf(w, "\n<p style='font-size: 12px; margin-top: -15px; color: #666;'>%s</p>\n", info)
-	renderMazeWithPath(w, maze, path, true)
+	renderMazeWithPath(w, maze, path, path != nil, overlay)
+	renderMapMetrics(w, m)
 	fmt.Fprintf(w, `</div>`)
 }
 
-func renderMazeWithPath(w http.ResponseWriter, maze [][]int, path [][]bool, showPath bool) {
-	size := len(maze)
+// 在地图下方显示质量指标，展开后是完整的JSON
+func renderMapMetrics(w http.ResponseWriter, m tiledmap.MapMetrics) {
+	largest := 0
+	if len(m.RegionSizes) > 0 {
+		largest = m.RegionSizes[0]
+	}
+	data, _ := json.MarshalIndent(m, "", "  ")
+	fmt.Fprintf(w, `
+		<details class="metrics">
+			<summary>通路%.1f%% · 区域%d(最大%d) · 死胡同%d · 宽度%.2f · 路径%d · 曲折度%.2f · 割点%d</summary>
+			<pre>%s</pre>
+		</details>`,
+		m.FloorRatio*100, m.RegionCount, largest, m.DeadEnds, m.AvgCorridorWidth,
+		m.PathLength, m.Tortuosity, m.ArticulationPoints, data)
+}
+
+func renderMazeWithPath(w http.ResponseWriter, maze [][]int, path [][]bool, showPath bool, overlay string) {
+	height, width := len(maze), len(maze[0])
 
 	fmt.Fprintf(w, `
-		<div class="dungeon-container" style="position: relative; width: %dpx; height: %dpx;">`, (size+2)*9, (size+2)*9)
+		<div class="dungeon-container" style="position: relative; width: %dpx; height: %dpx;">`, (width+2)*9, (height+2)*9)
 
 	// dungeon-grid
 	fmt.Fprintf(w, `
-		<div class="dungeon-grid" style="grid-template-columns: repeat(%d, 8px); grid-template-rows: repeat(%d, 8px);">`, size+2, size+2)
+		<div class="dungeon-grid" style="grid-template-columns: repeat(%d, 8px); grid-template-rows: repeat(%d, 8px);">`, width+2, height+2)
 
-	for i := 0; i < size+2; i++ {
+	for i := 0; i < width+2; i++ {
 		fmt.Fprintf(w, `<div class="dungeon-cell wall"></div>`)
 	}
 
-	for y := 0; y < size; y++ {
+	for y := 0; y < height; y++ {
 		fmt.Fprintf(w, `<div class="dungeon-cell wall"></div>`)
-		for x := 0; x < size; x++ {
+		for x := 0; x < width; x++ {
 			cellClass := "wall"
 			if maze[y][x] == 0 {
 				cellClass = "floor"
@@ -143,21 +171,21 @@ func renderMazeWithPath(w http.ResponseWriter, maze [][]int, path [][]bool, show
 		fmt.Fprintf(w, `<div class="dungeon-cell wall"></div>`)
 	}
 
-	for i := 0; i < size+2; i++ {
+	for i := 0; i < width+2; i++ {
 		fmt.Fprintf(w, `<div class="dungeon-cell wall"></div>`)
 	}
 	fmt.Fprintf(w, `</div>`)
 
 	// step-layer
 	fmt.Fprintf(w, `
-		<div class="step-layer" style="grid-template-columns: repeat(%d, 8px); grid-template-rows: repeat(%d, 8px);">`, size+2, size+2)
-	for i := 0; i < size+2; i++ {
+		<div class="step-layer" style="grid-template-columns: repeat(%d, 8px); grid-template-rows: repeat(%d, 8px);">`, width+2, height+2)
+	for i := 0; i < width+2; i++ {
 		fmt.Fprintf(w, `<div class="step-info"></div>`)
 	}
 	// 在这里可以添加步数信息或其他辅助信息
-	for y := 0; y < size; y++ {
+	for y := 0; y < height; y++ {
 		fmt.Fprintf(w, `<div class="step-info"></div>`)
-		for x := 0; x < size; x++ {
+		for x := 0; x < width; x++ {
 			if showPath && path[y][x] {
 				//fmt.Fprintf(w, `<div class="step-info" style="width: 4px; height: 4px; background-color: rgba(255, 0, 0, 0.5); margin: auto;"></div>`)
 				fmt.Fprintf(w, `<div class="step-info path-dot"></div>`)
@@ -167,9 +195,29 @@ func renderMazeWithPath(w http.ResponseWriter, maze [][]int, path [][]bool, show
 		}
 		fmt.Fprintf(w, `<div class="step-info"></div>`)
 	}
-	for i := 0; i < size+2; i++ {
+	for i := 0; i < width+2; i++ {
 		fmt.Fprintf(w, `<div class="step-info"></div>`)
 	}
 	fmt.Fprintf(w, `</div>`) // 结束 step-layer
+	fmt.Fprint(w, overlay)
 	fmt.Fprintf(w, `</div>`)
 }
+
+// 迷宫格子(行, 列)的实数坐标转换为dungeon-container中的像素坐标，外面有一圈墙，每格8px加1px间隔
+func mazePixel(v float64) float64 {
+	return 1 + 9*(v+1)
+}
+
+// 用SVG折线画出任意角度路径的拐点
+func waypointsOverlay(maze [][]int, points [][2]float64) string {
+	height, width := len(maze), len(maze[0])
+	str := fmt.Sprintf(`<svg class="path-overlay" width="%d" height="%d"><polyline points="`, (width+2)*9, (height+2)*9)
+	for _, p := range points {
+		str += fmt.Sprintf("%.1f,%.1f ", mazePixel(p[1]), mazePixel(p[0]))
+	}
+	str += `"/>`
+	for _, p := range points {
+		str += fmt.Sprintf(`<circle cx="%.1f" cy="%.1f" r="2"/>`, mazePixel(p[1]), mazePixel(p[0]))
+	}
+	return str + `</svg>`
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mazemap/pathfind"
+	"mazemap/tiledmap"
+)
+
+func hpaHandler(w http.ResponseWriter, req *http.Request) {
+	printHtmlHead(w, "分层寻路HPA*", true)
+
+	size, cluster, flips := parseHPAParams(req)
+
+	fmt.Fprintf(w, `
+<div class="all-container">
+	<div class="all-controls">
+		<form>
+			尺寸: <input type="number" name="size" value="%d" min="20" max="150">
+			簇大小: <input type="number" name="cluster" value="%d" min="3" max="30">
+			随机修改格子数: <input type="number" name="flip" value="%d" min="0" max="200">
+			<input type="submit" value="生成">
+		</form>
+	</div>
+	<div class="playback-controls">
+		<button onclick="togglePlayback()" id="playback-btn">播放</button>
+		<input type="range" min="50" max="1000" value="200"
+			   onchange="updateSpeed(this.value)" id="speed-control">
+		<span>更新间隔: <span id="speed-value">200</span>ms</span>
+		<button onclick="stepPlayback()" id="playback-btn">Step</button>
+	</div>`, size, cluster, flips)
+
+	maze := tiledmap.InitializeMaze(size, 0.45)
+	for i := 0; i < tiledmap.DefaultIterations; i++ {
+		tiledmap.CellularMaze(maze)
+	}
+	tiledmap.ConnectCaveRegions(maze, tiledmap.DefaultCaveConnectOptions())
+	start, end := tiledmap.MazeCorners(maze)
+
+	t := time.Now()
+	hpa := pathfind.NewHPA(maze, cluster)
+	buildTime := time.Since(t)
+
+	// 随机翻转一些格子，用增量更新维护抽象图，和整体重建的耗时比较
+	var updateTime time.Duration
+	for i := 0; i < flips; i++ {
+		pos := [2]int{rand.Intn(len(maze)), rand.Intn(len(maze[0]))}
+		if pos == start || pos == end {
+			continue
+		}
+		t = time.Now()
+		hpa.SetCell(pos, 1-maze[pos[0]][pos[1]])
+		updateTime += time.Since(t)
+	}
+
+	t = time.Now()
+	hpaRes := hpa.FindPath(maze, start, end)
+	hpaTime := time.Since(t)
+	abstract := hpa.AbstractPath(start, end)
+	t = time.Now()
+	astarRes := pathfind.FindPathAStar(maze, start, end)
+	astarTime := time.Since(t)
+
+	rows, cols := hpa.Clusters()
+	edges := hpa.Edges()
+	fmt.Fprintf(w, `
+	<table class="bench-table">
+		<tr><th>簇</th><th>抽象节点</th><th>抽象边</th><th>建图</th><th>增量更新</th><th>HPA*寻路</th><th>A*寻路</th></tr>
+		<tr><td>%d×%d</td><td>%d</td><td>%d</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>
+	</table>`,
+		rows, cols, len(hpa.Nodes()), len(edges), formatDuration(buildTime),
+		hpaUpdateInfo(flips, updateTime), formatDuration(hpaTime), formatDuration(astarTime))
+
+	fmt.Fprint(w, `
+	<div style="display: flex; gap: 20px; justify-content: center;">`)
+
+	overlay := hpaOverlay(maze, hpa, edges)
+	if abstract != nil {
+		overlay += waypointsOverlay(maze, pathfind.CellCenters(abstract))
+	}
+	renderHPAResult(w, maze, hpaRes, "HPA*寻路结果", start, end, overlay,
+		fmt.Sprintf(" 抽象路径%d个节点", len(abstract)))
+	renderPathWithTitle(w, maze, astarRes, "A*寻路结果", start, end)
+
+	fmt.Fprint(w, "\n</div></div></body></html>")
+}
+
+func parseHPAParams(req *http.Request) (size, cluster, flips int) {
+	size, cluster, flips = 80, pathfind.DefaultClusterSize, 0
+	query := req.URL.Query()
+	if v, err := strconv.Atoi(query.Get("size")); err == nil && v >= 20 && v <= 150 {
+		size = v
+	}
+	if v, err := strconv.Atoi(query.Get("cluster")); err == nil && v >= 3 && v <= 30 {
+		cluster = v
+	}
+	if v, err := strconv.Atoi(query.Get("flip")); err == nil && v >= 0 && v <= 200 {
+		flips = v
+	}
+	return
+}
+
+func hpaUpdateInfo(flips int, total time.Duration) string {
+	if flips == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d次，平均%s", flips, formatDuration(total/time.Duration(flips)))
+}
+
+// 带抽象图叠加层的寻路结果，步骤播放的是抽象图上的搜索
+func renderHPAResult(w http.ResponseWriter, maze [][]int, res pathfind.PathFindResult, title string, start, end [2]int, overlay, extra string) {
+	info := fmt.Sprintf(" (成本:%d,检查:%d,长度:%d%s)%s", res.Cost, res.Check, len(res.Path), pathStatus(res.Found, res.Partial, res.Err), extra)
+	pathArr := make([][]bool, len(maze))
+	for i := range maze {
+		pathArr[i] = make([]bool, len(maze[0]))
+	}
+	for _, p := range res.Path {
+		pathArr[p[0]][p[1]] = true
+	}
+	renderStepsData(w, title, res.StepRecord)
+	renderMazePathWithOverlay(w, maze, pathArr, title, info, start, end, overlay)
+}
+
+// 簇的边界线、抽象图的节点和边
+func hpaOverlay(maze [][]int, hpa *pathfind.HPA, edges []pathfind.HPAEdge) string {
+	height, width := len(maze), len(maze[0])
+	str := fmt.Sprintf(`<svg class="hpa-overlay" width="%d" height="%d">`, (width+2)*9, (height+2)*9)
+	for r := hpa.ClusterSize; r < height; r += hpa.ClusterSize {
+		y := mazePixel(float64(r)) - 0.5
+		str += fmt.Sprintf(`<line class="hpa-cluster" x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f"/>`, mazePixel(0), y, mazePixel(float64(width)), y)
+	}
+	for c := hpa.ClusterSize; c < width; c += hpa.ClusterSize {
+		x := mazePixel(float64(c)) - 0.5
+		str += fmt.Sprintf(`<line class="hpa-cluster" x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f"/>`, x, mazePixel(0), x, mazePixel(float64(height)))
+	}
+	for _, e := range edges {
+		class := "hpa-intra"
+		if e.Inter {
+			class = "hpa-inter"
+		}
+		str += fmt.Sprintf(`<line class="%s" x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f"/>`, class,
+			mazePixel(float64(e.A[1])+0.5), mazePixel(float64(e.A[0])+0.5), mazePixel(float64(e.B[1])+0.5), mazePixel(float64(e.B[0])+0.5))
+	}
+	for _, n := range hpa.Nodes() {
+		str += fmt.Sprintf(`<circle cx="%.1f" cy="%.1f" r="1.5"/>`, mazePixel(float64(n[1])+0.5), mazePixel(float64(n[0])+0.5))
+	}
+	return str + `</svg>`
+}
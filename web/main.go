@@ -9,13 +9,20 @@ func main() {
 	http.HandleFunc("/", indexHandler)
 	http.HandleFunc("/hello", helloHandler)
 	http.HandleFunc("/test/", testHandler)
-	http.HandleFunc("/maze", mazeHandler)
-	http.HandleFunc("/cellular", cellularHandler)
-	http.HandleFunc("/perlin", perlinHandler)
+	http.HandleFunc("/maze", withMetricsJSON(mazeHandler, parseMazePage))
+	http.HandleFunc("/cellular", withMetricsJSON(cellularHandler, parseCellularPage))
+	http.HandleFunc("/perlin", withMetricsJSON(perlinHandler, parsePerlinPage))
 	http.HandleFunc("/perlingray", perlinGrayHandler)
-	http.HandleFunc("/dungeon", dungeonHandler)
+	http.HandleFunc("/dungeon", withMetricsJSON(dungeonHandler, parseDungeonPage))
+	http.HandleFunc("/multifloor", multiFloorHandler)
+	http.HandleFunc("/dungeonbench", dungeonBenchHandler)
+	http.HandleFunc("/sweep", sweepHandler)
+	http.HandleFunc("/constrained", constrainedHandler)
 	http.HandleFunc("/wfc", wfcHandler)
-	http.HandleFunc("/astar", astarHandler)
+	http.HandleFunc("/astar", withMetricsJSON(astarHandler, parseAstarPage))
+	http.HandleFunc("/hpa", hpaHandler)
+	http.HandleFunc("/dstar", dstarHandler)
+	http.HandleFunc("/multiagent", multiAgentHandler)
 	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("web/static"))))
 	log.Fatal(http.ListenAndServe(":9999", nil))
 }
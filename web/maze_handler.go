@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"mazemap/tiledmap"
 )
@@ -49,37 +50,309 @@ func parseMazeParams(req *http.Request) (size int, turnProb, accRatio, erosionRa
 	return
 }
 
+// 解析迷宫的宽高，未指定时使用size
+func parseMazeShape(req *http.Request, size int) (width, height int) {
+	width, height = size, size
+
+	if wStr := req.URL.Query().Get("width"); wStr != "" {
+		if v, err := strconv.Atoi(wStr); err == nil && v > 1 && v < maxSize {
+			width = v
+		}
+	}
+
+	if hStr := req.URL.Query().Get("height"); hStr != "" {
+		if v, err := strconv.Atoi(hStr); err == nil && v > 1 && v < maxSize {
+			height = v
+		}
+	}
+
+	return
+}
+
+// 解析"行,列"格式的坐标
+func parseMazePos(str string) *[2]int {
+	parts := strings.Split(str, ",")
+	if len(parts) != 2 {
+		return nil
+	}
+	r, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+	c, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err1 != nil || err2 != nil || r < 0 || c < 0 {
+		return nil
+	}
+	return &[2]int{r, c}
+}
+
+func formatMazePos(pos [2]int) string {
+	return fmt.Sprintf("%d,%d", pos[0], pos[1])
+}
+
+// 迷宫生成算法在页面上显示的名字
+var mazeAlgorithmNames = map[tiledmap.MazeAlgorithm]string{
+	tiledmap.MazeDFS:         "深度优先回溯",
+	tiledmap.MazePrim:        "Prim",
+	tiledmap.MazeKruskal:     "Kruskal",
+	tiledmap.MazeWilson:      "Wilson",
+	tiledmap.MazeEller:       "Eller",
+	tiledmap.MazeGrowingTree: "Growing Tree",
+	tiledmap.MazeHuntAndKill: "Hunt-and-Kill",
+}
+
+var growingTreeNames = map[tiledmap.GrowingTreeStrategy]string{
+	tiledmap.GrowingTreeNewest: "最新",
+	tiledmap.GrowingTreeRandom: "随机",
+	tiledmap.GrowingTreeOldest: "最旧",
+	tiledmap.GrowingTreeMixed:  "混合",
+}
+
+// 解析迷宫生成算法参数
+func parseMazeOptions(req *http.Request, turnProb float64) tiledmap.MazeOptions {
+	opts := tiledmap.MazeOptions{
+		Algorithm:   tiledmap.MazeDFS,
+		TurnProb:    turnProb,
+		GrowingTree: tiledmap.GrowingTreeNewest,
+	}
+
+	algo := tiledmap.MazeAlgorithm(req.URL.Query().Get("algo"))
+	if _, ok := mazeAlgorithmNames[algo]; ok {
+		opts.Algorithm = algo
+	}
+
+	strategy := tiledmap.GrowingTreeStrategy(req.URL.Query().Get("strategy"))
+	if _, ok := growingTreeNames[strategy]; ok {
+		opts.GrowingTree = strategy
+	}
+
+	opts.Start = parseMazePos(req.URL.Query().Get("start"))
+	opts.End = parseMazePos(req.URL.Query().Get("end"))
+
+	// 目标路径长度：不超过1时表示占通路格子的比例，否则为格子数
+	if lenStr := req.URL.Query().Get("length"); lenStr != "" {
+		if v, err := strconv.ParseFloat(lenStr, 64); err == nil && v > 0 {
+			if v <= 1 {
+				opts.TargetRatio = v
+			} else {
+				opts.TargetLength = int(v)
+			}
+		}
+	}
+
+	return opts
+}
+
+// 侵蚀方式
+const (
+	erosionWeight   = "weight"   // 按权重随机侵蚀
+	erosionCellular = "cellular" // 细胞自动机侵蚀
+)
+
+type mazeErosionParams struct {
+	mode       string
+	bounded    bool    // 是否限制最短路径的缩短比例
+	maxShorten float64 // 允许最短路径缩短的最大比例
+	iterations int     // 细胞自动机迭代次数
+	birth      int     // 通路变成墙需要的最少墙邻居数
+	survive    int     // 墙保留需要的最少墙邻居数
+}
+
+// 解析侵蚀方式相关的参数
+func parseMazeErosion(req *http.Request) mazeErosionParams {
+	params := mazeErosionParams{
+		mode:       erosionWeight,
+		iterations: tiledmap.DefaultErosionIterations,
+		birth:      tiledmap.DefaultErosionBirth,
+		survive:    tiledmap.DefaultErosionSurvive,
+	}
+
+	if req.URL.Query().Get("erosionMode") == erosionCellular {
+		params.mode = erosionCellular
+	}
+
+	// 侵蚀时允许最短路径缩短的最大比例，未指定时不限制
+	if str := req.URL.Query().Get("shorten"); str != "" {
+		if v, err := strconv.ParseFloat(str, 64); err == nil && v >= 0 && v <= 1 {
+			params.bounded, params.maxShorten = true, v
+		}
+	}
+
+	if str := req.URL.Query().Get("caIter"); str != "" {
+		if v, err := strconv.Atoi(str); err == nil && v >= 0 && v <= tiledmap.MaxIterations {
+			params.iterations = v
+		}
+	}
+
+	// 邻居数范围是0~8，9表示永远不会发生
+	if str := req.URL.Query().Get("birth"); str != "" {
+		if v, err := strconv.Atoi(str); err == nil && v >= 0 && v <= 9 {
+			params.birth = v
+		}
+	}
+
+	if str := req.URL.Query().Get("survive"); str != "" {
+		if v, err := strconv.Atoi(str); err == nil && v >= 0 && v <= 9 {
+			params.survive = v
+		}
+	}
+
+	return params
+}
+
+// 侵蚀方式的控件
+func mazeErosionControls(params mazeErosionParams) string {
+	shorten := ""
+	if params.bounded {
+		shorten = strconv.FormatFloat(params.maxShorten, 'f', -1, 64)
+	}
+
+	modes := []struct{ value, name string }{
+		{erosionWeight, "按权重"},
+		{erosionCellular, "细胞自动机"},
+	}
+	html := `侵蚀方式: <select name="erosionMode">`
+	for _, m := range modes {
+		selected := ""
+		if m.value == params.mode {
+			selected = " selected"
+		}
+		html += fmt.Sprintf(`<option value="%s"%s>%s</option>`, m.value, selected, m.name)
+	}
+	html += `</select>`
+	html += fmt.Sprintf(`
+			最大缩短: <input type="text" name="shorten" value="%s" size="4" placeholder="不限">
+			迭代: <input type="number" name="caIter" value="%d" min="0" max="%d">
+			出生: <input type="number" name="birth" value="%d" min="0" max="9">
+			存活: <input type="number" name="survive" value="%d" min="0" max="9">`,
+		shorten, params.iterations, tiledmap.MaxIterations, params.birth, params.survive)
+	return html
+}
+
+func formatMazeTarget(opts tiledmap.MazeOptions) string {
+	switch {
+	case opts.TargetLength > 0:
+		return strconv.Itoa(opts.TargetLength)
+	case opts.TargetRatio > 0:
+		return strconv.FormatFloat(opts.TargetRatio, 'f', -1, 64)
+	}
+	return ""
+}
+
+// 生成算法的下拉框
+func mazeAlgorithmSelect(opts tiledmap.MazeOptions) string {
+	html := `算法: <select name="algo">`
+	for _, algo := range tiledmap.MazeAlgorithms {
+		selected := ""
+		if algo == opts.Algorithm {
+			selected = " selected"
+		}
+		html += fmt.Sprintf(`<option value="%s"%s>%s</option>`, algo, selected, mazeAlgorithmNames[algo])
+	}
+	html += `</select>
+			挑选方式: <select name="strategy">`
+	for _, strategy := range tiledmap.GrowingTreeStrategies {
+		selected := ""
+		if strategy == opts.GrowingTree {
+			selected = " selected"
+		}
+		html += fmt.Sprintf(`<option value="%s"%s>%s</option>`, strategy, selected, growingTreeNames[strategy])
+	}
+	html += `</select>`
+	return html
+}
+
+// mazePage 迷宫页面的参数
+type mazePage struct {
+	width, height                    int
+	turnProb, accRatio, erosionRatio float64
+	opts                             tiledmap.MazeOptions
+	erosion                          mazeErosionParams
+}
+
+func parseMazePage(req *http.Request) mazePage {
+	size, turnProb, accRatio, erosionRatio := parseMazeParams(req)
+	width, height := parseMazeShape(req, size)
+	return mazePage{
+		width:        width,
+		height:       height,
+		turnProb:     turnProb,
+		accRatio:     accRatio,
+		erosionRatio: erosionRatio,
+		opts:         parseMazeOptions(req, turnProb),
+		erosion:      parseMazeErosion(req),
+	}
+}
+
+// 依次生成原始迷宫、堆积后和侵蚀后的迷宫
+func (p mazePage) maps() []mapMetricsEntry {
+	var maps mapEntries
+
+	// 生成迷宫，指定目标长度且没有指定出口时出口由生成结果决定
+	result := tiledmap.GenerateMazeDetailed(p.width, p.height, p.opts)
+	maze, start, end := result.Maze, result.Start, result.End
+	path := tiledmap.FindPathBetween(maze, start, end)
+
+	// 第一个画布：原始迷宫
+	info := fmt.Sprintf("路径长度: %d", result.PathLength)
+	if result.TargetLength > 0 {
+		info += fmt.Sprintf(" (目标: %d, 生成%d次)", result.TargetLength, result.Attempts)
+	}
+	maps.addMaze("原始迷宫", info, maze, path, start, end, "")
+
+	tiledmap.AccuMazeBetween(maze, path, p.accRatio, start, end)
+	maps.addMaze("堆积后", "", maze, path, start, end, "") // 第二个画布：消除断头路后
+
+	// 第三个画布：侵蚀后
+	switch {
+	case p.erosion.mode == erosionCellular:
+		// 细胞自动机侵蚀，保护堆积后的唯一路径
+		changed := tiledmap.CellularErosionMaze(maze, path, p.erosion.iterations, p.erosion.birth, p.erosion.survive)
+		info := fmt.Sprintf("细胞自动机迭代%d次，变化%d格", p.erosion.iterations, changed)
+		maps.addMaze("侵蚀后", info, maze, path, start, end, "")
+	case p.erosion.bounded:
+		// 限制最短路径的缩短比例
+		res := tiledmap.ErosionMazeBounded(maze, p.erosionRatio, p.erosion.maxShorten, start, end)
+		info := fmt.Sprintf("路径长度: %d → %d (拒绝%d次)", res.OriginalLength, res.FinalLength, res.Rejected)
+		maps.addMaze("侵蚀后", info, maze, tiledmap.FindPathBetween(maze, start, end), start, end, "")
+	default:
+		tiledmap.ErosionMaze(maze, p.erosionRatio)
+		maps.addMaze("侵蚀后", "", maze, nil, start, end, "")
+	}
+	return maps
+}
+
 func mazeHandler(w http.ResponseWriter, req *http.Request) {
 	printHtmlHead(w, "迷宫堆积")
 
-	size, turnProb, accRatio, erosionRatio := parseMazeParams(req)
+	p := parseMazePage(req)
+	start, end := tiledmap.MazeEnds(p.width, p.height, p.opts)
+	// 指定目标长度且没有指定出口时出口由生成结果决定
+	endStr := formatMazePos(end)
+	if p.opts.End == nil && formatMazeTarget(p.opts) != "" {
+		endStr = ""
+	}
 
 	// 控制表单
 	fmt.Fprintf(w, `
 <div class="all-container">
 	<div class="all-controls">
 		<form>
-			尺寸: <input type="number" name="size" value="%d" min="13" max="99" step="2">
+			宽度: <input type="number" name="width" value="%d" min="2" max="99">
+			高度: <input type="number" name="height" value="%d" min="2" max="99">
+			入口: <input type="text" name="start" value="%s" size="5">
+			出口: <input type="text" name="end" value="%s" size="5" placeholder="自动">
+			路径长度: <input type="text" name="length" value="%s" size="5" placeholder="不限">
 			转弯概率: <input type="number" name="turn" value="%0.1f" step="0.1" min="0" max="1">
 			堆积系数: <input type="number" name="acc" value="%0.1f" step="0.1" min="0" max="1">
 			侵蚀系数: <input type="number" name="erosion" value="%0.1f" step="0.1" min="0" max="1">
+			%s
+			%s
 			<input type="submit" value="生成">
 		</form>
 	</div>
 	<div style="display: flex; gap: 20px; justify-content: center;">`,
-		size, turnProb, accRatio, erosionRatio)
-	// 生成迷宫和寻找路径
-	maze := tiledmap.GenerateMaze(size, turnProb)
-	path := tiledmap.FindPath(maze)
-
-	// 第一个画布：原始迷宫
-	renderMazePathWithTitle(w, maze, path, "原始迷宫", "")
-
-	tiledmap.AccuMaze(maze, path, accRatio)
-	renderMazePathWithTitle(w, maze, path, "堆积后", "") // 第二个画布：消除断头路后
+		p.width, p.height, formatMazePos(start), endStr, formatMazeTarget(p.opts),
+		p.turnProb, p.accRatio, p.erosionRatio, mazeErosionControls(p.erosion), mazeAlgorithmSelect(p.opts))
 
-	tiledmap.ErosionMaze(maze, erosionRatio)
-	renderMazeWithTitle(w, maze, "侵蚀后") // 第三个画布：侵蚀后
+	renderMapEntries(w, p.maps())
 
 	// 结束 HTML
 	fmt.Fprint(w, "\n</div></div></body></html>")
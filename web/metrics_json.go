@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"mazemap/tiledmap"
+)
+
+// 地图页面先按参数生成所有地图，得到按显示顺序排列的[]mapMetricsEntry，
+// HTML页面依次显示，format=json时直接输出每张地图的标题和质量指标
+
+type mapMetricsEntry struct {
+	Title   string              `json:"title"`
+	Metrics tiledmap.MapMetrics `json:"metrics"`
+
+	render func(w http.ResponseWriter) // 在HTML页面上显示这张地图
+}
+
+// mapEntries 按显示顺序收集页面上的地图
+type mapEntries []mapMetricsEntry
+
+// add 计算grid此时的质量指标，show用这些指标在HTML页面上显示地图，grid之后还会被修改时show要使用快照
+func (e *mapEntries) add(title string, grid [][]int, start, end [2]int, show func(w http.ResponseWriter, m tiledmap.MapMetrics)) {
+	m := tiledmap.AnalyzeMap(grid, start, end)
+	*e = append(*e, mapMetricsEntry{Title: title, Metrics: m, render: func(w http.ResponseWriter) { show(w, m) }})
+}
+
+// addMaze 记录迷宫和路径此时的快照，path为nil时不显示路径，overlay为叠加在迷宫上的HTML
+func (e *mapEntries) addMaze(title, info string, maze [][]int, path [][]bool, start, end [2]int, overlay string) {
+	snapshot := cloneGrid(maze)
+	var pathSnapshot [][]bool
+	for _, row := range path {
+		pathSnapshot = append(pathSnapshot, append([]bool(nil), row...))
+	}
+	e.add(title, maze, start, end, func(w http.ResponseWriter, m tiledmap.MapMetrics) {
+		renderMazeBox(w, snapshot, pathSnapshot, title, info, m, overlay)
+	})
+}
+
+func renderMapEntries(w http.ResponseWriter, maps []mapMetricsEntry) {
+	for _, m := range maps {
+		m.render(w)
+	}
+}
+
+// mapPage 按请求参数生成地图的页面
+type mapPage interface {
+	maps() []mapMetricsEntry
+}
+
+// withMetricsJSON 包装地图页面，format=json时不输出HTML，
+// 用parse解析的参数生成地图，返回 [{"title": ..., "metrics": MapMetrics}, ...]
+func withMetricsJSON[P mapPage](h http.HandlerFunc, parse func(req *http.Request) P) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("format") != "json" {
+			h(w, req)
+			return
+		}
+		maps := parse(req).maps()
+		if maps == nil {
+			maps = make([]mapMetricsEntry, 0)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(maps)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mazemap/pathfind"
+	"mazemap/tiledmap"
+)
+
+const maxCBSNodes = 500
+
+// 多智能体寻路算法，页面上按这个顺序比较
+var multiAgentAlgos = []struct {
+	name, title string
+}{
+	{"ca", "Cooperative A*"},
+	{"whca", "WHCA*"},
+	{"cbs", "CBS"},
+}
+
+type multiAgentParams struct {
+	size   int
+	agents int
+	algo   string
+	window int
+}
+
+func multiAgentHandler(w http.ResponseWriter, req *http.Request) {
+	printHtmlHead(w, "多智能体寻路", true)
+	params := parseMultiAgentParams(req)
+
+	algoOptions := ""
+	for _, a := range multiAgentAlgos {
+		selected := ""
+		if a.name == params.algo {
+			selected = " selected"
+		}
+		algoOptions += fmt.Sprintf(`<option value="%s"%s>%s</option>`, a.name, selected, a.title)
+	}
+	fmt.Fprintf(w, `
+<div class="all-container">
+	<div class="all-controls">
+		<form>
+			尺寸: <input type="number" name="size" value="%d" min="10" max="60">
+			智能体数: <input type="number" name="agents" value="%d" min="1" max="30">
+			演示算法: <select name="algo">%s</select>
+			WHCA*窗口: <input type="number" name="window" value="%d" min="2" max="32">
+			<input type="submit" value="生成">
+		</form>
+	</div>
+	<div class="playback-controls">
+		<button onclick="agentsTogglePlayback()" id="agents-play-btn">播放</button>
+		<button onclick="agentsStep()">Step</button>
+		<button onclick="agentsReset()">重置</button>
+		<input type="range" min="50" max="1000" value="300" onchange="agentsSpeed(this.value)">
+	</div>`, params.size, params.agents, algoOptions, params.window)
+
+	maze := tiledmap.InitializeMaze(params.size, 0.4)
+	for i := 0; i < tiledmap.DefaultIterations; i++ {
+		tiledmap.CellularMaze(maze)
+	}
+	tiledmap.ConnectCaveRegions(maze, tiledmap.DefaultCaveConnectOptions())
+	agents := randomAgents(maze, params.agents)
+
+	// 三种算法的对比表
+	fmt.Fprint(w, `
+	<table class="bench-table">
+		<tr><th>算法</th><th>结果</th><th>完成时刻</th><th>总代价</th><th>时空A*出堆</th><th>CBS节点</th><th>冲突</th><th>耗时</th></tr>`)
+	var shown pathfind.MultiAgentResult
+	for _, a := range multiAgentAlgos {
+		t := time.Now()
+		res := findMultiAgentPaths(a.name, maze, agents, params.window)
+		elapsed := time.Since(t)
+		if a.name == params.algo {
+			shown = res
+		}
+		fmt.Fprintf(w, `
+		<tr><td>%s</td><td>%s</td><td>%d</td><td>%d</td><td>%d</td><td>%d</td><td>%d</td><td>%s</td></tr>`,
+			a.title, multiAgentStatus(res), res.Makespan(), res.SumOfCosts(), res.Expanded, res.Nodes,
+			len(pathfind.FindConflicts(res.Paths)), formatDuration(elapsed))
+	}
+	fmt.Fprint(w, `
+	</table>
+	<div style="display: flex; gap: 20px; justify-content: center;">`)
+
+	height, width := len(maze), len(maze[0])
+	overlay := fmt.Sprintf(`<svg class="agents-overlay" width="%d" height="%d">`, (width+2)*9, (height+2)*9)
+	for i, a := range agents {
+		color := agentColor(i, len(agents))
+		overlay += fmt.Sprintf(`<rect x="%.1f" y="%.1f" width="7" height="7" stroke="%s"/>`,
+			mazePixel(float64(a.Goal[1]))-0.5, mazePixel(float64(a.Goal[0]))-0.5, color)
+		overlay += fmt.Sprintf(`<circle id="agent-%d" cx="%.1f" cy="%.1f" r="3.5" fill="%s"/>`,
+			i, mazePixel(float64(a.Start[1])+0.5), mazePixel(float64(a.Start[0])+0.5), color)
+	}
+	overlay += `</svg>`
+
+	fmt.Fprint(w, "\n<div class='maze-box' id='agents-box'>")
+	fmt.Fprintf(w, "<h3>%d个智能体</h3>\n<p id='agents-info' style='font-size: 12px; margin-top: -15px; color: #666;'></p>\n", len(agents))
+	renderMazeWithPath(w, maze, nil, false, overlay)
+	fmt.Fprint(w, `</div>`)
+
+	pathsJSON, _ := json.Marshal(shown.Paths)
+	fmt.Fprintf(w, `
+	<script>
+	initAgents(%s);
+	</script>`, string(pathsJSON))
+
+	fmt.Fprint(w, "\n</div></div></body></html>")
+}
+
+func parseMultiAgentParams(req *http.Request) multiAgentParams {
+	params := multiAgentParams{size: 32, agents: 8, algo: "cbs", window: 8}
+	query := req.URL.Query()
+	if v, err := strconv.Atoi(query.Get("size")); err == nil && v >= 10 && v <= 60 {
+		params.size = v
+	}
+	if v, err := strconv.Atoi(query.Get("agents")); err == nil && v >= 1 && v <= 30 {
+		params.agents = v
+	}
+	if v, err := strconv.Atoi(query.Get("window")); err == nil && v >= 2 && v <= 32 {
+		params.window = v
+	}
+	for _, a := range multiAgentAlgos {
+		if query.Get("algo") == a.name {
+			params.algo = a.name
+		}
+	}
+	return params
+}
+
+func findMultiAgentPaths(algo string, maze [][]int, agents []pathfind.Agent, window int) pathfind.MultiAgentResult {
+	switch algo {
+	case "ca":
+		return pathfind.FindPathsCooperative(maze, agents)
+	case "whca":
+		return pathfind.FindPathsWHCA(maze, agents, window)
+	}
+	return pathfind.FindPathsCBS(maze, agents, maxCBSNodes)
+}
+
+// 在可通行的格子中随机选出互不相同的起点和终点，格子不够时减少智能体数量
+func randomAgents(maze [][]int, n int) []pathfind.Agent {
+	floors := make([][2]int, 0)
+	for r, row := range maze {
+		for c, v := range row {
+			if v == 0 {
+				floors = append(floors, [2]int{r, c})
+			}
+		}
+	}
+	rand.Shuffle(len(floors), func(i, j int) { floors[i], floors[j] = floors[j], floors[i] })
+	n = min(n, len(floors)/2)
+	agents := make([]pathfind.Agent, n)
+	for i := range agents {
+		agents[i] = pathfind.Agent{Start: floors[i], Goal: floors[n+i]}
+	}
+	return agents
+}
+
+func multiAgentStatus(res pathfind.MultiAgentResult) string {
+	switch {
+	case res.Err == pathfind.ErrSearchLimit:
+		return "超过节点上限"
+	case res.Err != nil:
+		return "参数错误: " + html.EscapeString(res.Err.Error())
+	case res.Found:
+		return "成功"
+	}
+	return "失败"
+}
+
+func agentColor(i, n int) string {
+	return fmt.Sprintf("hsl(%d, 75%%, 45%%)", i*360/max(n, 1))
+}
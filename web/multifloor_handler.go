@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"mazemap/pathfind"
+	"mazemap/tiledmap"
+)
+
+func multiFloorHandler(w http.ResponseWriter, r *http.Request) {
+	printHtmlHead(w, "多层地牢")
+
+	floors := 3
+	if f := r.URL.Query().Get("floors"); f != "" {
+		if val, err := strconv.Atoi(f); err == nil && val > 0 && val <= 6 {
+			floors = val
+		}
+	}
+
+	size := 41
+	if s := r.URL.Query().Get("size"); s != "" {
+		if val, err := strconv.Atoi(s); err == nil && val > 12 && val <= 100 {
+			size = val
+		}
+	}
+
+	rooms := 6
+	if rm := r.URL.Query().Get("rooms"); rm != "" {
+		if val, err := strconv.Atoi(rm); err == nil && val > 0 && val <= 50 {
+			rooms = val
+		}
+	}
+
+	corridor := tiledmap.DefaultCorridorOptions()
+	if cw := r.URL.Query().Get("corridorWidth"); cw != "" {
+		if val, err := strconv.Atoi(cw); err == nil && val >= tiledmap.MinCorridorWidth && val <= tiledmap.MaxCorridorWidth {
+			corridor.Width = val
+		}
+	}
+
+	// 控制表单
+	fmt.Fprintf(w, `
+<div class="all-container">
+	<div class="all-controls">
+		<form>
+			层数: <input type="number" name="floors" value="%d" min="1" max="6">
+			尺寸: <input type="number" name="size" value="%d" min="13" max="99" step="2">
+			房间数: <input type="number" name="rooms" value="%d" min="2" max="50">
+			通道宽度: <input type="number" name="corridorWidth" value="%d" min="1" max="3">
+			<input type="submit" value="生成">
+		</form>
+	</div>
+	<div style="display: flex; flex-wrap: wrap; gap: 20px; justify-content: center;">`,
+		floors, size, rooms, corridor.Width)
+
+	m := tiledmap.GenerateMultiFloorDungeon(floors, size, size, rooms, 5, 9, 0.1, corridor)
+
+	// 楼梯作为传送边，起点在第一层第一个房间，终点在最后一层最后一个房间
+	grids := make([][][]int, len(m.Floors))
+	for i, d := range m.Floors {
+		grids[i] = d.Tiles
+	}
+	portals := make([]pathfind.Portal, 0, len(m.Stairs))
+	for _, s := range m.Stairs {
+		pos := [2]int{s.Y, s.X}
+		portals = append(portals, pathfind.Portal{
+			A: pathfind.FloorPos{Floor: s.Floor, Pos: pos},
+			B: pathfind.FloorPos{Floor: s.Floor + 1, Pos: pos},
+		})
+	}
+
+	var res pathfind.MultiFloorPathResult
+	first, last := m.Floors[0], m.Floors[len(m.Floors)-1]
+	if len(first.Rooms) > 0 && len(last.Rooms) > 0 {
+		startRoom := first.Rooms[0]
+		endRoom := last.Rooms[len(last.Rooms)-1]
+		start := pathfind.FloorPos{Floor: 0, Pos: [2]int{startRoom.Y + startRoom.Height/2, startRoom.X + startRoom.Width/2}}
+		end := pathfind.FloorPos{Floor: len(m.Floors) - 1, Pos: [2]int{endRoom.Y + endRoom.Height/2, endRoom.X + endRoom.Width/2}}
+		res = pathfind.FindPathMultiFloor(grids, portals, start, end)
+	}
+
+	for i := range m.Floors {
+		renderFloorWithTitle(w, m, i, res.Path, fmt.Sprintf("第%d层", i+1))
+	}
+
+	fmt.Fprintf(w, "\n</div><p class='info'>跨层寻路 (成本:%d,检查:%d,长度:%d%s)</p></div></body></html>",
+		res.Cost, res.Check, len(res.Path), pathStatus(res.Found, false, res.Err))
+}
+
+func renderFloorWithTitle(w http.ResponseWriter, m *tiledmap.MultiFloorDungeon, floor int, path []pathfind.FloorPos, title string) {
+	d := m.Floors[floor]
+
+	onPath := make(map[[2]int]bool)
+	for _, p := range path {
+		if p.Floor == floor {
+			onPath[p.Pos] = true
+		}
+	}
+
+	fmt.Fprintf(w, `
+		<div>
+			<h3 style="text-align: center">%s</h3>
+			<div class="wfc-grid" style="grid-template-columns: repeat(%d, 8px);">`, title, d.Width)
+
+	up, hasUp := m.UpStair(floor)
+	down, hasDown := m.DownStair(floor)
+	for y := 0; y < d.Height; y++ {
+		for x := 0; x < d.Width; x++ {
+			cellClass := "wall"
+			if d.Tiles[y][x] == 0 {
+				cellClass = "floor"
+			}
+			if onPath[[2]int{y, x}] {
+				cellClass = "path"
+			}
+			if hasUp && up.X == x && up.Y == y {
+				cellClass = "stair-up"
+			}
+			if hasDown && down.X == x && down.Y == y {
+				cellClass = "stair-down"
+			}
+			fmt.Fprintf(w, `<div class="wfc-cell %s"></div>`, cellClass)
+		}
+	}
+	fmt.Fprint(w, "</div></div>")
+}
@@ -9,33 +9,55 @@ import (
 	"mazemap/tiledmap"
 )
 
-func perlinHandler(w http.ResponseWriter, req *http.Request) {
-	printHtmlHead(w, "柏林噪声")
+// perlinPage 柏林噪声页面的参数
+type perlinPage struct {
+	size      int
+	scale     float64
+	threshold float64
+	useFBM    bool
+}
+
+func parsePerlinPage(req *http.Request) perlinPage {
+	p := perlinPage{size: 50, scale: 5.0}
 
-	// 解析参数
-	size := 50
 	if s := req.URL.Query().Get("size"); s != "" {
 		if val, err := strconv.Atoi(s); err == nil && val > 0 && val <= 512 {
-			size = val
+			p.size = val
 		}
 	}
 
-	scale := 5.0
 	if s := req.URL.Query().Get("scale"); s != "" {
 		if val, err := strconv.ParseFloat(s, 64); err == nil && val > 0 {
-			scale = val
+			p.scale = val
 		}
 	}
 
-	threshold := 0.0
 	if t := req.URL.Query().Get("threshold"); t != "" {
 		if val, err := strconv.ParseFloat(t, 64); err == nil {
-			threshold = val
+			p.threshold = val
 		}
 	}
 
 	// 添加 FBM 参数
-	useFBM := req.URL.Query().Get("fbm") == "true"
+	p.useFBM = req.URL.Query().Get("fbm") == "true"
+	return p
+}
+
+func (p perlinPage) maps() []mapMetricsEntry {
+	var maps mapEntries
+	maze := tiledmap.GeneratePerlinMaze(p.size, p.scale, p.threshold, p.useFBM)
+	start, end := tiledmap.MazeCorners(maze)
+	maps.addMaze("柏林噪声地图", "", maze, nil, start, end, "")
+
+	tiledmap.ConnectRegionsByBFS(maze)
+	maps.addMaze("BFS连接所有区域", "", maze, nil, start, end, "")
+	return maps
+}
+
+func perlinHandler(w http.ResponseWriter, req *http.Request) {
+	printHtmlHead(w, "柏林噪声")
+
+	p := parsePerlinPage(req)
 
 	// 修改控制表单，添加 FBM 选项
 	fmt.Fprintf(w, `
@@ -50,21 +72,15 @@ func perlinHandler(w http.ResponseWriter, req *http.Request) {
 		</form>
 	</div>
 	<div style="display: flex; gap: 20px; justify-content: center;">`,
-		size, scale, threshold,
+		p.size, p.scale, p.threshold,
 		func() string {
-			if useFBM {
+			if p.useFBM {
 				return "checked"
 			}
 			return ""
 		}())
 
-	// 生成迷宫
-
-	maze := tiledmap.GeneratePerlinMaze(size, scale, threshold, useFBM)
-	renderMazeWithTitle(w, maze, "柏林噪声地图")
-
-	tiledmap.ConnectRegionsByBFS(maze)
-	renderMazeWithTitle(w, maze, "BFS连接所有区域")
+	renderMapEntries(w, p.maps())
 
 	fmt.Fprint(w, "\n</div></div></body></html>")
 }
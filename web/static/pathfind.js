@@ -112,5 +112,216 @@ function updateStepCell(title, stepData) {
         const dot = document.createElement('div');
         dot.className = 'step-info push-dot';
         stepInfo.appendChild(dot);
+    } else if (stepData.Type === "pop-back") { // 双向搜索中反向搜索检查的格子
+        const dot = document.createElement('div');
+        dot.className = 'step-info pop-back-dot';
+        stepInfo.appendChild(dot);
+    } else if (stepData.Type === "push-back") { // 反向搜索入堆的格子
+        const dot = document.createElement('div');
+        dot.className = 'step-info push-back-dot';
+        stepInfo.appendChild(dot);
+    } else if (stepData.Type === "meet") { // 两个方向相遇的格子
+        const dot = document.createElement('div');
+        dot.className = 'step-info meet-dot';
+        stepInfo.appendChild(dot);
+    }
+}
+
+// D* Lite演示：机器人沿规划的路径移动，点击格子切换墙，
+// 把修改记录（步数:行,列）发给服务器重新模拟，当前步之前的结果不变，从当前位置继续播放
+let dstar = null;
+
+function initDStar(data) {
+    dstar = {data: data, step: 0, toggles: [], timer: null, speed: 200};
+    const container = document.querySelector('#dstar-box .dungeon-container');
+    container.addEventListener('click', dstarClick);
+    dstarRender();
+}
+
+function dstarPixel(v) {
+    return 1 + 9 * (v + 1);
+}
+
+function dstarRender() {
+    const frames = dstar.data.sim.Frames;
+    const step = Math.min(dstar.step, frames.length - 1);
+    const frame = frames[step];
+
+    let replans = 0, cost = 0, astarCost = 0;
+    for (let i = 0; i <= step; i++) {
+        if (frames[i].Replan) {
+            replans++;
+            cost += frames[i].Cost;
+            astarCost += frames[i].AStarCost;
+        }
+    }
+    // 当前路径是最近一次重规划的路径去掉已经走过的部分
+    let path = [];
+    for (let i = step; i >= 0; i--) {
+        if (frames[i].Replan) {
+            path = (frames[i].Path || []).slice(step - i);
+            break;
+        }
     }
+    document.getElementById('dstar-plan').setAttribute('points',
+        path.map(p => `${dstarPixel(p[1] + 0.5)},${dstarPixel(p[0] + 0.5)}`).join(' '));
+    const agent = document.getElementById('dstar-agent');
+    agent.setAttribute('cx', dstarPixel(frame.Pos[1] + 0.5));
+    agent.setAttribute('cy', dstarPixel(frame.Pos[0] + 0.5));
+
+    let status = '';
+    if (step === frames.length - 1) {
+        status = dstar.data.sim.Found ? '，已到达终点' : '，终点不可达';
+    }
+    document.getElementById('dstar-info').textContent =
+        `步骤: ${step + 1} / ${frames.length}，规划${replans}次，D* Lite入堆${cost}次，每次从头A*入堆${astarCost}次${status}`;
+}
+
+function dstarTick() {
+    if (dstar.step >= dstar.data.sim.Frames.length - 1) {
+        dstarStop();
+        return;
+    }
+    dstar.step++;
+    dstarRender();
+}
+
+function dstarStop() {
+    clearInterval(dstar.timer);
+    dstar.timer = null;
+    document.getElementById('dstar-play-btn').textContent = '播放';
+}
+
+function dstarTogglePlayback() {
+    if (dstar.timer) {
+        dstarStop();
+        return;
+    }
+    dstar.timer = setInterval(dstarTick, dstar.speed);
+    document.getElementById('dstar-play-btn').textContent = '暂停';
+}
+
+function dstarSpeed(value) {
+    dstar.speed = parseInt(value);
+    if (dstar.timer) {
+        clearInterval(dstar.timer);
+        dstar.timer = setInterval(dstarTick, dstar.speed);
+    }
+}
+
+// 回到起点，撤销所有修改
+function dstarReset() {
+    dstarStop();
+    const cells = document.querySelector('#dstar-box .dungeon-grid').children;
+    const rows = dstar.data.grid.split('.');
+    rows.forEach((row, r) => {
+        for (let c = 0; c < row.length; c++) {
+            cells[(r + 1) * (dstar.data.width + 2) + c + 1].className = 'dungeon-cell ' + (row[c] === '1' ? 'wall' : 'floor');
+        }
+    });
+    dstar.step = 0;
+    dstar.toggles = [];
+    dstarFetch();
+}
+
+function dstarClick(event) {
+    const rect = event.currentTarget.getBoundingClientRect();
+    const col = Math.floor((event.clientX - rect.left) / 9) - 1;
+    const row = Math.floor((event.clientY - rect.top) / 9) - 1;
+    if (row < 0 || row >= dstar.data.height || col < 0 || col >= dstar.data.width) {
+        return;
+    }
+    const frames = dstar.data.sim.Frames;
+    const pos = frames[Math.min(dstar.step, frames.length - 1)].Pos;
+    if ((row === pos[0] && col === pos[1]) || `${row},${col}` === dstar.data.end) {
+        return;
+    }
+
+    const cells = document.querySelector('#dstar-box .dungeon-grid').children;
+    const cell = cells[(row + 1) * (dstar.data.width + 2) + col + 1];
+    cell.className = 'dungeon-cell ' + (cell.classList.contains('wall') ? 'floor' : 'wall');
+    dstar.step = Math.min(dstar.step, frames.length - 1);
+    dstar.toggles.push(`${dstar.step}:${row},${col}`);
+    dstarFetch();
+}
+
+function dstarFetch() {
+    const params = new URLSearchParams({
+        format: 'json',
+        grid: dstar.data.grid,
+        start: dstar.data.start,
+        end: dstar.data.end,
+        toggles: dstar.toggles.join(';'),
+    });
+    fetch('/dstar?' + params.toString())
+        .then(response => response.json())
+        .then(sim => {
+            dstar.data.sim = sim;
+            dstarRender();
+        });
+}
+
+// 多智能体寻路演示：paths[i][t]为第i个智能体在t时刻的位置
+let agentsPlayback = null;
+
+function initAgents(paths) {
+    agentsPlayback = {paths: paths || [], t: 0, timer: null, speed: 300};
+    agentsRender();
+}
+
+function agentsMakespan() {
+    const paths = agentsPlayback.paths;
+    return paths.length > 0 ? paths[0].length - 1 : 0;
+}
+
+function agentsRender() {
+    const t = agentsPlayback.t;
+    agentsPlayback.paths.forEach((path, i) => {
+        const pos = path[Math.min(t, path.length - 1)];
+        const circle = document.getElementById(`agent-${i}`);
+        circle.setAttribute('cx', 1 + 9 * (pos[1] + 1.5));
+        circle.setAttribute('cy', 1 + 9 * (pos[0] + 1.5));
+    });
+    document.getElementById('agents-info').textContent = `时刻: ${t} / ${agentsMakespan()}`;
+}
+
+function agentsStep() {
+    if (agentsPlayback.t >= agentsMakespan()) {
+        agentsStop();
+        return;
+    }
+    agentsPlayback.t++;
+    agentsRender();
+}
+
+function agentsStop() {
+    clearInterval(agentsPlayback.timer);
+    agentsPlayback.timer = null;
+    document.getElementById('agents-play-btn').textContent = '播放';
+}
+
+function agentsTogglePlayback() {
+    if (agentsPlayback.timer) {
+        agentsStop();
+        return;
+    }
+    if (agentsPlayback.t >= agentsMakespan()) {
+        agentsPlayback.t = 0;
+    }
+    agentsPlayback.timer = setInterval(agentsStep, agentsPlayback.speed);
+    document.getElementById('agents-play-btn').textContent = '暂停';
+}
+
+function agentsSpeed(value) {
+    agentsPlayback.speed = parseInt(value);
+    if (agentsPlayback.timer) {
+        clearInterval(agentsPlayback.timer);
+        agentsPlayback.timer = setInterval(agentsStep, agentsPlayback.speed);
+    }
+}
+
+function agentsReset() {
+    agentsStop();
+    agentsPlayback.t = 0;
+    agentsRender();
 }
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"mazemap/tiledmap"
+)
+
+const (
+	defaultSweepSize    = 31
+	defaultSweepSamples = 5
+	maxSweepSize        = 101
+	maxSweepSamples     = 50
+	maxSweepMaps        = 2000 // 一次扫描最多生成的地图数
+)
+
+// 指标在页面上显示的名字
+var sweepMetricTitles = map[string]string{
+	"floorRatio":         "通路占比",
+	"regionCount":        "区域数",
+	"largestRegionRatio": "最大区域占比",
+	"deadEnds":           "死胡同",
+	"avgCorridorWidth":   "平均宽度",
+	"pathLength":         "路径长度",
+	"tortuosity":         "曲折度",
+	"articulationPoints": "割点数",
+}
+
+// sweepHandler 参数扫描：每个参数组合生成多张地图，统计质量指标
+// format=csv时直接下载所有样本的指标
+func sweepHandler(w http.ResponseWriter, req *http.Request) {
+	cfg, axesStr := parseSweepConfig(req.URL.Query())
+
+	total := cfg.Samples
+	for _, axis := range cfg.Axes {
+		total *= max(len(axis.Values), 1)
+	}
+	if total > maxSweepMaps {
+		http.Error(w, fmt.Sprintf("too many maps: %d > %d", total, maxSweepMaps), http.StatusBadRequest)
+		return
+	}
+
+	if req.URL.Query().Get("format") == "csv" {
+		res := tiledmap.RunSweep(cfg)
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=sweep_%s.csv", cfg.Kind))
+		res.WriteCSV(w)
+		return
+	}
+
+	printHtmlHead(w, "参数扫描")
+
+	// 先运行扫描，下载链接带上实际使用的种子，下载的CSV与页面上的结果一致
+	res := tiledmap.RunSweep(cfg)
+	csvQuery := req.URL.Query()
+	csvQuery.Set("seed", strconv.FormatInt(res.Config.Seed, 10))
+
+	// 控制表单
+	kindOptions := ""
+	for _, kind := range []tiledmap.SweepKind{tiledmap.SweepMaze, tiledmap.SweepCellular} {
+		selected := ""
+		if kind == cfg.Kind {
+			selected = " selected"
+		}
+		kindOptions += fmt.Sprintf(`<option value="%s"%s>%s</option>`, kind, selected, kind)
+	}
+	axisInputs := ""
+	for _, name := range tiledmap.SweepParamNames[cfg.Kind] {
+		axisInputs += fmt.Sprintf(`
+			%s: <input type="text" name="%s" value="%s" size="12" placeholder="%g">`,
+			name, name, axesStr[name], tiledmap.SweepDefaults[name])
+	}
+	seedStr := ""
+	if cfg.Seed != 0 {
+		seedStr = strconv.FormatInt(cfg.Seed, 10)
+	}
+	fmt.Fprintf(w, `
+<div class="all-container">
+	<div class="all-controls">
+		<form>
+			地图: <select name="kind">%s</select>
+			尺寸: <input type="number" name="size" value="%d" min="5" max="%d">
+			样本数: <input type="number" name="samples" value="%d" min="1" max="%d">
+			%s
+			种子: <input type="number" name="seed" value="%s" placeholder="随机">
+			<input type="submit" value="运行">
+		</form>
+	</div>
+	<p class="info">参数取值用逗号分隔，留空时使用默认值；切换地图类型后需要再点一次运行。种子%d，<a href="?%s">下载CSV</a></p>`,
+		kindOptions, cfg.Size, maxSweepSize, cfg.Samples, maxSweepSamples, axisInputs, seedStr,
+		res.Config.Seed, sweepCSVQuery(csvQuery))
+
+	// 每个指标一张柱状图，柱子为平均值，细线为最小值到最大值
+	fmt.Fprint(w, `
+	<div class="sweep-charts">`)
+	for _, metric := range tiledmap.SweepMetricNames {
+		renderSweepChart(w, res, metric)
+	}
+	fmt.Fprint(w, `
+	</div>`)
+
+	// 每个组合的平均指标和代表性的地图
+	names := tiledmap.SweepParamNames[cfg.Kind]
+	fmt.Fprint(w, `
+	<table class="bench-table">
+		<tr><th>#</th>`)
+	for _, name := range names {
+		fmt.Fprintf(w, "<th>%s</th>", name)
+	}
+	for _, metric := range tiledmap.SweepMetricNames {
+		fmt.Fprintf(w, "<th>%s</th>", sweepMetricTitles[metric])
+	}
+	fmt.Fprint(w, "<th>代表地图</th></tr>")
+	for i, combo := range res.Combos {
+		fmt.Fprintf(w, "\n\t\t<tr><td>%d</td>", i+1)
+		for _, name := range names {
+			fmt.Fprintf(w, "<td>%g</td>", combo.Params[name])
+		}
+		for _, metric := range tiledmap.SweepMetricNames {
+			fmt.Fprintf(w, "<td>%.2f</td>", tiledmap.SweepMetricValue(combo.Mean, metric))
+		}
+		fmt.Fprint(w, "<td>")
+		renderThumbnail(w, combo.Grid, 3)
+		fmt.Fprint(w, "</td></tr>")
+	}
+	fmt.Fprint(w, "\n\t</table>\n</div></body></html>")
+}
+
+// 解析扫描配置，同时返回每个参数的原始输入用于回填表单
+func parseSweepConfig(query url.Values) (tiledmap.SweepConfig, map[string]string) {
+	cfg := tiledmap.SweepConfig{
+		Kind:    tiledmap.SweepMaze,
+		Size:    defaultSweepSize,
+		Samples: defaultSweepSamples,
+	}
+
+	if query.Get("kind") == string(tiledmap.SweepCellular) {
+		cfg.Kind = tiledmap.SweepCellular
+	}
+
+	if v, err := strconv.Atoi(query.Get("size")); err == nil && v >= 5 && v <= maxSweepSize {
+		cfg.Size = v
+	}
+
+	if v, err := strconv.Atoi(query.Get("samples")); err == nil && v >= 1 && v <= maxSweepSamples {
+		cfg.Samples = v
+	}
+
+	if v, err := strconv.ParseInt(query.Get("seed"), 10, 64); err == nil {
+		cfg.Seed = v
+	}
+
+	axesStr := make(map[string]string)
+	for _, name := range tiledmap.SweepParamNames[cfg.Kind] {
+		values := parseSweepValues(name, query.Get(name))
+		if len(values) == 0 {
+			continue
+		}
+		cfg.Axes = append(cfg.Axes, tiledmap.SweepAxis{Name: name, Values: values})
+
+		strs := make([]string, len(values))
+		for i, v := range values {
+			strs[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		axesStr[name] = strings.Join(strs, ",")
+	}
+
+	return cfg, axesStr
+}
+
+// 解析逗号分隔的取值，忽略无法解析和超出范围的值
+// iterations的范围是0~MaxIterations，其余参数都是0~1的概率
+func parseSweepValues(name, str string) []float64 {
+	limit := 1.0
+	if name == "iterations" {
+		limit = tiledmap.MaxIterations
+	}
+	values := make([]float64, 0)
+	for _, part := range strings.Split(str, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || v < 0 || v > limit {
+			continue
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+func sweepCSVQuery(query url.Values) string {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("format", "csv")
+	return q.Encode()
+}
+
+// 用SVG画一个指标在所有组合上的柱状图
+func renderSweepChart(w http.ResponseWriter, res tiledmap.SweepResult, metric string) {
+	const (
+		chartHeight = 120
+		barWidth    = 16
+		gap         = 6
+		padding     = 20
+	)
+
+	maxValue := 0.0
+	for _, combo := range res.Combos {
+		for _, s := range combo.Samples {
+			maxValue = max(maxValue, tiledmap.SweepMetricValue(s.Metrics, metric))
+		}
+	}
+	if maxValue == 0 {
+		maxValue = 1
+	}
+	scale := func(v float64) float64 {
+		return chartHeight - v/maxValue*chartHeight + padding
+	}
+
+	width := len(res.Combos)*(barWidth+gap) + gap
+	fmt.Fprintf(w, `
+		<div class="sweep-chart">
+			<h4>%s</h4>
+			<svg width="%d" height="%d">
+				<text x="2" y="12" class="sweep-axis">%.2f</text>`,
+		sweepMetricTitles[metric], width, chartHeight+padding*2, maxValue)
+	for i, combo := range res.Combos {
+		x := gap + i*(barWidth+gap)
+		lo, hi := maxValue, 0.0
+		for _, s := range combo.Samples {
+			v := tiledmap.SweepMetricValue(s.Metrics, metric)
+			lo, hi = min(lo, v), max(hi, v)
+		}
+		mean := tiledmap.SweepMetricValue(combo.Mean, metric)
+		fmt.Fprintf(w, `
+				<rect x="%d" y="%.1f" width="%d" height="%.1f" class="sweep-bar"><title>#%d 平均%.3f 最小%.3f 最大%.3f</title></rect>
+				<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" class="sweep-range"/>
+				<text x="%d" y="%d" class="sweep-axis">%d</text>`,
+			x, scale(mean), barWidth, chartHeight+padding-scale(mean), i+1, mean, lo, hi,
+			x+barWidth/2, scale(hi), x+barWidth/2, scale(lo),
+			x+2, chartHeight+padding*2-4, i+1)
+	}
+	fmt.Fprint(w, `
+			</svg>
+		</div>`)
+}
+
+// 用SVG画地图的缩略图，同一行连续的墙合并成一个矩形
+func renderThumbnail(w http.ResponseWriter, grid [][]int, cell int) {
+	if len(grid) == 0 {
+		return
+	}
+	height, width := len(grid), len(grid[0])
+	fmt.Fprintf(w, `<svg width="%d" height="%d" class="sweep-thumb">`, width*cell, height*cell)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; {
+			if grid[y][x] == 0 {
+				x++
+				continue
+			}
+			end := x
+			for end < width && grid[y][end] != 0 {
+				end++
+			}
+			fmt.Fprintf(w, `<rect x="%d" y="%d" width="%d" height="%d"/>`, x*cell, y*cell, (end-x)*cell, cell)
+			x = end
+		}
+	}
+	fmt.Fprint(w, `</svg>`)
+}